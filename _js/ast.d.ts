@@ -0,0 +1,586 @@
+// Code generated by gen_types.go; DO NOT EDIT.
+
+/** A position in the source; see https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Pos */
+export interface Pos {
+	Offset: number
+	Line: number
+	Col: number
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ArithmExpr */
+export type ArithmExpr =
+	| (Word & { Type: "Word" })
+	| (UnaryArithm & { Type: "UnaryArithm" })
+	| (BinaryArithm & { Type: "BinaryArithm" })
+	| (ParenArithm & { Type: "ParenArithm" })
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Command */
+export type Command =
+	| (CallExpr & { Type: "CallExpr" })
+	| (ArithmCmd & { Type: "ArithmCmd" })
+	| (BinaryCmd & { Type: "BinaryCmd" })
+	| (IfClause & { Type: "IfClause" })
+	| (ForClause & { Type: "ForClause" })
+	| (WhileClause & { Type: "WhileClause" })
+	| (CaseClause & { Type: "CaseClause" })
+	| (Block & { Type: "Block" })
+	| (Subshell & { Type: "Subshell" })
+	| (FuncDecl & { Type: "FuncDecl" })
+	| (TestClause & { Type: "TestClause" })
+	| (DeclClause & { Type: "DeclClause" })
+	| (LetClause & { Type: "LetClause" })
+	| (TimeClause & { Type: "TimeClause" })
+	| (CoprocClause & { Type: "CoprocClause" })
+	| (TestDecl & { Type: "TestDecl" })
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Loop */
+export type Loop =
+	| (WordIter & { Type: "WordIter" })
+	| (CStyleLoop & { Type: "CStyleLoop" })
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Node */
+export type Node =
+	| (File & { Type: "File" })
+	| (Word & { Type: "Word" })
+	| (Lit & { Type: "Lit" })
+	| (SglQuoted & { Type: "SglQuoted" })
+	| (DblQuoted & { Type: "DblQuoted" })
+	| (ParamExp & { Type: "ParamExp" })
+	| (CmdSubst & { Type: "CmdSubst" })
+	| (CallExpr & { Type: "CallExpr" })
+	| (ArithmExp & { Type: "ArithmExp" })
+	| (ProcSubst & { Type: "ProcSubst" })
+	| (ExtGlob & { Type: "ExtGlob" })
+	| (BraceExp & { Type: "BraceExp" })
+	| (ArithmCmd & { Type: "ArithmCmd" })
+	| (BinaryCmd & { Type: "BinaryCmd" })
+	| (IfClause & { Type: "IfClause" })
+	| (ForClause & { Type: "ForClause" })
+	| (WhileClause & { Type: "WhileClause" })
+	| (CaseClause & { Type: "CaseClause" })
+	| (Block & { Type: "Block" })
+	| (Subshell & { Type: "Subshell" })
+	| (FuncDecl & { Type: "FuncDecl" })
+	| (TestClause & { Type: "TestClause" })
+	| (DeclClause & { Type: "DeclClause" })
+	| (LetClause & { Type: "LetClause" })
+	| (TimeClause & { Type: "TimeClause" })
+	| (CoprocClause & { Type: "CoprocClause" })
+	| (TestDecl & { Type: "TestDecl" })
+	| (UnaryArithm & { Type: "UnaryArithm" })
+	| (BinaryArithm & { Type: "BinaryArithm" })
+	| (ParenArithm & { Type: "ParenArithm" })
+	| (UnaryTest & { Type: "UnaryTest" })
+	| (BinaryTest & { Type: "BinaryTest" })
+	| (ParenTest & { Type: "ParenTest" })
+	| (WordIter & { Type: "WordIter" })
+	| (CStyleLoop & { Type: "CStyleLoop" })
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#TestExpr */
+export type TestExpr =
+	| (Word & { Type: "Word" })
+	| (UnaryTest & { Type: "UnaryTest" })
+	| (BinaryTest & { Type: "BinaryTest" })
+	| (ParenTest & { Type: "ParenTest" })
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#WordPart */
+export type WordPart =
+	| (Lit & { Type: "Lit" })
+	| (SglQuoted & { Type: "SglQuoted" })
+	| (DblQuoted & { Type: "DblQuoted" })
+	| (ParamExp & { Type: "ParamExp" })
+	| (CmdSubst & { Type: "CmdSubst" })
+	| (ArithmExp & { Type: "ArithmExp" })
+	| (ProcSubst & { Type: "ProcSubst" })
+	| (ExtGlob & { Type: "ExtGlob" })
+	| (BraceExp & { Type: "BraceExp" })
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ArithmCmd */
+export interface ArithmCmd {
+	Type?: "ArithmCmd"
+	Pos?: Pos
+	End?: Pos
+	Left?: Pos
+	Right?: Pos
+	Unsigned?: boolean
+	X?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ArithmExp */
+export interface ArithmExp {
+	Type?: "ArithmExp"
+	Pos?: Pos
+	End?: Pos
+	Left?: Pos
+	Right?: Pos
+	Bracket?: boolean
+	Unsigned?: boolean
+	X?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ArrayElem */
+export interface ArrayElem {
+	Pos?: Pos
+	End?: Pos
+	Index?: ArithmExpr
+	Value?: Word
+	Comments?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ArrayExpr */
+export interface ArrayExpr {
+	Pos?: Pos
+	End?: Pos
+	Lparen?: Pos
+	Rparen?: Pos
+	Elems?: ArrayElem[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Assign */
+export interface Assign {
+	Pos?: Pos
+	End?: Pos
+	Append?: boolean
+	Naked?: boolean
+	Name?: Lit
+	Index?: ArithmExpr
+	Value?: Word
+	Array?: ArrayExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#BinaryArithm */
+export interface BinaryArithm {
+	Type?: "BinaryArithm"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	X?: ArithmExpr
+	Y?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#BinaryCmd */
+export interface BinaryCmd {
+	Type?: "BinaryCmd"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	X?: Stmt
+	Y?: Stmt
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#BinaryTest */
+export interface BinaryTest {
+	Type?: "BinaryTest"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	X?: TestExpr
+	Y?: TestExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Block */
+export interface Block {
+	Type?: "Block"
+	Pos?: Pos
+	End?: Pos
+	Lbrace?: Pos
+	Rbrace?: Pos
+	Stmts?: Stmt[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#BraceExp */
+export interface BraceExp {
+	Type?: "BraceExp"
+	Pos?: Pos
+	End?: Pos
+	Sequence?: boolean
+	Elems?: Word[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#CStyleLoop */
+export interface CStyleLoop {
+	Type?: "CStyleLoop"
+	Pos?: Pos
+	End?: Pos
+	Lparen?: Pos
+	Rparen?: Pos
+	Init?: ArithmExpr
+	Cond?: ArithmExpr
+	Post?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#CallExpr */
+export interface CallExpr {
+	Type?: "CallExpr"
+	Pos?: Pos
+	End?: Pos
+	Assigns?: Assign[]
+	Args?: Word[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#CaseClause */
+export interface CaseClause {
+	Type?: "CaseClause"
+	Pos?: Pos
+	End?: Pos
+	Case?: Pos
+	In?: Pos
+	Esac?: Pos
+	Braces?: boolean
+	Word?: Word
+	Items?: CaseItem[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#CaseItem */
+export interface CaseItem {
+	Pos?: Pos
+	End?: Pos
+	Op?: number
+	OpPos?: Pos
+	Comments?: Comment[]
+	Patterns?: Word[]
+	Stmts?: Stmt[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#CmdSubst */
+export interface CmdSubst {
+	Type?: "CmdSubst"
+	Pos?: Pos
+	End?: Pos
+	Left?: Pos
+	Right?: Pos
+	Stmts?: Stmt[]
+	Last?: Comment[]
+	Backquotes?: boolean
+	TempFile?: boolean
+	ReplyVar?: boolean
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Comment */
+export interface Comment {
+	Pos?: Pos
+	End?: Pos
+	Hash?: Pos
+	Text?: string
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#CoprocClause */
+export interface CoprocClause {
+	Type?: "CoprocClause"
+	Pos?: Pos
+	End?: Pos
+	Coproc?: Pos
+	Name?: Word
+	Stmt?: Stmt
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#DblQuoted */
+export interface DblQuoted {
+	Type?: "DblQuoted"
+	Pos?: Pos
+	End?: Pos
+	Left?: Pos
+	Right?: Pos
+	Dollar?: boolean
+	Parts?: WordPart[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#DeclClause */
+export interface DeclClause {
+	Type?: "DeclClause"
+	Pos?: Pos
+	End?: Pos
+	Variant?: Lit
+	Args?: Assign[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Expansion */
+export interface Expansion {
+	Op?: number
+	Word?: Word
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ExtGlob */
+export interface ExtGlob {
+	Type?: "ExtGlob"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	Pattern?: Lit
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#File */
+export interface File {
+	Type?: "File"
+	Pos?: Pos
+	End?: Pos
+	Name?: string
+	Shebang?: string
+	Stmts?: Stmt[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ForClause */
+export interface ForClause {
+	Type?: "ForClause"
+	Pos?: Pos
+	End?: Pos
+	ForPos?: Pos
+	DoPos?: Pos
+	DonePos?: Pos
+	Select?: boolean
+	Braces?: boolean
+	Loop?: Loop
+	Do?: Stmt[]
+	DoLast?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#FuncDecl */
+export interface FuncDecl {
+	Type?: "FuncDecl"
+	Pos?: Pos
+	End?: Pos
+	Position?: Pos
+	RsrvWord?: boolean
+	Parens?: boolean
+	Name?: Lit
+	Body?: Stmt
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#IfClause */
+export interface IfClause {
+	Type?: "IfClause"
+	Pos?: Pos
+	End?: Pos
+	Position?: Pos
+	ThenPos?: Pos
+	FiPos?: Pos
+	Cond?: Stmt[]
+	CondLast?: Comment[]
+	Then?: Stmt[]
+	ThenLast?: Comment[]
+	Else?: IfClause
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#LetClause */
+export interface LetClause {
+	Type?: "LetClause"
+	Pos?: Pos
+	End?: Pos
+	Let?: Pos
+	Exprs?: ArithmExpr[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Lit */
+export interface Lit {
+	Type?: "Lit"
+	Pos?: Pos
+	End?: Pos
+	ValuePos?: Pos
+	ValueEnd?: Pos
+	Value?: string
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ParamExp */
+export interface ParamExp {
+	Type?: "ParamExp"
+	Pos?: Pos
+	End?: Pos
+	Dollar?: Pos
+	Rbrace?: Pos
+	Short?: boolean
+	Excl?: boolean
+	Length?: boolean
+	Width?: boolean
+	Param?: Lit
+	Index?: ArithmExpr
+	Slice?: Slice
+	Repl?: Replace
+	Names?: number
+	Exp?: Expansion
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ParenArithm */
+export interface ParenArithm {
+	Type?: "ParenArithm"
+	Pos?: Pos
+	End?: Pos
+	Lparen?: Pos
+	Rparen?: Pos
+	X?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ParenTest */
+export interface ParenTest {
+	Type?: "ParenTest"
+	Pos?: Pos
+	End?: Pos
+	Lparen?: Pos
+	Rparen?: Pos
+	X?: TestExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ProcSubst */
+export interface ProcSubst {
+	Type?: "ProcSubst"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Rparen?: Pos
+	Op?: number
+	Stmts?: Stmt[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Redirect */
+export interface Redirect {
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	N?: Lit
+	Word?: Word
+	Hdoc?: Word
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Replace */
+export interface Replace {
+	All?: boolean
+	Orig?: Word
+	With?: Word
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#SglQuoted */
+export interface SglQuoted {
+	Type?: "SglQuoted"
+	Pos?: Pos
+	End?: Pos
+	Left?: Pos
+	Right?: Pos
+	Dollar?: boolean
+	Value?: string
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Slice */
+export interface Slice {
+	Offset?: ArithmExpr
+	Length?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Stmt */
+export interface Stmt {
+	Pos?: Pos
+	End?: Pos
+	Comments?: Comment[]
+	Cmd?: Command
+	Position?: Pos
+	Semicolon?: Pos
+	Negated?: boolean
+	Background?: boolean
+	Coprocess?: boolean
+	Redirs?: Redirect[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Subshell */
+export interface Subshell {
+	Type?: "Subshell"
+	Pos?: Pos
+	End?: Pos
+	Lparen?: Pos
+	Rparen?: Pos
+	Stmts?: Stmt[]
+	Last?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#TestClause */
+export interface TestClause {
+	Type?: "TestClause"
+	Pos?: Pos
+	End?: Pos
+	Left?: Pos
+	Right?: Pos
+	X?: TestExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#TestDecl */
+export interface TestDecl {
+	Type?: "TestDecl"
+	Pos?: Pos
+	End?: Pos
+	Position?: Pos
+	Description?: Word
+	Body?: Stmt
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#TimeClause */
+export interface TimeClause {
+	Type?: "TimeClause"
+	Pos?: Pos
+	End?: Pos
+	Time?: Pos
+	PosixFormat?: boolean
+	Stmt?: Stmt
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#UnaryArithm */
+export interface UnaryArithm {
+	Type?: "UnaryArithm"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	Post?: boolean
+	X?: ArithmExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#UnaryTest */
+export interface UnaryTest {
+	Type?: "UnaryTest"
+	Pos?: Pos
+	End?: Pos
+	OpPos?: Pos
+	Op?: number
+	X?: TestExpr
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#WhileClause */
+export interface WhileClause {
+	Type?: "WhileClause"
+	Pos?: Pos
+	End?: Pos
+	WhilePos?: Pos
+	DoPos?: Pos
+	DonePos?: Pos
+	Until?: boolean
+	Cond?: Stmt[]
+	CondLast?: Comment[]
+	Do?: Stmt[]
+	DoLast?: Comment[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Word */
+export interface Word {
+	Type?: "Word"
+	Pos?: Pos
+	End?: Pos
+	Parts?: WordPart[]
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#WordIter */
+export interface WordIter {
+	Type?: "WordIter"
+	Pos?: Pos
+	End?: Pos
+	Name?: Lit
+	InPos?: Pos
+	Items?: Word[]
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2019, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build ignore
+
+// gen_types writes TypeScript definitions for the syntax tree as encoded by
+// the typedjson package, which is how the syntax tree is passed to JS.
+//
+// To run: go run gen_types.go >ast.d.ts
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// nodes lists the node types which may appear in interface fields, such as
+// [syntax.Stmt.Cmd], and thus carry a "Type" key in their JSON objects.
+var nodes = []reflect.Type{
+	reflect.TypeFor[syntax.File](),
+	reflect.TypeFor[syntax.Word](),
+
+	reflect.TypeFor[syntax.Lit](),
+	reflect.TypeFor[syntax.SglQuoted](),
+	reflect.TypeFor[syntax.DblQuoted](),
+	reflect.TypeFor[syntax.ParamExp](),
+	reflect.TypeFor[syntax.CmdSubst](),
+	reflect.TypeFor[syntax.CallExpr](),
+	reflect.TypeFor[syntax.ArithmExp](),
+	reflect.TypeFor[syntax.ProcSubst](),
+	reflect.TypeFor[syntax.ExtGlob](),
+	reflect.TypeFor[syntax.BraceExp](),
+
+	reflect.TypeFor[syntax.ArithmCmd](),
+	reflect.TypeFor[syntax.BinaryCmd](),
+	reflect.TypeFor[syntax.IfClause](),
+	reflect.TypeFor[syntax.ForClause](),
+	reflect.TypeFor[syntax.WhileClause](),
+	reflect.TypeFor[syntax.CaseClause](),
+	reflect.TypeFor[syntax.Block](),
+	reflect.TypeFor[syntax.Subshell](),
+	reflect.TypeFor[syntax.FuncDecl](),
+	reflect.TypeFor[syntax.TestClause](),
+	reflect.TypeFor[syntax.DeclClause](),
+	reflect.TypeFor[syntax.LetClause](),
+	reflect.TypeFor[syntax.TimeClause](),
+	reflect.TypeFor[syntax.CoprocClause](),
+	reflect.TypeFor[syntax.TestDecl](),
+
+	reflect.TypeFor[syntax.UnaryArithm](),
+	reflect.TypeFor[syntax.BinaryArithm](),
+	reflect.TypeFor[syntax.ParenArithm](),
+
+	reflect.TypeFor[syntax.UnaryTest](),
+	reflect.TypeFor[syntax.BinaryTest](),
+	reflect.TypeFor[syntax.ParenTest](),
+
+	reflect.TypeFor[syntax.WordIter](),
+	reflect.TypeFor[syntax.CStyleLoop](),
+}
+
+var (
+	nodeType = reflect.TypeFor[syntax.Node]()
+	posType  = reflect.TypeFor[syntax.Pos]()
+)
+
+type generator struct {
+	structs    map[string]reflect.Type
+	interfaces map[string]reflect.Type
+}
+
+func main() {
+	g := &generator{
+		structs:    make(map[string]reflect.Type),
+		interfaces: make(map[string]reflect.Type),
+	}
+	for _, typ := range nodes {
+		g.addStruct(typ)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by gen_types.go; DO NOT EDIT.\n\n")
+	sb.WriteString("/** A position in the source; see https://pkg.go.dev/mvdan.cc/sh/v3/syntax#Pos */\n")
+	sb.WriteString("export interface Pos {\n\tOffset: number\n\tLine: number\n\tCol: number\n}\n")
+
+	// The root node given to print must carry its type too.
+	g.interfaces["Node"] = nodeType
+	for _, name := range sortedKeys(g.interfaces) {
+		iface := g.interfaces[name]
+		var members []string
+		for _, typ := range nodes {
+			if reflect.PointerTo(typ).Implements(iface) {
+				members = append(members, fmt.Sprintf("(%s & { Type: %q })", typ.Name(), typ.Name()))
+			}
+		}
+		fmt.Fprintf(&sb, "\n/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#%s */\n", name)
+		fmt.Fprintf(&sb, "export type %s =\n\t| %s\n", name, strings.Join(members, "\n\t| "))
+	}
+
+	for _, name := range sortedKeys(g.structs) {
+		typ := g.structs[name]
+		fmt.Fprintf(&sb, "\n/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#%s */\n", name)
+		fmt.Fprintf(&sb, "export interface %s {\n", name)
+		if slices.Contains(nodes, typ) {
+			fmt.Fprintf(&sb, "\tType?: %q\n", name)
+		}
+		if reflect.PointerTo(typ).Implements(nodeType) {
+			sb.WriteString("\tPos?: Pos\n\tEnd?: Pos\n")
+		}
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fmt.Fprintf(&sb, "\t%s?: %s\n", field.Name, g.tsType(field.Type))
+		}
+		sb.WriteString("}\n")
+	}
+	os.Stdout.WriteString(sb.String())
+}
+
+func (g *generator) addStruct(typ reflect.Type) {
+	if _, ok := g.structs[typ.Name()]; ok {
+		return
+	}
+	g.structs[typ.Name()] = typ
+	for i := range typ.NumField() {
+		if field := typ.Field(i); field.IsExported() {
+			g.tsType(field.Type)
+		}
+	}
+}
+
+// tsType returns the TypeScript type for a Go type, recording any structs and
+// interfaces which need their own definitions.
+func (g *generator) tsType(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return g.tsType(typ.Elem())
+	case reflect.Slice:
+		return g.tsType(typ.Elem()) + "[]"
+	case reflect.Interface:
+		g.interfaces[typ.Name()] = typ
+		return typ.Name()
+	case reflect.Struct:
+		if typ == posType {
+			return "Pos"
+		}
+		g.addStruct(typ)
+		return typ.Name()
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Uint32:
+		// Operators and tokens, such as [syntax.BinCmdOperator].
+		return "number"
+	}
+	panic(fmt.Sprintf("unsupported type: %s", typ))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
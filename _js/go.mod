@@ -1,10 +1,12 @@
 module shjs
 
-go 1.19
+go 1.22.0
 
 replace mvdan.cc/sh/v3 => ../
 
+require mvdan.cc/sh/v3 v3.0.0-00010101000000-000000000000
+
 require (
-	github.com/gopherjs/gopherjs v1.17.2
-	mvdan.cc/sh/v3 v3.0.0-00010101000000-000000000000
+	golang.org/x/sys v0.27.0 // indirect
+	golang.org/x/term v0.26.0 // indirect
 )
@@ -0,0 +1,103 @@
+// Copyright (c) 2019, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+import type { File, Node } from "./ast"
+
+export * from "./ast"
+
+/** A shell language variant; see https://pkg.go.dev/mvdan.cc/sh/v3/syntax#LangVariant */
+export type LangVariant = "bash" | "posix" | "sh" | "mksh" | "bats" | "auto"
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#ParserOption */
+export interface ParseOptions {
+	/** Used in error messages. */
+	filename?: string
+	variant?: LangVariant
+	keepComments?: boolean
+	stopAt?: string
+}
+
+/** See https://pkg.go.dev/mvdan.cc/sh/v3/syntax#PrinterOption */
+export interface PrintOptions {
+	/** The number of spaces to indent with; zero means tabs. */
+	indent?: number
+	binaryNextLine?: boolean
+	switchCaseIndent?: boolean
+	spaceRedirects?: boolean
+	keepPadding?: boolean
+	minify?: boolean
+	singleLine?: boolean
+	functionNextLine?: boolean
+}
+
+/** The errors thrown when parsing fails carry the position of the error. */
+export interface ParseError extends Error {
+	filename: string
+	line: number
+	col: number
+	/** Whether the input ended before the program was complete. */
+	incomplete: boolean
+}
+
+/** What an exec handler receives besides the arguments. */
+export interface ExecContext {
+	dir: string
+	/** The exported variables. */
+	env: Record<string, string>
+	/** All of the standard input, which is empty if there is none. */
+	stdin: string
+}
+
+/** A missing status means zero, a success. */
+export interface ExecResult {
+	status?: number
+	stdout?: string
+	stderr?: string
+}
+
+/**
+ * Runs the programs which scripts call, as the interpreter cannot start
+ * processes itself; see https://pkg.go.dev/mvdan.cc/sh/v3/interp#ExecHandlerFunc
+ */
+export type ExecHandler = (
+	args: string[],
+	ctx: ExecContext,
+) => ExecResult | void | Promise<ExecResult | void>
+
+export interface RunOptions extends ParseOptions {
+	/** The environment variables; none by default. */
+	env?: Record<string, string>
+	/** The working directory; "/" by default. */
+	dir?: string
+	stdin?: string
+	/** The positional parameters, such as $1. */
+	params?: string[]
+	/** Without a handler, running any program fails with status 127. */
+	exec?: ExecHandler
+}
+
+export interface RunResult {
+	status: number
+	stdout: string
+	stderr: string
+}
+
+export interface Sh {
+	/** Parses a script into a syntax tree. Throws a ParseError on failure. */
+	parse(src: string, opts?: ParseOptions): File
+	/** Prints a syntax tree, whose root node must have its "Type" key set. */
+	print(node: Node, opts?: PrintOptions): string
+	/** Parses and prints a script, like shfmt. */
+	format(src: string, opts?: ParseOptions & PrintOptions): string
+	/** Interprets a script; see https://pkg.go.dev/mvdan.cc/sh/v3/interp */
+	run(src: string, opts?: RunOptions): Promise<RunResult>
+}
+
+/**
+ * Loads the WebAssembly module, which only happens once.
+ * By default, sh.wasm is read from the package directory in Node,
+ * or fetched relative to the page in browsers.
+ */
+export function load(
+	source?: string | URL | Response | PromiseLike<Response> | BufferSource,
+): Promise<Sh>
@@ -0,0 +1,91 @@
+// Copyright (c) 2019, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+'use strict';
+
+(function (global, factory) {
+	if (typeof module === 'object' && module.exports) {
+		// node, or a bundler
+		require('./wasm_exec.js')
+		module.exports = factory(global, true)
+	} else {
+		// browser, which must load wasm_exec.js first
+		global.sh = factory(global, false)
+	}
+}(globalThis, function (global, isNode) {
+	let loaded = null
+
+	// load instantiates the wasm module, once. source can be a URL to fetch,
+	// a fetch response, or the module's bytes. By default, sh.wasm is read
+	// from this package's directory in node, or fetched in the browser.
+	function load(source) {
+		if (loaded === null) {
+			loaded = instantiate(source).catch((err) => {
+				loaded = null
+				throw err
+			})
+		}
+		return loaded
+	}
+
+	async function instantiate(source) {
+		if (source === undefined) {
+			if (isNode) {
+				const path = require('path')
+				source = require('fs').readFileSync(path.join(__dirname, 'sh.wasm'))
+			} else {
+				source = 'sh.wasm'
+			}
+		}
+		if (typeof source === 'string' || source instanceof URL) {
+			source = fetch(source)
+		}
+		source = await source
+		if (typeof Response !== 'undefined' && source instanceof Response) {
+			source = await source.arrayBuffer()
+		}
+		const go = new global.Go()
+		const { instance } = await WebAssembly.instantiate(source, go.importObject)
+		// The Go program never exits; running it until it blocks sets the
+		// global object with its API.
+		go.run(instance)
+		const exps = global.__mvdanSh
+		delete global.__mvdanSh
+		return wrap(exps)
+	}
+
+	function call(fn, ...args) {
+		const result = fn(...args)
+		if (result.error) {
+			throw result.error
+		}
+		return result.value
+	}
+
+	function wrap(exps) {
+		return {
+			parse(src, opts) {
+				return JSON.parse(call(exps.parse, src, opts))
+			},
+			print(node, opts) {
+				return call(exps.print, JSON.stringify(node), opts)
+			},
+			format(src, opts) {
+				return call(exps.format, src, opts)
+			},
+			run(src, opts) {
+				return new Promise((resolve, reject) => {
+					call(exps.run, src, opts, (result) => {
+						if (result.error) {
+							reject(result.error)
+						} else {
+							resolve(result.value)
+						}
+					})
+				})
+			},
+		}
+	}
+
+	return { load }
+}))
@@ -1,253 +1,338 @@
+// Copyright (c) 2019, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build js && wasm
+
 package main
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"math"
-	"os"
 	"strings"
+	"syscall/js"
 
-	"github.com/gopherjs/gopherjs/js"
-
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
 	"mvdan.cc/sh/v3/syntax"
+	"mvdan.cc/sh/v3/syntax/typedjson"
 )
 
+// main exposes the API on a global object, which index.js picks up and then
+// removes. The AST is passed back and forth as typed JSON strings, which
+// index.js encodes and decodes, as JS cannot hold onto Go pointers.
 func main() {
-	var exps *js.Object
-
-	if js.Module != js.Undefined {
-		// node
-		exps = js.Module.Get("exports")
-	} else {
-		// browser, `module.exports` in unavailable
-		exps = js.MakeWrapper(map[string]interface{}{})
-		originalGlobalSh := js.Global.Get("sh")
-		exps.Set("noConflict", func() *js.Object {
-			js.Global.Set("sh", originalGlobalSh)
-			return exps
-		})
-		// make it accessible on `window.sh`
-		js.Global.Set("sh", exps)
-	}
-
-	exps.Set("syntax", map[string]interface{}{})
-
-	stx := exps.Get("syntax")
-
-	// Type helpers just for JS
-	stx.Set("NodeType", func(v interface{}) string {
-		if v == nil {
-			return "nil"
-		}
-		node, ok := v.(syntax.Node)
-		if !ok {
-			panic("NodeType requires a Node argument")
-		}
-		typ := fmt.Sprintf("%T", node)
-		if i := strings.LastIndexAny(typ, "*.]"); i >= 0 {
-			typ = typ[i+1:]
-		}
-		return typ
-	})
-
-	// Parser
-	stx.Set("NewParser", func(options ...func(interface{})) *js.Object {
-		p := syntax.NewParser()
-		jp := js.MakeFullWrapper(&jsParser{Parser: *p})
-		// Apply the options after we've wrapped the parser, as
-		// otherwise we cannot internalise the value.
-		for _, opt := range options {
-			opt(jp)
-		}
-		return jp
-	})
-	stx.Set("IsIncomplete", syntax.IsIncomplete)
-
-	stx.Set("KeepComments", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.KeepComments(enabled)(&v.(*jsParser).Parser)
-		}
-	})
-	stx.Set("Variant", func(l syntax.LangVariant) func(interface{}) {
-		if math.IsNaN(float64(l)) {
-			panic("Variant requires a LangVariant argument")
-		}
-		return func(v interface{}) {
-			syntax.Variant(l)(&v.(*jsParser).Parser)
-		}
-	})
-	stx.Set("LangBash", syntax.LangBash)
-	stx.Set("LangPOSIX", syntax.LangPOSIX)
-	stx.Set("LangMirBSDKorn", syntax.LangMirBSDKorn)
-	stx.Set("StopAt", func(word string) func(interface{}) {
-		return func(v interface{}) {
-			syntax.StopAt(word)(&v.(*jsParser).Parser)
-		}
-	})
-
-	// Printer
-	stx.Set("NewPrinter", func(options ...func(interface{})) *js.Object {
-		p := syntax.NewPrinter()
-		jp := js.MakeFullWrapper(&jsPrinter{Printer: *p})
-		// Apply the options after we've wrapped the printer, as
-		// otherwise we cannot internalise the value.
-		for _, opt := range options {
-			opt(jp)
-		}
-		return jp
-	})
+	exps := js.Global().Get("Object").New()
+	exps.Set("parse", js.FuncOf(jsParse))
+	exps.Set("print", js.FuncOf(jsPrint))
+	exps.Set("format", js.FuncOf(jsFormat))
+	exps.Set("run", js.FuncOf(jsRun))
+	js.Global().Set("__mvdanSh", exps)
 
-	stx.Set("Indent", func(spaces uint) func(interface{}) {
-		return func(v interface{}) {
-			syntax.Indent(spaces)(&v.(*jsPrinter).Printer)
-		}
-	})
-	stx.Set("BinaryNextLine", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.BinaryNextLine(enabled)(&v.(*jsPrinter).Printer)
-		}
-	})
-	stx.Set("SwitchCaseIndent", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.SwitchCaseIndent(enabled)(&v.(*jsPrinter).Printer)
-		}
-	})
-	stx.Set("SpaceRedirects", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.SpaceRedirects(enabled)(&v.(*jsPrinter).Printer)
-		}
-	})
-	stx.Set("KeepPadding", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.KeepPadding(enabled)(&v.(*jsPrinter).Printer)
-		}
-	})
-	stx.Set("Minify", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.Minify(enabled)(&v.(*jsPrinter).Printer)
-		}
-	})
-	stx.Set("FunctionNextLine", func(enabled bool) func(interface{}) {
-		return func(v interface{}) {
-			syntax.FunctionNextLine(enabled)(&v.(*jsPrinter).Printer)
-		}
-	})
+	// Keep the Go program alive, as the functions above may be called at
+	// any time.
+	select {}
+}
 
-	// Syntax utilities
-	stx.Set("Walk", func(node syntax.Node, jsFn func(*js.Object) bool) {
-		fn := func(node syntax.Node) bool {
-			if node == nil {
-				return jsFn(nil)
+// catch turns a Go panic into an object with an "error" property, which
+// index.js throws. Otherwise, results are in a "value" property.
+func catch(fn func() js.Value) (result js.Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
 			}
-			return jsFn(js.MakeFullWrapper(node))
+			result = js.Global().Get("Object").New()
+			result.Set("error", jsError(err))
 		}
-		syntax.Walk(node, fn)
-	})
-	stx.Set("DebugPrint", func(node syntax.Node) {
-		syntax.DebugPrint(os.Stdout, node)
-	})
-	stx.Set("SplitBraces", func(w *syntax.Word) *js.Object {
-		syntax.SplitBraces(w)
-		return js.MakeFullWrapper(w)
-	})
+	}()
+	return fn()
 }
 
-func throw(err error) {
-	js.Global.Call("$throw", js.MakeFullWrapper(err))
+// jsError converts an error into a JS Error, adding the details of parse errors
+// as properties so that they can be inspected.
+func jsError(err error) js.Value {
+	jerr := js.Global().Get("Error").New(err.Error())
+	var perr syntax.ParseError
+	var lerr syntax.LangError
+	switch {
+	case errors.As(err, &perr):
+		jerr.Set("filename", perr.Filename)
+		jerr.Set("line", perr.Pos.Line())
+		jerr.Set("col", perr.Pos.Col())
+		jerr.Set("incomplete", perr.Incomplete)
+	case errors.As(err, &lerr):
+		jerr.Set("filename", lerr.Filename)
+		jerr.Set("line", lerr.Pos.Line())
+		jerr.Set("col", lerr.Pos.Col())
+		jerr.Set("incomplete", false)
+	}
+	return jerr
+}
+
+func valueResult(v any) js.Value {
+	result := js.Global().Get("Object").New()
+	result.Set("value", v)
+	return result
 }
 
-// streamReader is an [io.Reader] wrapper for Node's stream.Readable. See
-// https://nodejs.org/api/stream.html#stream_class_stream_readable
-// TODO: support https://streams.spec.whatwg.org/#rs-class too?
-type streamReader struct {
-	stream *js.Object
+func optString(opts js.Value, name string) string {
+	if opts.Type() != js.TypeObject {
+		return ""
+	}
+	if v := opts.Get(name); v.Type() == js.TypeString {
+		return v.String()
+	}
+	return ""
 }
 
-func (r streamReader) Read(p []byte) (n int, err error) {
-	obj := r.stream.Call("read", len(p))
-	if obj == nil {
-		return 0, io.EOF
+func optBool(opts js.Value, name string) bool {
+	if opts.Type() != js.TypeObject {
+		return false
 	}
-	bs := []byte(obj.String())
-	return copy(p, bs), nil
+	return opts.Get(name).Truthy()
 }
 
-type jsParser struct {
-	syntax.Parser
+func optArg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
 
-	accumulated []*syntax.Stmt
-	incomplete  bytes.Buffer
+func newParser(opts js.Value) *syntax.Parser {
+	parser := syntax.NewParser(syntax.KeepComments(optBool(opts, "keepComments")))
+	if s := optString(opts, "variant"); s != "" {
+		var lang syntax.LangVariant
+		if err := lang.Set(s); err != nil {
+			panic(err)
+		}
+		syntax.Variant(lang)(parser)
+	}
+	if s := optString(opts, "stopAt"); s != "" {
+		syntax.StopAt(s)(parser)
+	}
+	return parser
 }
 
-func adaptReader(src *js.Object) io.Reader {
-	if src.Get("read") != js.Undefined {
-		return streamReader{stream: src}
+func newPrinter(opts js.Value) *syntax.Printer {
+	printer := syntax.NewPrinter(
+		syntax.BinaryNextLine(optBool(opts, "binaryNextLine")),
+		syntax.SwitchCaseIndent(optBool(opts, "switchCaseIndent")),
+		syntax.SpaceRedirects(optBool(opts, "spaceRedirects")),
+		syntax.KeepPadding(optBool(opts, "keepPadding")),
+		syntax.Minify(optBool(opts, "minify")),
+		syntax.SingleLine(optBool(opts, "singleLine")),
+		syntax.FunctionNextLine(optBool(opts, "functionNextLine")),
+	)
+	if opts.Type() == js.TypeObject {
+		if v := opts.Get("indent"); v.Type() == js.TypeNumber {
+			syntax.Indent(uint(v.Int()))(printer)
+		}
 	}
-	return strings.NewReader(src.String())
+	return printer
 }
 
-func (p *jsParser) Parse(src *js.Object, name string) *js.Object {
-	f, err := p.Parser.Parse(adaptReader(src), name)
+func parseFile(src string, opts js.Value) *syntax.File {
+	f, err := newParser(opts).Parse(strings.NewReader(src), optString(opts, "filename"))
 	if err != nil {
-		throw(err)
+		panic(err)
 	}
-	return js.MakeFullWrapper(f)
+	return f
 }
 
-func (p *jsParser) Incomplete() bool {
-	return p.Parser.Incomplete()
+func printNode(node syntax.Node, opts js.Value) string {
+	var buf bytes.Buffer
+	if err := newPrinter(opts).Print(&buf, node); err != nil {
+		panic(err)
+	}
+	return buf.String()
 }
 
-func (p *jsParser) Interactive(src *js.Object, jsFn func([]*js.Object) bool) {
-	fn := func(stmts []*syntax.Stmt) bool {
-		objs := make([]*js.Object, len(stmts))
-		for i, stmt := range stmts {
-			objs[i] = js.MakeFullWrapper(stmt)
+// jsParse(src, opts) returns the syntax tree as a typed JSON string.
+func jsParse(this js.Value, args []js.Value) any {
+	return catch(func() js.Value {
+		f := parseFile(args[0].String(), optArg(args, 1))
+		var buf bytes.Buffer
+		if err := typedjson.Encode(&buf, f); err != nil {
+			panic(err)
 		}
-		return jsFn(objs)
-	}
-	err := p.Parser.Interactive(adaptReader(src), fn)
-	if err != nil {
-		throw(err)
-	}
+		return valueResult(buf.String())
+	})
 }
 
-func (p *jsParser) InteractiveStep(line string) []*syntax.Stmt {
-	// pick up previous chunks of the incomplete statement
-	r := strings.NewReader(p.incomplete.String() + line)
-	lastEnd := uint(0)
-	err := p.Parser.Interactive(r, func(stmts []*syntax.Stmt) bool {
-		if len(stmts) > 0 {
-			// don't re-parse finished statements
-			lastEnd = stmts[len(stmts)-1].End().Offset()
+// jsPrint(json, opts) prints a syntax tree given as a typed JSON string.
+func jsPrint(this js.Value, args []js.Value) any {
+	return catch(func() js.Value {
+		node, err := typedjson.Decode(strings.NewReader(args[0].String()))
+		if err != nil {
+			panic(err)
 		}
-		p.accumulated = append(p.accumulated, stmts...)
-		return false
+		return valueResult(printNode(node, optArg(args, 1)))
+	})
+}
+
+// jsFormat(src, opts) parses and prints a script in one go.
+func jsFormat(this js.Value, args []js.Value) any {
+	return catch(func() js.Value {
+		opts := optArg(args, 1)
+		return valueResult(printNode(parseFile(args[0].String(), opts), opts))
+	})
+}
+
+// jsRun(src, opts, resolve) runs a script in a new goroutine, as it may need to
+// wait for promises returned by the exec handler, and calls resolve with the
+// result once it's done.
+func jsRun(this js.Value, args []js.Value) any {
+	return catch(func() js.Value {
+		opts := optArg(args, 1)
+		f := parseFile(args[0].String(), opts)
+		resolve := args[2]
+		go func() {
+			var stdout, stderr bytes.Buffer
+			status, err := runFile(f, opts, &stdout, &stderr)
+			result := js.Global().Get("Object").New()
+			if err != nil {
+				result.Set("error", jsError(err))
+			} else {
+				value := js.Global().Get("Object").New()
+				value.Set("status", status)
+				value.Set("stdout", stdout.String())
+				value.Set("stderr", stderr.String())
+				result.Set("value", value)
+			}
+			resolve.Invoke(result)
+		}()
+		return valueResult(js.Undefined())
+	})
+}
+
+func runFile(f *syntax.File, opts js.Value, stdout, stderr io.Writer) (int, error) {
+	var env []string
+	if opts.Type() == js.TypeObject {
+		if jenv := opts.Get("env"); jenv.Type() == js.TypeObject {
+			keys := js.Global().Get("Object").Call("keys", jenv)
+			for i := range keys.Length() {
+				key := keys.Index(i).String()
+				env = append(env, key+"="+jenv.Get(key).String())
+			}
+		}
+	}
+	runOpts := []interp.RunnerOption{
+		interp.Env(expand.ListEnviron(env...)),
+		interp.StdIO(strings.NewReader(optString(opts, "stdin")), stdout, stderr),
+		interp.ExecHandlers(func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+			return execHandler(opts)
+		}),
+	}
+	// Don't use [interp.Dir], as there may not be a filesystem at all.
+	runOpts = append(runOpts, func(r *interp.Runner) error {
+		r.Dir = cmp.Or(optString(opts, "dir"), "/")
+		return nil
 	})
-	if syntax.IsIncomplete(err) {
-		// starting or continuing an incomplete statement
-		p.incomplete.WriteString(line[lastEnd:])
-		return p.accumulated
+	if opts.Type() == js.TypeObject {
+		if params := opts.Get("params"); params.Type() == js.TypeObject {
+			args := []string{"--"}
+			for i := range params.Length() {
+				args = append(args, params.Index(i).String())
+			}
+			runOpts = append(runOpts, interp.Params(args...))
+		}
 	}
-	// complete; empty both fields and return
-	p.incomplete.Reset()
+	r, err := interp.New(runOpts...)
 	if err != nil {
-		throw(err)
+		return 0, err
+	}
+	err = r.Run(context.Background(), f)
+	if status, ok := interp.IsExitStatus(err); ok {
+		return int(status), nil
 	}
-	acc := p.accumulated
-	p.accumulated = p.accumulated[:0]
-	return acc
+	return 0, err
 }
 
-type jsPrinter struct {
-	syntax.Printer
+// execHandler calls the JS exec handler in opts, if any,
+// waiting for its result if it returns a promise.
+func execHandler(opts js.Value) interp.ExecHandlerFunc {
+	var handler js.Value
+	if opts.Type() == js.TypeObject {
+		handler = opts.Get("exec")
+	}
+	return func(ctx context.Context, args []string) error {
+		hc := interp.HandlerCtx(ctx)
+		if handler.Type() != js.TypeFunction {
+			fmt.Fprintf(hc.Stderr, "%s: command not found\n", args[0])
+			return interp.NewExitStatus(127)
+		}
+		jargs := js.Global().Get("Array").New()
+		for _, arg := range args {
+			jargs.Call("push", arg)
+		}
+		jctx := js.Global().Get("Object").New()
+		jctx.Set("dir", hc.Dir)
+		jenv := js.Global().Get("Object").New()
+		hc.Env.Each(func(name string, vr expand.Variable) bool {
+			if vr.Exported && vr.IsSet() {
+				jenv.Set(name, vr.String())
+			}
+			return true
+		})
+		jctx.Set("env", jenv)
+		var stdin []byte
+		if hc.Stdin != nil {
+			stdin, _ = io.ReadAll(hc.Stdin)
+		}
+		jctx.Set("stdin", string(stdin))
+
+		result, err := await(handler.Invoke(jargs, jctx))
+		if err != nil {
+			return err
+		}
+		if result.Type() != js.TypeObject {
+			return nil
+		}
+		if v := result.Get("stdout"); v.Type() == js.TypeString {
+			io.WriteString(hc.Stdout, v.String())
+		}
+		if v := result.Get("stderr"); v.Type() == js.TypeString {
+			io.WriteString(hc.Stderr, v.String())
+		}
+		if v := result.Get("status"); v.Type() == js.TypeNumber && v.Int() != 0 {
+			return interp.NewExitStatus(uint8(v.Int()))
+		}
+		return nil
+	}
 }
 
-func (p jsPrinter) Print(file *syntax.File) string {
-	var buf bytes.Buffer
-	if err := p.Printer.Print(&buf, file); err != nil {
-		throw(err)
+// await waits for a value to resolve if it is a promise.
+func await(v js.Value) (js.Value, error) {
+	if v.Type() != js.TypeObject || v.Get("then").Type() != js.TypeFunction {
+		return v, nil
 	}
-	return buf.String()
+	type result struct {
+		value js.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	onResolve := js.FuncOf(func(this js.Value, args []js.Value) any {
+		done <- result{value: optArg(args, 0)}
+		return nil
+	})
+	defer onResolve.Release()
+	onReject := js.FuncOf(func(this js.Value, args []js.Value) any {
+		reason := optArg(args, 0)
+		msg := reason.String()
+		if reason.Type() == js.TypeObject {
+			msg = reason.Get("message").String()
+		}
+		done <- result{err: errors.New(msg)}
+		return nil
+	})
+	defer onReject.Release()
+	v.Call("then", onResolve, onReject)
+	res := <-done
+	return res.value, res.err
 }
@@ -6,10 +6,11 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"golang.org/x/term"
@@ -18,11 +19,41 @@ import (
 	"mvdan.cc/sh/v3/syntax"
 )
 
-var command = flag.String("c", "", "command to be executed")
+const usage = `usage: gosh [long option...] [option...] [file [arg...]]
+       gosh [long option...] [option...] -c command [name [arg...]]
+       gosh [long option...] [option...] -s [arg...]
+
+Options are those accepted by the "set" builtin, such as -e, -u, -x,
+or -o pipefail, and they can be turned off with a plus sign like +e.
+The -l option makes gosh act as a login shell.
+
+Long options:
+  --login        same as -l
+  --rcfile file  read the given file instead of ~/.goshrc
+  --norc         do not read ~/.goshrc in interactive shells
+
+Login shells read /etc/profile and then the first of ~/.gosh_profile and
+~/.profile which exists. Interactive shells which are not login shells
+read ~/.goshrc instead.
+`
 
 func main() {
-	flag.Parse()
-	err := runAll()
+	f, err := parseFlags(os.Args[1:])
+	if strings.HasPrefix(filepath.Base(os.Args[0]), "-") {
+		// Like other shells, a leading dash in the program name
+		// is how login(1) starts a login shell.
+		f.login = true
+	}
+	var r *interp.Runner
+	if err == nil {
+		r, err = newRunner(f, os.Stdin, os.Stdout, os.Stderr)
+	}
+	if err != nil {
+		// Like Bash, invalid arguments are a usage error.
+		fmt.Fprintf(os.Stderr, "gosh: %v\n%s", err, usage)
+		os.Exit(2)
+	}
+	err = runAll(r, f)
 	if e, ok := interp.IsExitStatus(err); ok {
 		os.Exit(int(e))
 	}
@@ -32,27 +63,115 @@ func main() {
 	}
 }
 
-func runAll() error {
-	r, err := interp.New(interp.Interactive(true), interp.StdIO(os.Stdin, os.Stdout, os.Stderr))
-	if err != nil {
-		return err
-	}
+// flags holds the parsed command-line arguments, which follow Bash's.
+type flags struct {
+	command bool     // -c: read the commands from the first argument
+	stdin   bool     // -s: read the commands from standard input
+	opts    []string // shell options as accepted by [interp.Params]
+	script  string   // the script file, or the commands given with -c
+	name    string   // the name used as $0, if any
+	params  []string // the positional parameters
 
-	if *command != "" {
-		return run(r, strings.NewReader(*command), "")
+	login  bool   // -l, --login: act as a login shell
+	rcfile string // --rcfile: the rc file to read instead of ~/.goshrc
+	norc   bool   // --norc: do not read an rc file
+}
+
+func parseFlags(args []string) (flags, error) {
+	var f flags
+	// Like in Bash, long options must come first.
+	for len(args) > 0 && len(args[0]) > 2 && strings.HasPrefix(args[0], "--") {
+		arg := args[0]
+		args = args[1:]
+		switch arg {
+		case "--login":
+			f.login = true
+		case "--norc":
+			f.norc = true
+		case "--rcfile":
+			if len(args) == 0 {
+				return f, fmt.Errorf("%s: option requires an argument", arg)
+			}
+			f.rcfile, args = args[0], args[1:]
+		default:
+			return f, fmt.Errorf("%s: invalid option", arg)
+		}
+	}
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" || arg == "-" {
+			args = args[1:]
+			break
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		args = args[1:]
+		for _, c := range arg[1:] {
+			flag := arg[:1] + string(c)
+			switch flag {
+			case "-c":
+				f.command = true
+			case "-s":
+				f.stdin = true
+			case "-l":
+				f.login = true
+			case "-o", "+o":
+				if len(args) == 0 {
+					return f, fmt.Errorf("%s: option requires an argument", flag)
+				}
+				f.opts = append(f.opts, flag, args[0])
+				args = args[1:]
+			default:
+				// Let [interp.Params] reject unknown options.
+				f.opts = append(f.opts, flag)
+			}
+		}
 	}
-	if flag.NArg() == 0 {
-		if term.IsTerminal(int(os.Stdin.Fd())) {
-			return runInteractive(r, os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case f.command:
+		if len(args) == 0 {
+			return f, fmt.Errorf("-c: option requires an argument")
 		}
-		return run(r, os.Stdin, "")
+		f.script, args = args[0], args[1:]
+		if len(args) > 0 {
+			f.name, args = args[0], args[1:]
+		}
+	case f.stdin || len(args) == 0:
+	default:
+		f.script, args = args[0], args[1:]
+		f.name = f.script
 	}
-	for _, path := range flag.Args() {
-		if err := runPath(r, path); err != nil {
+	f.params = args
+	return f, nil
+}
+
+func newRunner(f flags, stdin io.Reader, stdout, stderr io.Writer) (*interp.Runner, error) {
+	return interp.New(
+		interp.Interactive(true),
+		interp.StdIO(stdin, stdout, stderr),
+		interp.Params(slices.Concat(f.opts, []string{"--"}, f.params)...),
+		interp.LoginShell(f.login),
+	)
+}
+
+func runAll(r *interp.Runner, f flags) error {
+	interactive := !f.command && f.script == "" && term.IsTerminal(int(os.Stdin.Fd()))
+	home, _ := os.UserHomeDir()
+	for _, path := range startupFiles(f, interactive, home) {
+		if err := source(r, path); r.Exited() {
 			return err
 		}
 	}
-	return nil
+	switch {
+	case f.command:
+		return run(r, strings.NewReader(f.script), f.name)
+	case f.script != "":
+		return runPath(r, f.script)
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		return runInteractive(r, os.Stdin, os.Stdout, os.Stderr)
+	}
+	return run(r, os.Stdin, "")
 }
 
 func run(r *interp.Runner, reader io.Reader, name string) error {
@@ -60,11 +179,56 @@ func run(r *interp.Runner, reader io.Reader, name string) error {
 	if err != nil {
 		return err
 	}
-	r.Reset()
 	ctx := context.Background()
 	return r.Run(ctx, prog)
 }
 
+// startupFiles returns the files to read before running any commands,
+// following what Bash does with its profile and rc files.
+func startupFiles(f flags, interactive bool, home string) []string {
+	if f.login {
+		files := []string{"/etc/profile"}
+		if home != "" {
+			for _, name := range []string{".gosh_profile", ".profile"} {
+				path := filepath.Join(home, name)
+				if _, err := os.Stat(path); err == nil {
+					files = append(files, path)
+					break
+				}
+			}
+		}
+		return files
+	}
+	if !interactive || f.norc {
+		return nil
+	}
+	if f.rcfile != "" {
+		return []string{f.rcfile}
+	}
+	if home != "" {
+		return []string{filepath.Join(home, ".goshrc")}
+	}
+	return nil
+}
+
+// source runs a startup file via the "source" builtin, so that the shell is
+// not exited once the file is done. Missing files are ignored.
+func source(r *interp.Runner, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	quoted, err := syntax.Quote(path, syntax.LangBash)
+	if err != nil {
+		return err
+	}
+	prog, err := syntax.NewParser().Parse(strings.NewReader("source "+quoted), "")
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	return r.Run(ctx, prog.Stmts[0])
+}
+
 func runPath(r *interp.Runner, path string) error {
 	f, err := os.Open(path)
 	if err != nil {
@@ -75,14 +239,17 @@ func runPath(r *interp.Runner, path string) error {
 }
 
 func runInteractive(r *interp.Runner, stdin io.Reader, stdout, stderr io.Writer) error {
+	ignoreStop()
 	parser := syntax.NewParser()
 	fmt.Fprintf(stdout, "$ ")
 	var runErr error
+	eofs := 0
 	fn := func(stmts []*syntax.Stmt) bool {
 		if parser.Incomplete() {
 			fmt.Fprintf(stdout, "> ")
 			return true
 		}
+		eofs = 0
 		ctx := context.Background()
 		for _, stmt := range stmts {
 			runErr = r.Run(ctx, stmt)
@@ -93,8 +260,18 @@ func runInteractive(r *interp.Runner, stdin io.Reader, stdout, stderr io.Writer)
 		fmt.Fprintf(stdout, "$ ")
 		return true
 	}
-	if err := parser.Interactive(stdin, fn); err != nil {
-		return err
+	for {
+		if err := parser.Interactive(stdin, fn); err != nil {
+			return err
+		}
+		// Like Bash, "set -o ignoreeof" or IGNOREEOF make us ignore
+		// a number of consecutive end-of-file conditions.
+		if r.Exited() || eofs >= r.IgnoreEOF() {
+			break
+		}
+		eofs++
+		fmt.Fprintf(stderr, "Use \"exit\" to leave the shell.\n")
+		fmt.Fprintf(stdout, "$ ")
 	}
 	return runErr
 }
@@ -7,9 +7,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/go-quicktest/qt"
+	"github.com/google/go-cmp/cmp"
 	"mvdan.cc/sh/v3/interp"
 )
 
@@ -107,7 +111,7 @@ var interactiveTests = []struct {
 		pairs: []string{
 			"echo foo |\n",
 			"> ",
-			"read var; echo $var\n",
+			"while read var; do echo $var; done\n",
 			"foo\n",
 		},
 	},
@@ -144,11 +148,11 @@ var interactiveTests = []struct {
 	{
 		pairs: []string{
 			"echo *; :\n",
-			"main.go main_test.go\n$ ",
+			"main.go main_test.go signal_notunix.go signal_unix.go\n$ ",
 			"echo *\n",
-			"main.go main_test.go\n$ ",
+			"main.go main_test.go signal_notunix.go signal_unix.go\n$ ",
 			"shopt -s globstar; echo **\n",
-			"main.go main_test.go\n$ ",
+			"main.go main_test.go signal_notunix.go signal_unix.go\n$ ",
 		},
 	},
 	{
@@ -260,6 +264,153 @@ func TestInteractiveExit(t *testing.T) {
 	}
 }
 
+func TestInteractiveIgnoreEOF(t *testing.T) {
+	inReader, inWriter, err := os.Pipe()
+	qt.Assert(t, qt.IsNil(err))
+	defer inReader.Close()
+	go func() {
+		io.WriteString(inWriter, "IGNOREEOF=2\n")
+		inWriter.Close()
+	}()
+	var b strings.Builder
+	runner, _ := interp.New(interp.Interactive(true), interp.StdIO(inReader, &b, &b))
+	if err := runInteractive(runner, inReader, &b, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := "$ $ " + strings.Repeat("Use \"exit\" to leave the shell.\n$ ", 2)
+	qt.Assert(t, qt.Equals(b.String(), want))
+}
+
+var flagsTests = []struct {
+	args    []string
+	want    string
+	wantErr string
+}{
+	{
+		args: []string{"-c", `echo $0 "$@"`, "name", "a", "b"},
+		want: "name a b\n",
+	},
+	{
+		args: []string{"-c", `echo $0 $#`},
+		want: "gosh 0\n",
+	},
+	{
+		args: []string{"-eu", "-o", "pipefail", "-c", `[[ -o errexit && -o nounset && -o pipefail ]] && echo on`},
+		want: "on\n",
+	},
+	{
+		args: []string{"-e", "+e", "-o", "pipefail", "+o", "pipefail", "-c", `[[ -o errexit || -o pipefail ]] || echo off`},
+		want: "off\n",
+	},
+	{
+		args: []string{"-ec", "false; echo unreachable"},
+		want: "",
+	},
+	{
+		args: []string{"-xc", "echo foo"},
+		want: "+ echo foo\nfoo\n",
+	},
+	{
+		args:    []string{"-q"},
+		wantErr: `invalid option: "-q"`,
+	},
+	{
+		args:    []string{"-o", "nope"},
+		wantErr: `invalid option: "nope"`,
+	},
+	{
+		args:    []string{"-o"},
+		wantErr: "-o: option requires an argument",
+	},
+	{
+		args:    []string{"-c"},
+		wantErr: "-c: option requires an argument",
+	},
+	{
+		args:    []string{"--rcfile"},
+		wantErr: "--rcfile: option requires an argument",
+	},
+	{
+		args:    []string{"--foo"},
+		wantErr: "--foo: invalid option",
+	},
+	{
+		args: []string{"-l", "-c", "shopt login_shell"},
+		want: "login_shell\ton\t(\"off\" not supported)\n",
+	},
+}
+
+func TestFlags(t *testing.T) {
+	for _, tc := range flagsTests {
+		t.Run("", func(t *testing.T) {
+			f, err := parseFlags(tc.args)
+			var b strings.Builder
+			var r *interp.Runner
+			if err == nil {
+				r, err = newRunner(f, strings.NewReader(""), &b, &b)
+			}
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.ErrorMatches(err, regexp.QuoteMeta(tc.wantErr)))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			runAll(r, f)
+			qt.Assert(t, qt.Equals(b.String(), tc.want))
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want flags
+	}{
+		{nil, flags{}},
+		{[]string{"file", "-e", "a"}, flags{script: "file", name: "file", params: []string{"-e", "a"}}},
+		{[]string{"-e", "--", "-file", "a"}, flags{opts: []string{"-e"}, script: "-file", name: "-file", params: []string{"a"}}},
+		{[]string{"-", "-file"}, flags{script: "-file", name: "-file", params: []string{}}},
+		{[]string{"-s", "-u", "a", "b"}, flags{stdin: true, opts: []string{"-u"}, params: []string{"a", "b"}}},
+		{[]string{"-sc", "cmd", "a", "b"}, flags{command: true, stdin: true, script: "cmd", name: "a", params: []string{"b"}}},
+		{[]string{"--login", "--norc", "-l"}, flags{login: true, norc: true, params: []string{}}},
+		{[]string{"--rcfile", "rc", "file", "--login"}, flags{rcfile: "rc", script: "file", name: "file", params: []string{"--login"}}},
+	}
+	for _, test := range tests {
+		got, err := parseFlags(test.args)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.CmpEquals(got, test.want, cmp.AllowUnexported(flags{})))
+	}
+}
+
+func TestStartupFiles(t *testing.T) {
+	home := t.TempDir()
+	profile := filepath.Join(home, ".profile")
+	goshProfile := filepath.Join(home, ".gosh_profile")
+	goshrc := filepath.Join(home, ".goshrc")
+
+	login := flags{login: true}
+	qt.Assert(t, qt.DeepEquals(startupFiles(login, true, home), []string{"/etc/profile"}))
+	err := os.WriteFile(profile, []byte("PROFILE=profile\n"), 0o666)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(startupFiles(login, false, home), []string{"/etc/profile", profile}))
+	err = os.WriteFile(goshProfile, []byte("PROFILE=gosh_profile\n"), 0o666)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(startupFiles(login, false, home), []string{"/etc/profile", goshProfile}))
+
+	qt.Assert(t, qt.DeepEquals(startupFiles(flags{}, true, home), []string{goshrc}))
+	qt.Assert(t, qt.DeepEquals(startupFiles(flags{rcfile: "rc"}, true, home), []string{"rc"}))
+	qt.Assert(t, qt.HasLen(startupFiles(flags{norc: true}, true, home), 0))
+	qt.Assert(t, qt.HasLen(startupFiles(flags{}, false, home), 0))
+
+	// Sourcing startup files keeps their state, and missing files are ignored.
+	var b strings.Builder
+	r, err := newRunner(flags{}, nil, &b, &b)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.IsNil(source(r, goshrc)))
+	qt.Assert(t, qt.IsNil(source(r, goshProfile)))
+	qt.Assert(t, qt.IsNil(runAll(r, flags{command: true, script: "echo $PROFILE"})))
+	qt.Assert(t, qt.Equals(b.String(), "gosh_profile\n"))
+}
+
 // readString will keep reading from a reader until all bytes from the supplied
 // string are read.
 func readString(r io.Reader, want string) error {
@@ -0,0 +1,9 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build !unix
+
+package main
+
+// ignoreStop is a no-op, as there is no SIGTSTP.
+func ignoreStop() {}
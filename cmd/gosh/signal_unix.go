@@ -0,0 +1,19 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ignoreStop keeps the shell from being stopped by SIGTSTP, such as via
+// Ctrl-Z, so that only the foreground process is stopped. Unlike with
+// [signal.Ignore], child processes still get the default behavior.
+func ignoreStop() {
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP)
+}
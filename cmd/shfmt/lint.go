@@ -0,0 +1,139 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// lintFinding is a problem reported by one of the rules in --lint.
+type lintFinding struct {
+	pos  syntax.Pos
+	rule string
+	msg  string
+}
+
+// lintFile runs all the --lint rules on a file,
+// returning their findings sorted by position.
+func lintFile(file *syntax.File) []lintFinding {
+	var findings []lintFinding
+	report := func(pos syntax.Pos, rule, msg string) {
+		findings = append(findings, lintFinding{pos, rule, msg})
+	}
+
+	// Statements whose failure is already handled,
+	// such as "cd dir || exit" or "if cd dir; then".
+	checked := make(map[*syntax.Stmt]bool)
+	checkStmts := func(stmts []*syntax.Stmt) {
+		for _, stmt := range stmts {
+			checked[stmt] = true
+		}
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.BinaryCmd:
+			if node.Op == syntax.AndStmt || node.Op == syntax.OrStmt {
+				checked[node.X] = true
+			}
+		case *syntax.IfClause:
+			checkStmts(node.Cond)
+		case *syntax.WhileClause:
+			checkStmts(node.Cond)
+		}
+		return true
+	})
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Stmt:
+			if callName(node.Cmd) == "cd" && !node.Negated && !checked[node] {
+				report(node.Pos(), "cd-unchecked", `cd may fail; use "cd dir || exit" or similar`)
+			}
+		case *syntax.CallExpr:
+			if len(node.Args) == 0 {
+				break
+			}
+			if callName(node) == "read" && !hasFlag(node.Args[1:], 'r') {
+				report(node.Pos(), "read-without-r", "read without -r mangles backslashes")
+			}
+			for _, arg := range node.Args[1:] {
+				lintUnquotedArgs(arg, report)
+			}
+		case *syntax.WordIter:
+			for _, item := range node.Items {
+				lintUnquotedArgs(item, report)
+			}
+		case *syntax.BinaryCmd:
+			if node.Op != syntax.Pipe {
+				break
+			}
+			cat, ok := node.X.Cmd.(*syntax.CallExpr)
+			if ok && callName(cat) == "cat" && len(cat.Args) == 2 &&
+				len(node.X.Redirs) == 0 && !strings.HasPrefix(cat.Args[1].Lit(), "-") {
+				report(node.X.Pos(), "useless-cat", `useless cat; use a "<file" redirect instead`)
+			}
+		case *syntax.CmdSubst:
+			if node.Backquotes {
+				report(node.Pos(), "backticks", "use $(...) instead of legacy backticks")
+			}
+		}
+		return true
+	})
+	slices.SortStableFunc(findings, func(a, b lintFinding) int {
+		return cmp.Compare(a.pos.Offset(), b.pos.Offset())
+	})
+	return findings
+}
+
+// callName returns the literal command name of a simple command,
+// or an empty string if there is none.
+func callName(cmd syntax.Command) string {
+	call, ok := cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return ""
+	}
+	return call.Args[0].Lit()
+}
+
+// hasFlag reports whether any of the literal flags in args contains the
+// given flag character, such as 'r' in "read -rp prompt".
+func hasFlag(args []*syntax.Word, flag byte) bool {
+	for _, arg := range args {
+		lit := arg.Lit()
+		if lit == "--" {
+			break
+		}
+		if len(lit) > 1 && lit[0] == '-' && strings.IndexByte(lit[1:], flag) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lintUnquotedArgs reports unquoted expansions of all the positional
+// parameters or all the elements of an array, like $@ or ${arr[@]},
+// which are split into words and globbed.
+func lintUnquotedArgs(word *syntax.Word, report func(syntax.Pos, string, string)) {
+	for _, part := range word.Parts {
+		pe, ok := part.(*syntax.ParamExp)
+		if !ok || pe.Excl || pe.Length || pe.Width {
+			continue
+		}
+		name := "$" + pe.Param.Value
+		switch pe.Param.Value {
+		case "@", "*":
+		default:
+			idx, ok := pe.Index.(*syntax.Word)
+			if !ok || (idx.Lit() != "@" && idx.Lit() != "*") {
+				continue
+			}
+			name = "${" + pe.Param.Value + "[" + idx.Lit() + "]}"
+		}
+		report(pe.Pos(), "unquoted-args", "unquoted "+name+" is split and globbed; use \""+name+"\"")
+	}
+}
@@ -65,8 +65,10 @@ var (
 	keepPadding = &multiFlag[bool]{"kp", "keep-padding", false}
 	funcNext    = &multiFlag[bool]{"fn", "func-next-line", false}
 
-	toJSON   = &multiFlag[bool]{"tojson", "to-json", false} // TODO(v4): remove "tojson" for consistency
-	fromJSON = &multiFlag[bool]{"", "from-json", false}
+	toJSON     = &multiFlag[bool]{"tojson", "to-json", false} // TODO(v4): remove "tojson" for consistency
+	fromJSON   = &multiFlag[bool]{"", "from-json", false}
+	checkPOSIX = &multiFlag[bool]{"", "check-posix", false}
+	lint       = &multiFlag[boolString]{"", "lint", "false"}
 
 	// useEditorConfig will be false if any parser or printer flags were used.
 	useEditorConfig = true
@@ -84,6 +86,7 @@ var (
 		versionFlag, list, write, simplify, minify, find, diff, applyIgnore,
 		lang, posix, filename, expRecover,
 		indent, binNext, caseIndent, spaceRedirs, keepPadding, funcNext, toJSON, fromJSON,
+		checkPOSIX, lint,
 	}
 )
 
@@ -180,6 +183,9 @@ Utilities:
                       paths are separated by a newline or a null character if -f=0
   --to-json           print syntax tree to stdout as a typed JSON
   --from-json         read syntax tree from stdin as a typed JSON
+  --check-posix       report the bash or mksh features used in POSIX shell scripts
+  --lint[=error]      report common mistakes like unquoted $@ or read without -r;
+                      only fail when findings are reported if --lint=error
 
 Formatting options can also be read from EditorConfig files; see 'man shfmt'
 for a detailed description of the tool's behavior.
@@ -212,6 +218,18 @@ For more information and to report bugs, see https://github.com/mvdan/sh.
 		fmt.Fprintf(os.Stderr, "only -f and -f=0 allowed\n")
 		os.Exit(1)
 	}
+	if checkPOSIX.val && (write.val || diff.val || list.val != "false" || toJSON.val || fromJSON.val) {
+		fmt.Fprintf(os.Stderr, "--check-posix cannot be used with -w, -d, -l, --to-json, or --from-json\n")
+		os.Exit(1)
+	}
+	if lint.val != "true" && lint.val != "false" && lint.val != "error" {
+		fmt.Fprintf(os.Stderr, "only --lint and --lint=error allowed\n")
+		os.Exit(1)
+	}
+	if lint.val != "false" && (write.val || diff.val || list.val != "false" || toJSON.val || fromJSON.val || checkPOSIX.val) {
+		fmt.Fprintf(os.Stderr, "--lint cannot be used with -w, -d, -l, --to-json, --from-json, or --check-posix\n")
+		os.Exit(1)
+	}
 	if minify.val {
 		simplify.val = true
 	}
@@ -264,7 +282,7 @@ For more information and to report bugs, see https://github.com/mvdan/sh.
 			name = filename.val
 		}
 		if err := formatStdin(name); err != nil {
-			if err != errChangedWithDiff {
+			if err != errChangedWithDiff && err != errLintFindings {
 				fmt.Fprintln(os.Stderr, err)
 			}
 			os.Exit(1)
@@ -288,7 +306,7 @@ For more information and to report bugs, see https://github.com/mvdan/sh.
 			// One exception is --apply-ignore, which explicitly changes this behavior.
 			// Another is --find, whose logic depends on walkPath being called.
 			if err := formatPath(path, false); err != nil {
-				if err != errChangedWithDiff {
+				if err != errChangedWithDiff && err != errLintFindings {
 					fmt.Fprintln(os.Stderr, err)
 				}
 				status = 1
@@ -303,7 +321,7 @@ For more information and to report bugs, see https://github.com/mvdan/sh.
 			case nil:
 			case filepath.SkipDir:
 				return err
-			case errChangedWithDiff:
+			case errChangedWithDiff, errLintFindings:
 				status = 1
 			default:
 				fmt.Fprintln(os.Stderr, err)
@@ -320,6 +338,10 @@ For more information and to report bugs, see https://github.com/mvdan/sh.
 
 var errChangedWithDiff = fmt.Errorf("")
 
+// errLintFindings is returned when --lint=error reported any findings,
+// which are printed as they are found.
+var errLintFindings = fmt.Errorf("")
+
 func formatStdin(name string) error {
 	if write.val {
 		return fmt.Errorf("-w cannot be used on standard input")
@@ -498,6 +520,12 @@ func formatBytes(src []byte, path string, fileLang syntax.LangVariant) error {
 	} else {
 		syntax.Variant(fileLang)(parser)
 	}
+	if checkPOSIX.val {
+		return checkPOSIXBytes(src, path, fileLang)
+	}
+	if lint.val != "false" {
+		return lintBytes(src, path)
+	}
 	var node syntax.Node
 	var err error
 	if fromJSON.val {
@@ -527,7 +555,12 @@ func formatBytes(src []byte, path string, fileLang syntax.LangVariant) error {
 		return typedjson.EncodeOptions{Indent: "\t"}.Encode(os.Stdout, node)
 	}
 	writeBuf.Reset()
-	printer.Print(&writeBuf, node)
+	if fromJSON.val {
+		printer.Print(&writeBuf, node)
+	} else {
+		// Use the original source to honor directives like "# shfmt: off".
+		printer.PrintSource(&writeBuf, node, src)
+	}
 	res := writeBuf.Bytes()
 	if !bytes.Equal(src, res) {
 		switch list.val {
@@ -585,6 +618,52 @@ func formatBytes(src []byte, path string, fileLang syntax.LangVariant) error {
 	return nil
 }
 
+// checkPOSIXBytes reports the features used by a POSIX shell script which
+// are not part of POSIX, such as arrays. Other scripts are skipped.
+func checkPOSIXBytes(src []byte, path string, fileLang syntax.LangVariant) error {
+	if fileLang != syntax.LangPOSIX {
+		return nil
+	}
+	// Parse as bash, which supports most features, so that we can report
+	// all of them rather than stopping at the first parse error.
+	// mksh is a fallback for its own features, like "${ stmts;}".
+	syntax.Variant(syntax.LangBash)(parser)
+	file, err := parser.Parse(bytes.NewReader(src), path)
+	if err != nil {
+		syntax.Variant(syntax.LangMirBSDKorn)(parser)
+		var err2 error
+		if file, err2 = parser.Parse(bytes.NewReader(src), path); err2 != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, feature := range syntax.Features(file) {
+		errs = append(errs, syntax.LangError{
+			Filename: path,
+			Pos:      feature.Pos,
+			Feature:  feature.Name,
+			Langs:    feature.Langs,
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// lintBytes reports the findings of the --lint rules on a shell script.
+func lintBytes(src []byte, path string) error {
+	file, err := parser.Parse(bytes.NewReader(src), path)
+	if err != nil {
+		return err
+	}
+	findings := lintFile(file)
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "%s:%s: %s (%s)\n", path, f.pos, f.msg, f.rule)
+	}
+	if len(findings) > 0 && lint.val == "error" {
+		return errLintFindings
+	}
+	return nil
+}
+
 const (
 	terminalGreen = "\u001b[32m"
 	terminalRed   = "\u001b[31m"
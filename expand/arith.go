@@ -5,6 +5,8 @@ package expand
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -37,15 +39,22 @@ func Arithm(cfg *Config, expr syntax.ArithmExpr) (int, error) {
 	case *syntax.UnaryArithm:
 		switch expr.Op {
 		case syntax.Inc, syntax.Dec:
-			name := expr.X.(*syntax.Word).Lit()
-			old := atoi(cfg.envGet(name))
+			ref, err := cfg.arithmRef(expr.X.(*syntax.Word))
+			if err != nil {
+				return 0, err
+			}
+			str, err := cfg.arithmGet(ref)
+			if err != nil {
+				return 0, err
+			}
+			old := atoi(str)
 			val := old
 			if expr.Op == syntax.Inc {
 				val++
 			} else {
 				val--
 			}
-			if err := cfg.envSet(name, strconv.Itoa(val)); err != nil {
+			if err := cfg.arithmSet(ref, strconv.Itoa(val)); err != nil {
 				return 0, err
 			}
 			if expr.Post {
@@ -80,7 +89,7 @@ func Arithm(cfg *Config, expr syntax.ArithmExpr) (int, error) {
 				return 0, err
 			}
 			b2 := expr.Y.(*syntax.BinaryArithm) // must have Op==TernColon
-			if cond == 1 {
+			if cond != 0 {
 				return Arithm(cfg, b2.X)
 			}
 			return Arithm(cfg, b2.Y)
@@ -89,6 +98,14 @@ func Arithm(cfg *Config, expr syntax.ArithmExpr) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		// Like in Bash, the right side is not evaluated when it cannot
+		// change the result, which matters for side effects like "i++".
+		switch {
+		case expr.Op == syntax.AndArit && left == 0:
+			return 0, nil
+		case expr.Op == syntax.OrArit && left != 0:
+			return 1, nil
+		}
 		right, err := Arithm(cfg, expr.Y)
 		if err != nil {
 			return 0, err
@@ -114,15 +131,37 @@ func atoi(s string) int {
 }
 
 func (cfg *Config) assgnArit(b *syntax.BinaryArithm) (int, error) {
-	name := b.X.(*syntax.Word).Lit()
-	val := atoi(cfg.envGet(name))
+	word := b.X.(*syntax.Word)
+	if b.Op == syntax.Assgn {
+		// Like in Bash, a plain assignment evaluates the value
+		// before the array index, if any.
+		arg, err := Arithm(cfg, b.Y)
+		if err != nil {
+			return 0, err
+		}
+		ref, err := cfg.arithmRef(word)
+		if err != nil {
+			return 0, err
+		}
+		if err := cfg.arithmSet(ref, strconv.Itoa(arg)); err != nil {
+			return 0, err
+		}
+		return arg, nil
+	}
+	ref, err := cfg.arithmRef(word)
+	if err != nil {
+		return 0, err
+	}
+	str, err := cfg.arithmGet(ref)
+	if err != nil {
+		return 0, err
+	}
+	val := atoi(str)
 	arg, err := Arithm(cfg, b.Y)
 	if err != nil {
 		return 0, err
 	}
 	switch b.Op {
-	case syntax.Assgn:
-		val = arg
 	case syntax.AddAssgn:
 		val += arg
 	case syntax.SubAssgn:
@@ -150,12 +189,111 @@ func (cfg *Config) assgnArit(b *syntax.BinaryArithm) (int, error) {
 	case syntax.ShrAssgn:
 		val >>= uint(arg)
 	}
-	if err := cfg.envSet(name, strconv.Itoa(val)); err != nil {
+	if err := cfg.arithmSet(ref, strconv.Itoa(val)); err != nil {
 		return 0, err
 	}
 	return val, nil
 }
 
+// arithmElem returns the parameter expansion for an array element which is
+// the target of an arithmetic assignment, such as "a[i]" in "a[i]++".
+// It returns nil if the target is a plain variable name.
+func arithmElem(word *syntax.Word) *syntax.ParamExp {
+	if len(word.Parts) != 1 {
+		return nil
+	}
+	pe, _ := word.Parts[0].(*syntax.ParamExp)
+	if pe == nil || pe.Index == nil {
+		return nil
+	}
+	return pe
+}
+
+// arithmRef is the target of an arithmetic assignment,
+// which is either a variable name or an array element.
+// Like in Bash, an array index is only evaluated once,
+// even when the element is both read and written, such as in "a[i++] += 2".
+type arithmRef struct {
+	name string
+	elem bool
+	key  string // for associative arrays
+	idx  int    // for indexed arrays
+}
+
+func (cfg *Config) arithmRef(word *syntax.Word) (arithmRef, error) {
+	pe := arithmElem(word)
+	if pe == nil {
+		return arithmRef{name: word.Lit()}, nil
+	}
+	ref := arithmRef{name: pe.Param.Value, elem: true}
+	vr := cfg.Env.Get(ref.name)
+	if name2, vr2 := vr.Resolve(cfg.Env); name2 != "" {
+		ref.name, vr = name2, vr2
+	}
+	var err error
+	if vr.Kind == Associative {
+		ref.key, err = Literal(cfg, pe.Index.(*syntax.Word))
+	} else {
+		ref.idx, err = Arithm(cfg, pe.Index)
+	}
+	return ref, err
+}
+
+// arithmGet returns the value of the target of an arithmetic assignment.
+func (cfg *Config) arithmGet(ref arithmRef) (string, error) {
+	if !ref.elem {
+		return cfg.envGet(ref.name), nil
+	}
+	vr := cfg.Env.Get(ref.name)
+	switch vr.Kind {
+	case String:
+		if ref.idx == 0 {
+			return vr.Str, nil
+		}
+	case Indexed:
+		i, ok := vr.ArrayIndex(ref.idx)
+		if !ok {
+			return "", fmt.Errorf("bad array subscript")
+		}
+		str, _ := vr.ArrayElem(i)
+		return str, nil
+	case Associative:
+		return vr.Map[ref.key], nil
+	}
+	return "", nil
+}
+
+// arithmSet sets the target of an arithmetic assignment.
+func (cfg *Config) arithmSet(ref arithmRef, value string) error {
+	if !ref.elem {
+		return cfg.envSet(ref.name, value)
+	}
+	wenv, ok := cfg.Env.(WriteEnviron)
+	if !ok {
+		return fmt.Errorf("environment is read-only")
+	}
+	vr := cfg.Env.Get(ref.name)
+	switch vr.Kind {
+	case Associative:
+		vr.Map = maps.Clone(vr.Map)
+		vr.Map[ref.key] = value
+		return wenv.Set(ref.name, vr)
+	case String:
+		vr.List = []string{vr.Str}
+		vr.Str = ""
+	case Indexed:
+		vr.List = slices.Clone(vr.List)
+		vr.Indices = slices.Clone(vr.Indices)
+	}
+	vr.Kind = Indexed
+	i, ok := vr.ArrayIndex(ref.idx)
+	if !ok {
+		return fmt.Errorf("%s[%d]: bad array subscript", ref.name, ref.idx)
+	}
+	vr.SetArrayElem(i, value)
+	return wenv.Set(ref.name, vr)
+}
+
 func intPow(a, b int) int {
 	p := 1
 	for b > 0 {
@@ -66,6 +66,11 @@ const (
 // If a variable is set, its Value field will be a []string if it is an indexed
 // array, a map[string]string if it's an associative array, or a string
 // otherwise.
+//
+// Indexed arrays may be sparse, such as after "a[1000]=x". In that case,
+// Indices holds the index of each element in List, in increasing order.
+// A nil Indices means that the elements in List have the indices 0, 1, 2,
+// and so on, which is the common case.
 type Variable struct {
 	Local    bool
 	Exported bool
@@ -73,9 +78,10 @@ type Variable struct {
 
 	Kind ValueKind
 
-	Str  string            // Used when Kind is String or NameRef.
-	List []string          // Used when Kind is Indexed.
-	Map  map[string]string // Used when Kind is Associative.
+	Str     string            // Used when Kind is String or NameRef.
+	List    []string          // Used when Kind is Indexed.
+	Indices []int             // Used when Kind is Indexed and List is sparse.
+	Map     map[string]string // Used when Kind is Associative.
 }
 
 // IsSet returns whether the variable is set. An empty variable is set, but an
@@ -91,15 +97,82 @@ func (v Variable) String() string {
 	case String:
 		return v.Str
 	case Indexed:
-		if len(v.List) > 0 {
-			return v.List[0]
-		}
+		s, _ := v.ArrayElem(0)
+		return s
 	case Associative:
 		// nothing to do
 	}
 	return ""
 }
 
+// ArrayIndices returns the indices of the elements of an indexed array,
+// in increasing order.
+func (v Variable) ArrayIndices() []int {
+	if v.Indices != nil {
+		return v.Indices
+	}
+	indices := make([]int, len(v.List))
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// ArrayIndex resolves an index into an indexed array. Like in Bash, negative
+// indices count back from one past the highest index, so -1 refers to the
+// last element. It reports false if a negative index is out of range.
+func (v Variable) ArrayIndex(i int) (int, bool) {
+	if i >= 0 {
+		return i, true
+	}
+	end := len(v.List)
+	if n := len(v.Indices); n > 0 {
+		end = v.Indices[n-1] + 1
+	}
+	i += end
+	return i, i >= 0
+}
+
+// ArrayElem returns the element at a non-negative index of an indexed array,
+// and whether the element is set.
+func (v Variable) ArrayElem(i int) (string, bool) {
+	if v.Indices == nil {
+		if 0 <= i && i < len(v.List) {
+			return v.List[i], true
+		}
+		return "", false
+	}
+	if pos, ok := slices.BinarySearch(v.Indices, i); ok {
+		return v.List[pos], true
+	}
+	return "", false
+}
+
+// SetArrayElem sets the element at a non-negative index of an indexed array,
+// modifying its List and Indices in place, so the caller must clone them first
+// if they are shared. An index past the end makes the array sparse, so that
+// "a[1000000]=x" does not allocate a million elements.
+func (v *Variable) SetArrayElem(i int, s string) {
+	if v.Indices == nil {
+		switch {
+		case i < len(v.List):
+			v.List[i] = s
+			return
+		case i == len(v.List):
+			v.List = append(v.List, s)
+			return
+		}
+		v.Indices = v.ArrayIndices()
+	}
+	pos, found := slices.BinarySearch(v.Indices, i)
+	if found {
+		v.List[pos] = s
+		return
+	}
+	v.Indices = slices.Insert(v.Indices, pos, i)
+	v.List = slices.Insert(v.List, pos, s)
+}
+
 // maxNameRefDepth defines the maximum number of times to follow references when
 // resolving a variable. Otherwise, simple name reference loops could crash a
 // program quite easily.
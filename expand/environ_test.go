@@ -80,3 +80,46 @@ func TestGetWithSameSubPrefix(t *testing.T) {
 		t.Fatalf("ListEnviron.Get(GREETING) wanted text1, got %q", got)
 	}
 }
+
+func TestVariableSparseArray(t *testing.T) {
+	var vr Variable
+	vr.Kind = Indexed
+	vr.SetArrayElem(0, "a")
+	vr.SetArrayElem(1, "b")
+	if vr.Indices != nil {
+		t.Fatalf("dense array should have nil Indices, got %v", vr.Indices)
+	}
+	vr.SetArrayElem(1000000, "z")
+	vr.SetArrayElem(5, "f")
+	vr.SetArrayElem(1, "B")
+	if want := []string{"a", "B", "f", "z"}; !reflect.DeepEqual(vr.List, want) {
+		t.Fatalf("List wanted %q, got %q", want, vr.List)
+	}
+	if want := []int{0, 1, 5, 1000000}; !reflect.DeepEqual(vr.ArrayIndices(), want) {
+		t.Fatalf("ArrayIndices wanted %v, got %v", want, vr.ArrayIndices())
+	}
+	for _, tc := range []struct {
+		index int
+		want  string
+		ok    bool
+	}{
+		{0, "a", true},
+		{5, "f", true},
+		{4, "", false},
+		{-1, "z", true},
+		{-2, "", false},
+		{-1000000, "B", true},
+	} {
+		i, ok := vr.ArrayIndex(tc.index)
+		if !ok {
+			t.Fatalf("ArrayIndex(%d) should be in range", tc.index)
+		}
+		got, ok := vr.ArrayElem(i)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("element %d wanted %q, %t; got %q, %t", tc.index, tc.want, tc.ok, got, ok)
+		}
+	}
+	if _, ok := vr.ArrayIndex(-1000002); ok {
+		t.Fatalf("ArrayIndex(-1000002) should be out of range")
+	}
+}
@@ -45,8 +45,21 @@ type Config struct {
 	// UnexpectedCommandError.
 	CmdSubst func(io.Writer, *syntax.CmdSubst) error
 
+	// CmdSubstNulls is called when the output of a command substitution
+	// contains null bytes, which cannot be part of shell strings in Bash.
+	// It returns the output to use instead, before the trailing newlines
+	// are removed, or an error to stop the expansion.
+	// For example, it can print a warning like Bash,
+	// or return the output unmodified to keep the null bytes like Zsh.
+	//
+	// If nil, the null bytes are silently dropped.
+	CmdSubstNulls func(cs *syntax.CmdSubst, out string) (string, error)
+
 	// ProcSubst expands a process substitution node.
 	//
+	// If nil, encountering a process substitution will result in an
+	// UnexpectedProcessError.
+	//
 	// Note that this feature is a work in progress, and the signature of
 	// this field might change until #451 is completely fixed.
 	ProcSubst func(*syntax.ProcSubst) (string, error)
@@ -79,6 +92,19 @@ type Config struct {
 	// as errors.
 	NoUnset bool
 
+	// NoGlob corresponds to the shell option that disables pathname
+	// expansion, even when [Config.ReadDir2] is set.
+	NoGlob bool
+
+	// NoSplit disables field splitting, so that each unquoted expansion
+	// is kept as part of a single field, like in Zsh. Unquoted expansions
+	// which are empty still result in no field.
+	NoSplit bool
+
+	// NoUserLookup disables the use of os/user in tilde expansions like
+	// "~name", so that only the "HOME name" variable in Env is used.
+	NoUserLookup bool
+
 	bufferAlloc strings.Builder
 	fieldAlloc  [4]fieldPart
 	fieldsAlloc [4][]fieldPart
@@ -99,6 +125,33 @@ func (u UnexpectedCommandError) Error() string {
 	return fmt.Sprintf("unexpected command substitution at %s", u.Node.Pos())
 }
 
+// UnexpectedProcessError is returned if a process substitution is encountered
+// when [Config.ProcSubst] is nil.
+type UnexpectedProcessError struct {
+	Node *syntax.ProcSubst
+}
+
+func (u UnexpectedProcessError) Error() string {
+	return fmt.Sprintf("unexpected process substitution at %s", u.Node.Pos())
+}
+
+// SafeConfig returns a configuration to expand untrusted words with,
+// such as when templating values into configuration files.
+// Expansions only use the given environment; they never run commands,
+// nor access the filesystem or the system's user database.
+//
+// Command and process substitutions result in [UnexpectedCommandError]
+// and [UnexpectedProcessError] respectively, pathname expansion and field
+// splitting are disabled, and "~name" is only expanded via "HOME name".
+func SafeConfig(env Environ) *Config {
+	return &Config{
+		Env:          env,
+		NoGlob:       true,
+		NoSplit:      true,
+		NoUserLookup: true,
+	}
+}
+
 var zeroConfig = &Config{}
 
 // TODO: note that prepareConfig is modifying the user's config in place,
@@ -138,6 +191,17 @@ func (cfg *Config) ifsRune(r rune) bool {
 	return false
 }
 
+// ifsSpace reports whether an IFS character is IFS whitespace.
+//
+// Field splitting follows POSIX: leading and trailing IFS whitespace is ignored,
+// and a sequence of IFS whitespace delimits a field. Each other IFS character
+// also delimits a field, along with any adjacent IFS whitespace, so that two
+// of them in a row result in an empty field. A trailing delimiter does not
+// result in an empty field.
+func ifsSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n'
+}
+
 func (cfg *Config) ifsJoin(strs []string) string {
 	sep := ""
 	if cfg.ifs != "" {
@@ -232,6 +296,8 @@ func Pattern(cfg *Config, word *syntax.Word) (string, error) {
 // shell's format specifications. These include printf(1), among others.
 //
 // The resulting string is returned, along with the number of arguments used.
+// Positional specifiers like "%2$s" use a particular argument; in that case,
+// the number of arguments used is at least the highest position.
 //
 // The config specifies shell expansion options; nil behaves the same as an
 // empty config.
@@ -249,15 +315,33 @@ func Format(cfg *Config, format string, args []string) (string, int, error) {
 
 func formatInto(sb *strings.Builder, format string, args []string) (int, error) {
 	var fmts []byte
+	allArgs := args
 	initialArgs := len(args)
 
+	// argPos is set by a positional specifier like "%2$s",
+	// and maxPos is the highest one used.
+	argPos, maxPos := 0, 0
+	nextArg := func() string {
+		arg := ""
+		if argPos > 0 {
+			if argPos <= len(allArgs) {
+				arg = allArgs[argPos-1]
+			}
+			maxPos = max(maxPos, argPos)
+			argPos = 0
+		} else if len(args) > 0 {
+			arg, args = args[0], args[1:]
+		}
+		return arg
+	}
+
 formatLoop:
 	for i := 0; i < len(format); i++ {
 		// readDigits reads from 0 to max digits, either octal or
 		// hexadecimal.
 		readDigits := func(max int, hex bool) string {
 			j := 0
-			for ; j < max; j++ {
+			for ; j < max && i+j < len(format); j++ {
 				c := format[i+j]
 				if (c >= '0' && c <= '9') ||
 					(hex && c >= 'a' && c <= 'f') ||
@@ -337,12 +421,8 @@ formatLoop:
 				fmts = nil
 			case 'c':
 				var b byte
-				if len(args) > 0 {
-					arg := ""
-					arg, args = args[0], args[1:]
-					if len(arg) > 0 {
-						b = arg[0]
-					}
+				if arg := nextArg(); len(arg) > 0 {
+					b = arg[0]
 				}
 				sb.WriteByte(b)
 				fmts = nil
@@ -353,11 +433,17 @@ formatLoop:
 				fmts = append(fmts, c)
 			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 				fmts = append(fmts, c)
-			case 's', 'b', 'd', 'i', 'u', 'o', 'x':
-				arg := ""
-				if len(args) > 0 {
-					arg, args = args[0], args[1:]
+			case '$':
+				// A positional specifier like "%2$s", where the digits
+				// cannot be a width as they come right after the '%'.
+				n, err := strconv.Atoi(string(fmts[1:]))
+				if argPos > 0 || err != nil || n < 1 {
+					return 0, fmt.Errorf("invalid format char: %c", c)
 				}
+				argPos = n
+				fmts = fmts[:1]
+			case 's', 'b', 'd', 'i', 'u', 'o', 'x':
+				arg := nextArg()
 				var farg any
 				if c == 'b' {
 					// Passing in nil for args ensures that % format
@@ -399,7 +485,7 @@ formatLoop:
 	if len(fmts) > 0 {
 		return 0, fmt.Errorf("missing format char")
 	}
-	return initialArgs - len(args), nil
+	return max(initialArgs-len(args), min(maxPos, initialArgs)), nil
 }
 
 func (cfg *Config) fieldJoin(parts []fieldPart) string {
@@ -440,6 +526,74 @@ func (cfg *Config) escapedGlobField(parts []fieldPart) (escaped string, glob boo
 func Fields(cfg *Config, words ...*syntax.Word) ([]string, error) {
 	cfg = prepareConfig(cfg)
 	fields := make([]string, 0, len(words))
+	err := cfg.fields(words, func(value string, _ []fieldPart, _ bool) {
+		fields = append(fields, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Field is a field resulting from [AnnotatedFields], along with where it came
+// from and which expansions it was subject to.
+type Field struct {
+	Value string
+
+	// Parts are the word parts which the field's value came from, in order.
+	// Quoted parts are the innermost ones, such as the [*syntax.ParamExp]
+	// in "$foo" rather than the [*syntax.DblQuoted].
+	// One part may result in multiple fields, such as an unquoted $foo
+	// being split. After brace expansion, parts may be new literals
+	// which are not part of the original words.
+	Parts []syntax.WordPart
+
+	// Split is true if any of the parts was an unquoted parameter expansion,
+	// command substitution, or process substitution, which are subject to
+	// field splitting.
+	Split bool
+
+	// Glob is true if the field contained unquoted pattern characters, so that
+	// pathname expansion was performed. If any files matched,
+	// each of them results in a separate field with the same parts;
+	// otherwise, the field is kept as is.
+	// Note that pathname expansion is only performed if [Config.ReadDir2] is set.
+	Glob bool
+}
+
+// AnnotatedFields is like [Fields], but it also reports where each field came
+// from, and whether it was subject to field splitting or pathname expansion.
+// This can be useful to find fields which may contain arbitrary input.
+func AnnotatedFields(cfg *Config, words ...*syntax.Word) ([]Field, error) {
+	cfg = prepareConfig(cfg)
+	fields := make([]Field, 0, len(words))
+	err := cfg.fields(words, func(value string, parts []fieldPart, glob bool) {
+		field := Field{Value: value, Glob: glob}
+		for _, part := range parts {
+			if lit, ok := part.part.(*syntax.Lit); ok && lit.Value == "" {
+				continue // such as those left behind by brace expansion
+			}
+			if n := len(field.Parts); n == 0 || field.Parts[n-1] != part.part {
+				field.Parts = append(field.Parts, part.part)
+			}
+			if part.quote == quoteNone {
+				switch part.part.(type) {
+				case *syntax.ParamExp, *syntax.CmdSubst, *syntax.ProcSubst:
+					field.Split = true
+				}
+			}
+		}
+		fields = append(fields, field)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// fields implements [Fields], calling fn with each resulting field's value,
+// the parts it was made of, and whether pathname expansion was performed.
+func (cfg *Config) fields(words []*syntax.Word, fn func(value string, parts []fieldPart, glob bool)) error {
 	dir := cfg.envGet("PWD")
 	for _, word := range words {
 		word := *word // make a copy, since SplitBraces replaces the Parts slice
@@ -450,34 +604,41 @@ func Fields(cfg *Config, words ...*syntax.Word) ([]string, error) {
 		for _, word2 := range afterBraces {
 			wfields, err := cfg.wordFields(word2.Parts)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			for _, field := range wfields {
 				path, doGlob := cfg.escapedGlobField(field)
-				var matches []string
-				if doGlob && cfg.ReadDir2 != nil {
-					matches, err = cfg.glob(dir, path)
+				globbed := false
+				if doGlob && cfg.ReadDir2 != nil && !cfg.NoGlob {
+					matches, err := cfg.glob(dir, path)
 					if err != nil {
 						// We avoid [errors.As] as it allocates,
 						// and we know that [Config.glob] returns [pattern.Regexp] errors without wrapping.
 						if _, ok := err.(*pattern.SyntaxError); !ok {
-							return nil, err
+							return err
 						}
 					} else if len(matches) > 0 || cfg.NullGlob {
-						fields = append(fields, matches...)
+						for _, match := range matches {
+							fn(match, field, true)
+						}
 						continue
+					} else {
+						globbed = true
 					}
 				}
-				fields = append(fields, cfg.fieldJoin(field))
+				fn(cfg.fieldJoin(field), field, globbed)
 			}
 		}
 	}
-	return fields, nil
+	return nil
 }
 
 type fieldPart struct {
 	val   string
 	quote quoteLevel
+
+	// part is the word part which the value came from.
+	part syntax.WordPart
 }
 
 type quoteLevel uint
@@ -519,9 +680,9 @@ func (cfg *Config) wordField(wps []syntax.WordPart, ql quoteLevel) ([]fieldPart,
 			if i := strings.IndexByte(s, '\x00'); i >= 0 {
 				s = s[:i]
 			}
-			field = append(field, fieldPart{val: s})
+			field = append(field, fieldPart{val: s, part: wp})
 		case *syntax.SglQuoted:
-			fp := fieldPart{quote: quoteSingle, val: wp.Value}
+			fp := fieldPart{quote: quoteSingle, val: wp.Value, part: wp}
 			if wp.Dollar {
 				fp.val, _, _ = Format(cfg, fp.val, nil)
 			}
@@ -540,25 +701,25 @@ func (cfg *Config) wordField(wps []syntax.WordPart, ql quoteLevel) ([]fieldPart,
 			if err != nil {
 				return nil, err
 			}
-			field = append(field, fieldPart{val: val})
+			field = append(field, fieldPart{val: val, part: wp})
 		case *syntax.CmdSubst:
 			val, err := cfg.cmdSubst(wp)
 			if err != nil {
 				return nil, err
 			}
-			field = append(field, fieldPart{val: val})
+			field = append(field, fieldPart{val: val, part: wp})
 		case *syntax.ArithmExp:
 			n, err := Arithm(cfg, wp.X)
 			if err != nil {
 				return nil, err
 			}
-			field = append(field, fieldPart{val: strconv.Itoa(n)})
+			field = append(field, fieldPart{val: strconv.Itoa(n), part: wp})
 		case *syntax.ProcSubst:
-			path, err := cfg.ProcSubst(wp)
+			path, err := cfg.procSubst(wp)
 			if err != nil {
 				return nil, err
 			}
-			field = append(field, fieldPart{val: path})
+			field = append(field, fieldPart{val: path, part: wp})
 		default:
 			panic(fmt.Sprintf("unhandled word part: %T", wp))
 		}
@@ -566,6 +727,13 @@ func (cfg *Config) wordField(wps []syntax.WordPart, ql quoteLevel) ([]fieldPart,
 	return field, nil
 }
 
+func (cfg *Config) procSubst(ps *syntax.ProcSubst) (string, error) {
+	if cfg.ProcSubst == nil {
+		return "", UnexpectedProcessError{Node: ps}
+	}
+	return cfg.ProcSubst(ps)
+}
+
 func (cfg *Config) cmdSubst(cs *syntax.CmdSubst) (string, error) {
 	if cfg.CmdSubst == nil {
 		return "", UnexpectedCommandError{Node: cs}
@@ -576,7 +744,14 @@ func (cfg *Config) cmdSubst(cs *syntax.CmdSubst) (string, error) {
 	}
 	out := sb.String()
 	if strings.IndexByte(out, '\x00') >= 0 {
-		out = strings.ReplaceAll(out, "\x00", "")
+		if cfg.CmdSubstNulls == nil {
+			out = strings.ReplaceAll(out, "\x00", "")
+		} else {
+			var err error
+			if out, err = cfg.CmdSubstNulls(cs, out); err != nil {
+				return "", err
+			}
+		}
 	}
 	return strings.TrimRight(out, "\n"), nil
 }
@@ -592,23 +767,43 @@ func (cfg *Config) wordFields(wps []syntax.WordPart) ([][]fieldPart, error) {
 		fields = append(fields, curField)
 		curField = nil
 	}
-	splitAdd := func(val string) {
+	// spaceEnded is set when IFS whitespace ended the last field,
+	// so that a following non-whitespace IFS character doesn't start
+	// an empty field. See [ifsSpace].
+	spaceEnded := false
+	splitAdd := func(val string, part syntax.WordPart) {
+		if cfg.NoSplit {
+			if val != "" {
+				curField = append(curField, fieldPart{val: val, part: part})
+			}
+			return
+		}
 		fieldStart := -1
 		for i, r := range val {
-			if cfg.ifsRune(r) {
-				if fieldStart >= 0 { // ending a field
-					curField = append(curField, fieldPart{val: val[fieldStart:i]})
-					fieldStart = -1
-				}
-				flush()
-			} else {
+			if !cfg.ifsRune(r) {
 				if fieldStart < 0 { // starting a new field
 					fieldStart = i
 				}
+				continue
+			}
+			if fieldStart >= 0 { // ending a field
+				curField = append(curField, fieldPart{val: val[fieldStart:i], part: part})
+				fieldStart = -1
+			}
+			switch {
+			case len(curField) > 0:
+				flush()
+				spaceEnded = ifsSpace(r)
+			case ifsSpace(r):
+			case spaceEnded:
+				spaceEnded = false
+			default: // an empty field
+				curField = append(curField, fieldPart{part: part})
+				flush()
 			}
 		}
 		if fieldStart >= 0 { // ending a field without IFS
-			curField = append(curField, fieldPart{val: val[fieldStart:]})
+			curField = append(curField, fieldPart{val: val[fieldStart:], part: part})
 		}
 	}
 	for i, wp := range wps {
@@ -620,6 +815,7 @@ func (cfg *Config) wordFields(wps []syntax.WordPart) ([][]fieldPart, error) {
 				curField = append(curField, fieldPart{
 					quote: quoteSingle,
 					val:   prefix,
+					part:  wp,
 				})
 				s = rest
 			}
@@ -637,10 +833,10 @@ func (cfg *Config) wordFields(wps []syntax.WordPart) ([][]fieldPart, error) {
 				}
 				s = sb.String()
 			}
-			curField = append(curField, fieldPart{val: s})
+			curField = append(curField, fieldPart{val: s, part: wp})
 		case *syntax.SglQuoted:
 			allowEmpty = true
-			fp := fieldPart{quote: quoteSingle, val: wp.Value}
+			fp := fieldPart{quote: quoteSingle, val: wp.Value, part: wp}
 			if wp.Dollar {
 				fp.val, _, _ = Format(cfg, fp.val, nil)
 			}
@@ -656,6 +852,7 @@ func (cfg *Config) wordFields(wps []syntax.WordPart) ([][]fieldPart, error) {
 						curField = append(curField, fieldPart{
 							quote: quoteDouble,
 							val:   elem,
+							part:  pe,
 						})
 					}
 					continue
@@ -666,6 +863,10 @@ func (cfg *Config) wordFields(wps []syntax.WordPart) ([][]fieldPart, error) {
 			if err != nil {
 				return nil, err
 			}
+			if len(wfield) == 0 {
+				// An empty quoted string can still start a field.
+				wfield = append(wfield, fieldPart{part: wp})
+			}
 			for _, part := range wfield {
 				part.quote = quoteDouble
 				curField = append(curField, part)
@@ -675,25 +876,25 @@ func (cfg *Config) wordFields(wps []syntax.WordPart) ([][]fieldPart, error) {
 			if err != nil {
 				return nil, err
 			}
-			splitAdd(val)
+			splitAdd(val, wp)
 		case *syntax.CmdSubst:
 			val, err := cfg.cmdSubst(wp)
 			if err != nil {
 				return nil, err
 			}
-			splitAdd(val)
+			splitAdd(val, wp)
 		case *syntax.ArithmExp:
 			n, err := Arithm(cfg, wp.X)
 			if err != nil {
 				return nil, err
 			}
-			curField = append(curField, fieldPart{val: strconv.Itoa(n)})
+			curField = append(curField, fieldPart{val: strconv.Itoa(n), part: wp})
 		case *syntax.ProcSubst:
-			path, err := cfg.ProcSubst(wp)
+			path, err := cfg.procSubst(wp)
 			if err != nil {
 				return nil, err
 			}
-			splitAdd(path)
+			splitAdd(path, wp)
 		case *syntax.ExtGlob:
 			return nil, fmt.Errorf("extended globbing is not supported")
 		default:
@@ -725,9 +926,8 @@ func (cfg *Config) quotedElemFields(pe *syntax.ParamExp) []string {
 		case "@": // "${!name[@]}"
 			switch vr := cfg.Env.Get(name); vr.Kind {
 			case Indexed:
-				keys := make([]string, 0, len(vr.Map))
-				// TODO: maps.Keys if it makes it into Go 1.23
-				for key := range vr.List {
+				keys := make([]string, 0, len(vr.List))
+				for _, key := range vr.ArrayIndices() {
 					keys = append(keys, strconv.Itoa(key))
 				}
 				return keys
@@ -802,6 +1002,9 @@ func (cfg *Config) expandUser(field string) (prefix, rest string) {
 	if vr := cfg.Env.Get("HOME " + name); vr.IsSet() {
 		return vr.String(), rest
 	}
+	if cfg.NoUserLookup {
+		return "", field
+	}
 
 	u, err := user.Lookup(name)
 	if err != nil {
@@ -958,6 +1161,13 @@ func (cfg *Config) glob(base, pat string) ([]string, error) {
 		}
 		matches = newMatches
 	}
+	if filepath.Separator != '/' && strings.Contains(pat, "/") {
+		// On Windows, use forward slashes like the pattern did,
+		// rather than returning paths with mixed separators.
+		for i, match := range matches {
+			matches[i] = filepath.ToSlash(match)
+		}
+	}
 	return matches, nil
 }
 
@@ -1005,51 +1215,66 @@ func (cfg *Config) globDir(base, dir string, rx *regexp.Regexp, matchHidden bool
 // empty config.
 func ReadFields(cfg *Config, s string, n int, raw bool) []string {
 	cfg = prepareConfig(cfg)
-	type pos struct {
-		start, end int
-	}
-	var fpos []pos
-
 	runes := make([]rune, 0, len(s))
-	infield := false
+	escaped := make([]bool, 0, len(s)) // escaped runes are never IFS
 	esc := false
 	for _, r := range s {
-		if infield {
-			if cfg.ifsRune(r) && (raw || !esc) {
-				fpos[len(fpos)-1].end = len(runes)
-				infield = false
-			}
-		} else {
-			if !cfg.ifsRune(r) && (raw || !esc) {
-				fpos = append(fpos, pos{start: len(runes), end: -1})
-				infield = true
-			}
-		}
-		if r == '\\' {
-			if raw || esc {
-				runes = append(runes, r)
-			}
-			esc = !esc
+		if r == '\\' && !raw && !esc {
+			esc = true
 			continue
 		}
 		runes = append(runes, r)
+		escaped = append(escaped, esc)
 		esc = false
 	}
+	isIFS := func(i int) bool { return !escaped[i] && cfg.ifsRune(runes[i]) }
+
+	type pos struct {
+		start, end int
+	}
+	// split is like the field splitting in [Config.wordFields].
+	split := func(start, end int) []pos {
+		var fpos []pos
+		fieldStart := -1
+		spaceEnded := false
+		for i := start; i < end; i++ {
+			switch {
+			case !isIFS(i):
+				if fieldStart < 0 {
+					fieldStart = i
+				}
+			case fieldStart >= 0:
+				fpos = append(fpos, pos{fieldStart, i})
+				fieldStart = -1
+				spaceEnded = ifsSpace(runes[i])
+			case ifsSpace(runes[i]):
+			case spaceEnded:
+				spaceEnded = false
+			default: // an empty field
+				fpos = append(fpos, pos{i, i})
+			}
+		}
+		if fieldStart >= 0 {
+			fpos = append(fpos, pos{fieldStart, end})
+		}
+		return fpos
+	}
+	fpos := split(0, len(runes))
 	if len(fpos) == 0 {
 		return nil
 	}
-	if infield {
-		fpos[len(fpos)-1].end = len(runes)
-	}
-
-	switch {
-	case n == 1:
-		// include heading/trailing IFSs
-		fpos[0].start, fpos[0].end = 0, len(runes)
-		fpos = fpos[:1]
-	case n != -1 && n < len(fpos):
-		// combine to max n fields
-		fpos[n-1].end = fpos[len(fpos)-1].end
+	if n != -1 && n < len(fpos) {
+		// The last field gets the rest of the input,
+		// without trailing IFS whitespace.
+		start, end := fpos[n-1].start, len(runes)
+		for end > start && isIFS(end-1) && ifsSpace(runes[end-1]) {
+			end--
+		}
+		if rest := split(start, end); len(rest) == 1 {
+			// Only one field remains, so drop its trailing delimiter.
+			end = rest[0].end
+		}
+		fpos[n-1].end = end
 		fpos = fpos[:n]
 	}
 
@@ -4,6 +4,8 @@
 package expand
 
 import (
+	"errors"
+	"io"
 	"io/fs"
 	"os"
 	"reflect"
@@ -64,6 +66,85 @@ func TestConfigNils(t *testing.T) {
 	}
 }
 
+func TestCmdSubstNulls(t *testing.T) {
+	t.Parallel()
+	cmdSubst := func(w io.Writer, cs *syntax.CmdSubst) error {
+		_, err := io.WriteString(w, "a\x00b\n\x00\n")
+		return err
+	}
+	errNulls := errors.New("null bytes")
+	tests := []struct {
+		name    string
+		nulls   func(*syntax.CmdSubst, string) (string, error)
+		want    string
+		wantErr error
+	}{
+		{"Drop", nil, "ab", nil},
+		{"Keep", func(cs *syntax.CmdSubst, out string) (string, error) {
+			return out, nil
+		}, "a\x00b\n\x00", nil},
+		{"Error", func(cs *syntax.CmdSubst, out string) (string, error) {
+			return "", errNulls
+		}, "", errNulls},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{CmdSubst: cmdSubst, CmdSubstNulls: tc.nulls}
+			got, err := Literal(cfg, parseWord(t, "$(foo)"))
+			if err != tc.wantErr {
+				t.Fatalf("wanted error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("wanted %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSafeConfig(t *testing.T) {
+	t.Parallel()
+	env := ListEnviron("a=x  y*", "empty=", "HOME=/home/me", "HOME bob=/home/bob")
+	tests := []struct {
+		src     string
+		want    []string
+		wantErr string
+	}{
+		{src: "$a", want: []string{"x  y*"}},
+		{src: "$a$empty $empty", want: []string{"x  y*"}},
+		{src: `"$a" *`, want: []string{"x  y*", "*"}},
+		{src: "~/f ~bob/f ~root/f", want: []string{"/home/me/f", "/home/bob/f", "~root/f"}},
+		{src: "foo $(rm -rf /)", wantErr: "unexpected command substitution at 1:5"},
+		{src: "foo <(rm -rf /)", wantErr: "unexpected process substitution at 1:5"},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			cfg := SafeConfig(env)
+			// Ensure that the knobs apply even if globbing is possible.
+			cfg.ReadDir2 = func(string) ([]fs.DirEntry, error) {
+				t.Fatal("unexpected ReadDir2 call")
+				return nil, nil
+			}
+			f, err := syntax.NewParser().Parse(strings.NewReader(tc.src), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Fields(cfg, f.Stmts[0].Cmd.(*syntax.CallExpr).Args...)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("wanted error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("wanted %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestFieldsIdempotency(t *testing.T) {
 	tests := []struct {
 		src  string
@@ -92,6 +173,159 @@ func TestFieldsIdempotency(t *testing.T) {
 	}
 }
 
+func TestFieldSplitting(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ifs, a, b string
+		src       string
+		want      []string
+	}{
+		{" \t\n", "  foo  bar  ", "", "$a", []string{"foo", "bar"}},
+		{": ", " : x", "", "$a", []string{"", "x"}},
+		{": ", "x::y", "", "$a", []string{"x", "", "y"}},
+		{": ", ":x:", "", "$a", []string{"", "x"}},
+		{": ", "x : y", "", "$a", []string{"x", "y"}},
+		{": ", "x :: y ", "", "$a", []string{"x", "", "y"}},
+		{": ", "  ", "", "$a", nil},
+		{": ", ":", "", "$a", []string{""}},
+		{": ", "::", "", "$a", []string{"", ""}},
+		{": ", " : ", "", "$a", []string{""}},
+		{": ", "x ", ":y", "$a$b", []string{"x", "y"}},
+		{": ", "x:", ":y", "$a$b", []string{"x", "", "y"}},
+		{": ", "x", ":", "${a}${b}z", []string{"x", "z"}},
+		{": ", ":x", "", `""$a`, []string{"", "x"}},
+		{": ", "x:", "", `$a""`, []string{"x", ""}},
+		{": ", "x:y", "", `"$a"`, []string{"x:y"}},
+		{":", "x: :y", "", "$a", []string{"x", " ", "y"}},
+		{"", "x y", "", "$a", []string{"x y"}},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			cfg := &Config{Env: ListEnviron("IFS="+tc.ifs, "a="+tc.a, "b="+tc.b)}
+			f, err := syntax.NewParser().Parse(strings.NewReader(tc.src), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			word := f.Stmts[0].Cmd.(*syntax.CallExpr).Args[0]
+			got, err := Fields(cfg, word)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) == 0 && len(tc.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("wanted %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReadFields(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ifs  string
+		in   string
+		n    int
+		raw  bool
+		want []string
+	}{
+		{" \t\n", "  foo  bar  ", -1, false, []string{"foo", "bar"}},
+		{" \t\n", "  foo  bar  ", 1, false, []string{"foo  bar"}},
+		{": ", "x::y", 3, false, []string{"x", "", "y"}},
+		{": ", " x : y : z : w ", 3, false, []string{"x", "y", "z : w"}},
+		{": ", "x:y:", 3, false, []string{"x", "y"}},
+		{": ", "x:y:z:", 3, false, []string{"x", "y", "z"}},
+		{": ", "x:y:z::", 3, false, []string{"x", "y", "z::"}},
+		{": ", "x:y:z :", 3, false, []string{"x", "y", "z"}},
+		{": ", ":x", 3, false, []string{"", "x"}},
+		{": ", "  ", 3, false, nil},
+		{": ", "  x : y  ", 1, false, []string{"x : y"}},
+		{": ", "x:", 1, false, []string{"x"}},
+		{": ", "x::", 1, false, []string{"x::"}},
+		{": ", "x: y :", 2, false, []string{"x", "y"}},
+		{": ", "x:y :: ", 2, false, []string{"x", "y ::"}},
+		{": ", `x\:y:z`, -1, false, []string{"x:y", "z"}},
+		{": ", `x\:y:z`, -1, true, []string{`x\`, "y", "z"}},
+		{": ", `x\ :y\ `, 2, false, []string{"x ", "y "}},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			cfg := &Config{Env: ListEnviron("IFS=" + tc.ifs)}
+			got := ReadFields(cfg, tc.in, tc.n, tc.raw)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("wanted %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAnnotatedFields(t *testing.T) {
+	t.Parallel()
+	type field struct {
+		value       string
+		parts       string
+		split, glob bool
+	}
+	tests := []struct {
+		src  string
+		want []field
+	}{
+		{"foo", []field{{"foo", "foo", false, false}}},
+		{"'a b'", []field{{"a b", "'a b'", false, false}}},
+		{"$a", []field{{"x", "$a", true, false}, {"y", "$a", true, false}}},
+		{`"$a"`, []field{{"x y", "$a", false, false}}},
+		{`pre$a"$b"`, []field{
+			{"prex", "pre $a", true, false},
+			{"y*", `$a $b`, true, false},
+		}},
+		{"$(($n + 1))", []field{{"3", "$(($n + 1))", false, false}}},
+		{"a*", []field{{"ab", "a*", false, true}, {"ac", "a*", false, true}}},
+		{`"a*"`, []field{{"a*", "a*", false, false}}},
+		{"z*", []field{{"z*", "z*", false, true}}},
+		{"$b", []field{{"ab", "$b", true, true}, {"ac", "$b", true, true}}},
+		{"{x,y}", []field{{"x", "x", false, false}, {"y", "y", false, false}}},
+	}
+	printer := syntax.NewPrinter()
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			cfg := &Config{
+				Env: ListEnviron("a=x y", "b=*", "n=2"),
+				ReadDir2: func(string) ([]fs.DirEntry, error) {
+					return []fs.DirEntry{
+						&mockFileInfo{name: "ab"},
+						&mockFileInfo{name: "ac"},
+					}, nil
+				},
+			}
+			f, err := syntax.NewParser().Parse(strings.NewReader(tc.src), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			word := f.Stmts[0].Cmd.(*syntax.CallExpr).Args[0]
+			fields, err := AnnotatedFields(cfg, word)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []field
+			for _, fd := range fields {
+				var parts []string
+				for _, part := range fd.Parts {
+					var sb strings.Builder
+					if err := printer.Print(&sb, part); err != nil {
+						t.Fatal(err)
+					}
+					parts = append(parts, sb.String())
+				}
+				got = append(got, field{fd.Value, strings.Join(parts, " "), fd.Split, fd.Glob})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("wanted %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
 func Test_glob(t *testing.T) {
 	cfg := &Config{
 		ReadDir2: func(string) ([]fs.DirEntry, error) {
@@ -62,10 +62,13 @@ func (cfg *Config) paramExp(pe *syntax.ParamExp) (string, error) {
 	var vr Variable
 	switch name {
 	case "LINENO":
-		// This is the only parameter expansion that the environment
-		// interface cannot satisfy.
-		line := uint64(cfg.curParam.Pos().Line())
-		vr = Variable{Kind: String, Str: strconv.FormatUint(line, 10)}
+		// The environment may provide the line number, such as when an
+		// interpreter runs a trap callback parsed separately.
+		// Otherwise, use the position of the parameter expansion.
+		if vr = cfg.Env.Get(name); !vr.IsSet() {
+			line := uint64(cfg.curParam.Pos().Line())
+			vr = Variable{Kind: String, Str: strconv.FormatUint(line, 10)}
+		}
 	default:
 		vr = cfg.Env.Get(name)
 	}
@@ -125,7 +128,13 @@ func (cfg *Config) paramExp(pe *syntax.ParamExp) (string, error) {
 				return n
 			}
 			if pe.Slice != nil && pe.Slice.Offset != nil {
-				elems = elems[slicePos(sliceOffset):]
+				// The offset is an index rather than a position,
+				// which matters when the array is sparse.
+				start := len(elems)
+				if i, ok := vr.ArrayIndex(sliceOffset); ok {
+					start, _ = slices.BinarySearch(vr.ArrayIndices(), i)
+				}
+				elems = elems[start:]
 			}
 			if pe.Slice != nil && pe.Slice.Length != nil {
 				elems = elems[:slicePos(sliceLen)]
@@ -158,19 +167,19 @@ func (cfg *Config) paramExp(pe *syntax.ParamExp) (string, error) {
 		switch {
 		case pe.Names != 0:
 			strs = cfg.namesByPrefix(pe.Param.Value)
+			slices.Sort(strs)
 		case orig.Kind == NameRef:
 			strs = append(strs, orig.Str)
 		case pe.Index != nil && vr.Kind == Indexed:
-			for i, e := range vr.List {
-				if e != "" {
-					strs = append(strs, strconv.Itoa(i))
-				}
+			for _, i := range vr.ArrayIndices() {
+				strs = append(strs, strconv.Itoa(i))
 			}
 		case pe.Index != nil && vr.Kind == Associative:
 			// TODO: use maps.Keys
 			for k := range vr.Map {
 				strs = append(strs, k)
 			}
+			slices.Sort(strs)
 		case vr.Kind == Unset:
 			return "", fmt.Errorf("invalid indirect expansion")
 		case str == "":
@@ -179,7 +188,6 @@ func (cfg *Config) paramExp(pe *syntax.ParamExp) (string, error) {
 			vr = cfg.Env.Get(str)
 			strs = append(strs, vr.String())
 		}
-		slices.Sort(strs)
 		str = strings.Join(strs, " ")
 	case pe.Slice != nil:
 		if callVarInd {
@@ -391,12 +399,12 @@ func (cfg *Config) varInd(vr Variable, idx syntax.ArithmExpr) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		if i < 0 {
-			return "", fmt.Errorf("negative array index")
-		}
-		if i < len(vr.List) {
-			return vr.List[i], nil
+		i, ok := vr.ArrayIndex(i)
+		if !ok {
+			return "", fmt.Errorf("bad array subscript")
 		}
+		str, _ := vr.ArrayElem(i)
+		return str, nil
 	case Associative:
 		switch lit := nodeLit(idx); lit {
 		case "@", "*":
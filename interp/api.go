@@ -11,9 +11,14 @@
 // The interpreter currently aims to behave like a non-interactive shell,
 // which is how most shells run scripts, and is more useful to machines.
 // In the future, it may gain an option to behave like an interactive shell.
+//
+// The interpreter can also be built for js/wasm and wasip1/wasm, such as to run
+// scripts in a web browser. Since those platforms cannot start processes, any
+// programs which scripts may run must be provided via [ExecHandlers].
 package interp
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -23,12 +28,13 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/sync/errgroup"
-
 	"mvdan.cc/sh/v3/expand"
 	"mvdan.cc/sh/v3/syntax"
 )
@@ -66,6 +72,18 @@ type Runner struct {
 	Vars  map[string]expand.Variable
 	Funcs map[string]*syntax.Stmt
 
+	// exportedFuncs holds the names of the functions marked with "export -f".
+	exportedFuncs map[string]bool
+
+	// noExportFuncs disables exporting and importing functions.
+	noExportFuncs bool
+
+	// policy is set by [Restrict].
+	policy Policy
+
+	// msysPaths enables translating MSYS-style paths on Windows.
+	msysPaths bool
+
 	alias map[string]alias
 
 	// callHandler is a function allowing to replace a simple command's
@@ -83,6 +101,10 @@ type Runner struct {
 	// openHandler is a function responsible for opening files. It must not be nil.
 	openHandler OpenHandlerFunc
 
+	// openMiddlewares grows with calls to [OpenHandlers],
+	// and is used to wrap openHandler when Reset is first called.
+	openMiddlewares []func(OpenHandlerFunc) OpenHandlerFunc
+
 	// readDirHandler is a function responsible for reading directories during
 	// glob expansion. It must be non-nil.
 	readDirHandler ReadDirHandlerFunc2
@@ -90,7 +112,7 @@ type Runner struct {
 	// statHandler is a function responsible for getting file stat. It must be non-nil.
 	statHandler StatHandlerFunc
 
-	stdin  *os.File // e.g. the read end of a pipe
+	stdin  io.Reader // e.g. the read end of a pipe; an [*os.File] where possible
 	stdout io.Writer
 	stderr io.Writer
 
@@ -137,14 +159,31 @@ type Runner struct {
 	exit     int
 	lastExit int
 
-	bgShells errgroup.Group
+	// pipeStatus holds the exit status of each command in the last pipeline,
+	// as exposed via $PIPESTATUS.
+	pipeStatus []int
+
+	// jobs is the job table; see [job].
+	// jobsMu must be held to modify it, so that [Runner.Jobs] can be
+	// called concurrently.
+	jobs   []*job
+	jobsMu sync.Mutex
+
+	// jobControl is set for interactive shells, which can stop and resume
+	// jobs. Like in Bash, subshells don't have job control.
+	jobControl bool
+
+	// trackVarChanges is set by [TrackVarChanges], and varChanges holds
+	// the changes recorded by the last call to [Runner.Run].
+	trackVarChanges bool
+	varChanges      []VarChange
 
 	opts runnerOpts
 
 	origDir    string
 	origParams []string
 	origOpts   runnerOpts
-	origStdin  *os.File
+	origStdin  io.Reader
 	origStdout io.Writer
 	origStderr io.Writer
 
@@ -162,6 +201,14 @@ type Runner struct {
 	// Fake signal callbacks
 	callbackErr  string
 	callbackExit string
+
+	// bashCommand is the statement currently being run, used for $BASH_COMMAND.
+	// It is not updated while running trap callbacks.
+	bashCommand *syntax.Stmt
+
+	// trapLine is what $LINENO expands to while running a trap callback,
+	// which is the line of the statement that triggered the trap.
+	trapLine uint
 }
 
 type alias struct {
@@ -267,11 +314,112 @@ func Dir(path string) RunnerOption {
 }
 
 // Interactive configures the interpreter to behave like an interactive shell,
-// akin to Bash. Currently, this only enables the expansion of aliases,
-// but later on it should also change other behavior.
+// akin to Bash. Currently, this enables the expansion of aliases and job
+// control, meaning that foreground processes stopped via Ctrl-Z are added to
+// the job table, to be resumed with the "fg" and "bg" builtins.
+// Background jobs which finish are reported at the end of each call to
+// [Runner.Run], or before running each command with "set -b".
+// Later on it should also change other behavior.
 func Interactive(enabled bool) RunnerOption {
 	return func(r *Runner) error {
 		r.opts[optExpandAliases] = enabled
+		r.jobControl = enabled
+		return nil
+	}
+}
+
+// LoginShell configures the interpreter to behave like a login shell.
+// Currently, this only sets the read-only "login_shell" shell option,
+// and forbids suspending the shell via the "suspend" builtin.
+func LoginShell(enabled bool) RunnerOption {
+	return func(r *Runner) error {
+		_, opt := r.optByName("login_shell", true)
+		*opt = enabled
+		return nil
+	}
+}
+
+// TrackVarChanges configures whether the interpreter records which variables
+// are created, modified, or unset by each call to [Runner.Run], to be
+// retrieved afterwards via [Runner.VarChanges]. Only changes to the global
+// scope are recorded, so local variables and variables set in subshells are
+// ignored.
+func TrackVarChanges(enabled bool) RunnerOption {
+	return func(r *Runner) error {
+		r.trackVarChanges = enabled
+		return nil
+	}
+}
+
+// ExportFuncs configures whether functions marked with "export -f" are passed
+// to child processes, and whether functions exported by the parent process are
+// imported when the runner is reset. Like in Bash, functions are encoded in
+// environment variables such as "BASH_FUNC_name%%=() { body; }", so they can be
+// shared with Bash as well.
+//
+// This is enabled by default. Importing functions means running code that
+// comes from the environment, so users who don't trust their environment
+// may want to disable it.
+func ExportFuncs(enabled bool) RunnerOption {
+	return func(r *Runner) error {
+		r.noExportFuncs = !enabled
+		return nil
+	}
+}
+
+// Policy restricts how scripts may load and run code dynamically,
+// for running untrusted scripts. See [Restrict].
+type Policy struct {
+	// NoEval disallows running strings as code,
+	// via the "eval" builtin or via "trap" callbacks.
+	NoEval bool
+
+	// NoCmdSubst disallows command and process substitutions.
+	NoCmdSubst bool
+
+	// AllowSource reports whether the "source" and "." builtins may run
+	// the script at the given absolute path. If nil, any script may be run.
+	AllowSource func(path string) bool
+}
+
+// PolicyError is the error returned by [Runner.Run] when a script tries
+// to do something which its [Policy] does not allow.
+type PolicyError struct {
+	Pos syntax.Pos
+
+	// Action is what the script tried to do, such as "eval".
+	Action string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s: %s is not allowed", e.Pos, e.Action)
+}
+
+// Restrict sets a policy to restrict how scripts may load and run code
+// dynamically. When a script tries to do something which is not allowed,
+// the runner stops and returns a [*PolicyError].
+//
+// Note that the policy does not cover executing programs, such as "bash -c",
+// which can be restricted via [ExecHandlers], nor importing exported functions
+// from the environment, which can be disabled via [ExportFuncs].
+func Restrict(policy Policy) RunnerOption {
+	return func(r *Runner) error {
+		r.policy = policy
+		return nil
+	}
+}
+
+// MSYSPaths configures whether the interpreter accepts MSYS-style paths on
+// Windows, such as "/c/Users" for `C:\Users`, like Git Bash does. When enabled,
+// such paths are translated when changing directories, opening or testing
+// files, and "pwd" and $PWD show the current directory in the same style.
+// It has no effect on other platforms.
+//
+// Regardless of this option, PATH may be an MSYS-style list on Windows,
+// such as "/c/bin:/usr/bin"; see [LookPathDir].
+func MSYSPaths(enabled bool) RunnerOption {
+	return func(r *Runner) error {
+		r.msysPaths = enabled
 		return nil
 	}
 }
@@ -324,6 +472,9 @@ func Params(args ...string) RunnerOption {
 				return fmt.Errorf("invalid option: %q", value)
 			}
 			*opt = enable
+			if value == "ignoreeof" && r.writeEnv != nil {
+				r.setIgnoreEOF(enable)
+			}
 		}
 		if args := fp.args(); args != nil {
 			// If "--" wasn't given and there were zero arguments,
@@ -379,8 +530,8 @@ func ExecHandlers(middlewares ...func(next ExecHandlerFunc) ExecHandlerFunc) Run
 	}
 }
 
-// TODO: consider porting the middleware API in [ExecHandlers] to [OpenHandler],
-// ReadDirHandler, and StatHandler.
+// TODO: consider porting the middleware API in [ExecHandlers] to
+// ReadDirHandler and StatHandler, like [OpenHandlers].
 
 // TODO(v4): now that [ExecHandlers] allows calling a next handler with changed
 // arguments, one of the two advantages of [CallHandler] is gone. The other is the
@@ -395,6 +546,23 @@ func OpenHandler(f OpenHandlerFunc) RunnerOption {
 	}
 }
 
+// OpenHandlers appends middlewares to handle opening files.
+// The middlewares are chained from first to last, and the first is called by the runner.
+// Each middleware is expected to call the "next" middleware at most once.
+//
+// For example, a middleware may provide virtual files like "/dev/null"
+// without calling "next", rewrite the path before calling "next",
+// or log each call to "next" along with its result.
+//
+// The last open handler is the one set via [OpenHandler],
+// which defaults to [DefaultOpenHandler].
+func OpenHandlers(middlewares ...func(next OpenHandlerFunc) OpenHandlerFunc) RunnerOption {
+	return func(r *Runner) error {
+		r.openMiddlewares = append(r.openMiddlewares, middlewares...)
+		return nil
+	}
+}
+
 // ReadDirHandler sets the read directory handler. See [ReadDirHandlerFunc] for more info.
 //
 // Deprecated: use [ReadDirHandler2].
@@ -431,23 +599,41 @@ func StatHandler(f StatHandlerFunc) RunnerOption {
 	}
 }
 
-func stdinFile(r io.Reader) (*os.File, error) {
+// stdinReader returns r as an [*os.File] where possible, as that is the only
+// way to share a reader with subprocesses.
+func stdinReader(r io.Reader) (io.Reader, error) {
 	switch r := r.(type) {
 	case *os.File:
 		return r, nil
 	case nil:
 		return nil, nil
-	default:
-		pr, pw, err := os.Pipe()
-		if err != nil {
-			return nil, err
-		}
-		go func() {
-			io.Copy(pw, r)
-			pw.Close()
-		}()
-		return pr, nil
 	}
+	if noProcesses {
+		return r, nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		io.Copy(pw, r)
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+// noProcesses is set on platforms which cannot start processes,
+// and thus cannot create pipes either.
+const noProcesses = runtime.GOOS == "js" || runtime.GOOS == "wasip1"
+
+// pipe is like [os.Pipe], but it uses an [io.Pipe] on platforms like js/wasm,
+// where no processes need to share the pipe.
+func pipe() (io.ReadCloser, io.WriteCloser, error) {
+	if noProcesses {
+		pr, pw := io.Pipe()
+		return pr, pw, nil
+	}
+	return os.Pipe()
 }
 
 // StdIO configures an interpreter's standard input, standard output, and
@@ -465,7 +651,7 @@ func stdinFile(r io.Reader) (*os.File, error) {
 // so that cancelling the runner's context can stop a blocked standard input read.
 func StdIO(in io.Reader, out, err io.Writer) RunnerOption {
 	return func(r *Runner) error {
-		stdin, _err := stdinFile(in)
+		stdin, _err := stdinReader(in)
 		if _err != nil {
 			return _err
 		}
@@ -520,8 +706,10 @@ var shellOptsTable = [...]shellOpt{
 	{'e', "errexit"},
 	{'n', "noexec"},
 	{'f', "noglob"},
+	{'b', "notify"},
 	{'u', "nounset"},
 	{'x', "xtrace"},
+	{' ', "ignoreeof"},
 	{' ', "pipefail"},
 }
 
@@ -537,11 +725,21 @@ var bashOptsTable = [...]bashOpt{
 		defaultState: false,
 		supported:    true,
 	},
+	{
+		name:         "lastpipe",
+		defaultState: false,
+		supported:    true,
+	},
 	{
 		name:         "nocaseglob",
 		defaultState: false,
 		supported:    true,
 	},
+	{
+		name:         "nocasematch",
+		defaultState: false,
+		supported:    true,
+	},
 	{
 		name:         "nullglob",
 		defaultState: false,
@@ -607,14 +805,12 @@ var bashOptsTable = [...]bashOpt{
 		name:         "interactive_comments",
 		defaultState: true,
 	},
-	{name: "lastpipe"},
 	{name: "lithist"},
 	{name: "localvar_inherit"},
 	{name: "localvar_unset"},
 	{name: "login_shell"},
 	{name: "mailwarn"},
 	{name: "no_empty_cmd_completion"},
-	{name: "nocasematch"},
 	{
 		name:         "progcomp",
 		defaultState: true,
@@ -642,15 +838,19 @@ const (
 	optErrExit
 	optNoExec
 	optNoGlob
+	optNotify
 	optNoUnset
 	optXTrace
+	optIgnoreEOF
 	optPipeFail
 
-	// These correspond to indexes (offset by the above seven items) of
+	// These correspond to indexes (offset by the above nine items) of
 	// supported options in [bashOptsTable]
 	optExpandAliases
 	optGlobStar
+	optLastPipe
 	optNoCaseGlob
+	optNoCaseMatch
 	optNullGlob
 )
 
@@ -685,6 +885,10 @@ func (r *Runner) Reset() {
 			middleware := r.execMiddlewares[i]
 			r.execHandler = middleware(r.execHandler)
 		}
+		for i := len(r.openMiddlewares) - 1; i >= 0; i-- {
+			middleware := r.openMiddlewares[i]
+			r.openHandler = middleware(r.openHandler)
+		}
 	}
 	// reset the internal state
 	*r = Runner{
@@ -694,6 +898,12 @@ func (r *Runner) Reset() {
 		openHandler:    r.openHandler,
 		readDirHandler: r.readDirHandler,
 		statHandler:    r.statHandler,
+		noExportFuncs:  r.noExportFuncs,
+		policy:         r.policy,
+		msysPaths:      r.msysPaths,
+		jobControl:     r.jobControl,
+
+		trackVarChanges: r.trackVarChanges,
 
 		// These can be set by functions like [Dir] or [Params], but
 		// builtins can overwrite them; reset the fields to whatever the
@@ -749,9 +959,15 @@ func (r *Runner) Reset() {
 			Str:      strconv.Itoa(os.Getgid()),
 		})
 	}
-	r.setVarString("PWD", r.Dir)
+	r.setVarString("PWD", r.shellPath(r.Dir))
 	r.setVarString("IFS", " \t\n")
 	r.setVarString("OPTIND", "1")
+	if r.opts[optIgnoreEOF] && !r.writeEnv.Get("IGNOREEOF").IsSet() {
+		r.setIgnoreEOF(true)
+	}
+	if !r.noExportFuncs {
+		r.importFuncs()
+	}
 
 	r.dirStack = append(r.dirStack, r.Dir)
 
@@ -795,6 +1011,11 @@ func (r *Runner) Run(ctx context.Context, node syntax.Node) error {
 	r.err = nil
 	r.shellExited = false
 	r.filename = ""
+	r.varChanges = nil
+	if r.trackVarChanges {
+		r.writeEnv.(*overlayEnviron).changed = make(map[string]expand.Variable)
+		defer r.finishVarChanges()
+	}
 	switch node := node.(type) {
 	case *syntax.File:
 		r.filename = node.Name
@@ -812,6 +1033,8 @@ func (r *Runner) Run(ctx context.Context, node syntax.Node) error {
 	if r.exit != 0 {
 		r.setErr(NewExitStatus(uint8(r.exit)))
 	}
+	// Like in Bash, finished jobs are reported before the next prompt.
+	r.reportJobs()
 	if r.Vars != nil {
 		r.writeEnv.Each(func(name string, vr expand.Variable) bool {
 			r.Vars[name] = vr
@@ -821,6 +1044,86 @@ func (r *Runner) Run(ctx context.Context, node syntax.Node) error {
 	return r.err
 }
 
+// VarChange describes a variable which was changed by a call to [Runner.Run].
+// Its attributes, such as whether it is exported or read-only, are part of
+// the old and new values.
+type VarChange struct {
+	Name string
+
+	// Old is the value before the change, which is unset if the variable
+	// was created.
+	Old expand.Variable
+
+	// New is the value after the change, which is unset if the variable
+	// was unset.
+	New expand.Variable
+}
+
+// VarChanges returns the variables which were changed by the last call to
+// [Runner.Run], sorted by name. A variable which was modified and then
+// restored to its old value is not included.
+//
+// Changes are only recorded if [TrackVarChanges] is enabled.
+func (r *Runner) VarChanges() []VarChange {
+	return r.varChanges
+}
+
+func (r *Runner) finishVarChanges() {
+	o := r.writeEnv.(*overlayEnviron)
+	for name, old := range o.changed {
+		vr := o.Get(name)
+		if !varEqual(old, vr) {
+			r.varChanges = append(r.varChanges, VarChange{Name: name, Old: old, New: vr})
+		}
+	}
+	o.changed = nil
+	slices.SortFunc(r.varChanges, func(a, b VarChange) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// Output is like [Runner.Run], but it captures the standard output and standard
+// error written while interpreting the node, rather than writing them to the
+// writers given via [StdIO]. The original writers are restored before returning,
+// even if the node fails or the context is cancelled.
+//
+// Background commands which are still running when Output returns may keep
+// writing to the captured output, which is then lost; use "wait" to avoid that.
+func (r *Runner) Output(ctx context.Context, node syntax.Node) (stdout, stderr []byte, err error) {
+	if !r.didReset {
+		// Make sure that Reset does not remember our buffers as the
+		// original writers.
+		r.Reset()
+	}
+	var outBuf, errBuf syncBuffer
+	oldOut, oldErr := r.stdout, r.stderr
+	r.stdout, r.stderr = &outBuf, &errBuf
+	defer func() { r.stdout, r.stderr = oldOut, oldErr }()
+
+	err = r.Run(ctx, node)
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// syncBuffer is a [bytes.Buffer] which is safe for concurrent use,
+// as multiple commands may write to the same output at once,
+// such as the commands in a pipeline writing to standard error.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
 // Exited reports whether the last Run call should exit an entire shell. This
 // can be triggered by the "exit" built-in command, for example.
 //
@@ -830,6 +1133,34 @@ func (r *Runner) Exited() bool {
 	return r.shellExited
 }
 
+// IgnoreEOF returns how many consecutive end-of-file conditions an interactive
+// shell should ignore before exiting, when reading a command as its input.
+// Like in Bash, this is configured via the IGNOREEOF variable, and defaults to
+// 10 if the variable is set to a non-numeric value or via "set -o ignoreeof".
+// If the variable is unset, it returns zero.
+func (r *Runner) IgnoreEOF() int {
+	if !r.didReset {
+		return 0
+	}
+	vr := r.writeEnv.Get("IGNOREEOF")
+	if !vr.IsSet() {
+		return 0
+	}
+	if n, err := strconv.Atoi(vr.String()); err == nil {
+		return max(n, 0)
+	}
+	return 10
+}
+
+// setIgnoreEOF implements "set -o ignoreeof", which Bash ties to IGNOREEOF.
+func (r *Runner) setIgnoreEOF(enable bool) {
+	if enable {
+		r.setVarString("IGNOREEOF", "10")
+	} else {
+		r.delVar("IGNOREEOF")
+	}
+}
+
 // Subshell makes a copy of the given [Runner], suitable for use concurrently
 // with the original. The copy will have the same environment, including
 // variables and functions, but they can all be modified without affecting the
@@ -845,7 +1176,7 @@ func (r *Runner) Subshell() *Runner {
 		r.Reset()
 	}
 	// Keep in sync with the Runner type. Manually copy fields, to not copy
-	// sensitive ones like [errgroup.Group], and to do deep copies of slices.
+	// sensitive ones like the job table, and to do deep copies of slices.
 	r2 := &Runner{
 		Dir:            r.Dir,
 		Params:         r.Params,
@@ -854,6 +1185,9 @@ func (r *Runner) Subshell() *Runner {
 		openHandler:    r.openHandler,
 		readDirHandler: r.readDirHandler,
 		statHandler:    r.statHandler,
+		noExportFuncs:  r.noExportFuncs,
+		policy:         r.policy,
+		msysPaths:      r.msysPaths,
 		stdin:          r.stdin,
 		stdout:         r.stdout,
 		stderr:         r.stderr,
@@ -862,6 +1196,8 @@ func (r *Runner) Subshell() *Runner {
 		usedNew:        r.usedNew,
 		exit:           r.exit,
 		lastExit:       r.lastExit,
+		pipeStatus:     slices.Clone(r.pipeStatus),
+		bashCommand:    r.bashCommand,
 
 		origStdout: r.origStdout, // used for process substitutions
 	}
@@ -870,6 +1206,7 @@ func (r *Runner) Subshell() *Runner {
 	oenv := &overlayEnviron{parent: r.writeEnv}
 	r2.writeEnv = oenv
 	r2.Funcs = maps.Clone(r.Funcs)
+	r2.exportedFuncs = maps.Clone(r.exportedFuncs)
 	r2.Vars = make(map[string]expand.Variable)
 	r2.alias = maps.Clone(r.alias)
 
@@ -12,9 +12,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"golang.org/x/term"
@@ -29,7 +29,7 @@ func isBuiltin(name string) bool {
 		"echo", "printf", "break", "continue", "pwd", "cd",
 		"wait", "builtin", "trap", "type", "source", ".", "command",
 		"dirs", "pushd", "popd", "umask", "alias", "unalias",
-		"fg", "bg", "getopts", "eval", "test", "[", "exec",
+		"jobs", "fg", "bg", "disown", "suspend", "getopts", "eval", "test", "[", "exec",
 		"return", "read", "mapfile", "readarray", "shopt":
 		return true
 	}
@@ -121,6 +121,7 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 				r.delVar(arg)
 			} else if _, ok := r.Funcs[arg]; ok && funcs {
 				delete(r.Funcs, arg)
+				delete(r.exportedFuncs, arg)
 			}
 		}
 	case "echo":
@@ -151,23 +152,57 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 			r.out("\n")
 		}
 	case "printf":
+		fp := flagParser{remaining: args}
+		varRef := ""
+		for fp.more() {
+			switch flag := fp.flag(); flag {
+			case "-v":
+				varRef = fp.value()
+				if varRef == "" {
+					r.errf("printf: -v: option requires an argument\n")
+					return 2
+				}
+			default:
+				r.errf("printf: %s: invalid option\n", flag)
+				r.errf("usage: printf [-v var] format [arguments]\n")
+				return 2
+			}
+		}
+		args := fp.args()
 		if len(args) == 0 {
-			r.errf("usage: printf format [arguments]\n")
+			r.errf("usage: printf [-v var] format [arguments]\n")
 			return 2
 		}
+		var name string
+		var index syntax.ArithmExpr
+		if varRef != "" {
+			var ok bool
+			if name, index, ok = r.parseVarRef(varRef); !ok {
+				r.errf("printf: %q: not a valid identifier\n", varRef)
+				return 2
+			}
+		}
 		format, args := args[0], args[1:]
+		var sb strings.Builder
 		for {
 			s, n, err := expand.Format(r.ecfg, format, args)
 			if err != nil {
 				r.errf("%v\n", err)
 				return 1
 			}
-			r.out(s)
+			if name != "" {
+				sb.WriteString(s)
+			} else {
+				r.out(s)
+			}
 			args = args[n:]
 			if n == 0 || len(args) == 0 {
 				break
 			}
 		}
+		if name != "" {
+			r.setVar(name, index, expand.Variable{Kind: expand.String, Str: sb.String()})
+		}
 	case "break", "continue":
 		if !r.inLoop {
 			r.errf("%s is only useful in a loop\n", name)
@@ -206,12 +241,14 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		}
 		pwd := r.envGet("PWD")
 		if evalSymlinks {
-			var err error
-			pwd, err = filepath.EvalSymlinks(pwd)
+			// TODO: this bypasses the stat handler, as there is no handler
+			// to read symlinks with yet.
+			path, err := filepath.EvalSymlinks(r.nativePath(pwd))
 			if err != nil {
 				r.setErr(err)
 				return 1
 			}
+			pwd = r.shellPath(path)
 		}
 		r.outf("%s\n", pwd)
 	case "cd":
@@ -234,12 +271,188 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		}
 		return r.changeDir(ctx, path)
 	case "wait":
-		if len(args) > 0 {
-			panic("wait with args not handled yet")
+		if len(args) == 0 {
+			// Stopped jobs are left alone, as they would never finish.
+			for _, j := range slices.Clone(r.jobs) {
+				if j.stopped != nil {
+					continue
+				}
+				<-j.done
+				if _, ok := IsExitStatus(j.err); j.err != nil && !ok {
+					r.setErr(j.err)
+				}
+				r.removeJob(j)
+			}
+			break
+		}
+		// Like in Bash, the status is the one of the last job.
+		exit := 0
+		for _, spec := range args {
+			j := r.findJob(spec)
+			if j == nil {
+				if strings.HasPrefix(spec, "%") {
+					r.errf("wait: %s: no such job\n", spec)
+				} else {
+					r.errf("wait: pid %s is not a child of this shell\n", spec)
+				}
+				exit = 127
+				continue
+			}
+			if j.stopped != nil {
+				exit = 128 + 20 // SIGTSTP
+				continue
+			}
+			<-j.done
+			r.removeJob(j)
+			status, ok := IsExitStatus(j.err)
+			if j.err != nil && !ok {
+				r.setErr(j.err)
+			}
+			exit = int(status)
+		}
+		return exit
+	case "jobs":
+		long, pidsOnly, running, stopped := false, false, false, false
+		fp := flagParser{remaining: args}
+		for fp.more() {
+			switch flag := fp.flag(); flag {
+			case "-l":
+				long = true
+			case "-p":
+				pidsOnly = true
+			case "-r":
+				running = true
+			case "-s":
+				stopped = true
+			default:
+				r.errf("jobs: invalid option %q\n", flag)
+				return 2
+			}
+		}
+		filter := func(jobs []*job) []*job {
+			return slices.DeleteFunc(jobs, func(j *job) bool {
+				state := j.info().State
+				return (running && state != JobRunning) || (stopped && state != JobStopped)
+			})
+		}
+		args := fp.args()
+		if len(args) == 0 {
+			r.printJobs(filter(slices.Clone(r.jobs)), long, pidsOnly)
+			break
+		}
+		exit := 0
+		for _, spec := range args {
+			j := r.findJob(spec)
+			if j == nil {
+				r.errf("jobs: %s: no such job\n", spec)
+				exit = 1
+				continue
+			}
+			r.printJobs(filter([]*job{j}), long, pidsOnly)
+		}
+		return exit
+	case "fg", "bg":
+		if !r.jobControl {
+			r.errf("%s: no job control\n", name)
+			return 1
+		}
+		spec := "%+"
+		switch len(args) {
+		case 0:
+		case 1:
+			spec = args[0]
+		default:
+			r.errf("%s: too many arguments\n", name)
+			return 2
+		}
+		j := r.findJob(spec)
+		if j == nil {
+			if len(args) == 0 {
+				spec = "current"
+			}
+			r.errf("%s: %s: no such job\n", name, spec)
+			return 1
+		}
+		if name == "fg" {
+			r.foreground(ctx, j)
+			return r.exit
 		}
-		err := r.bgShells.Wait()
-		if _, ok := IsExitStatus(err); err != nil && !ok {
-			r.setErr(err)
+		r.background(ctx, j)
+		return r.exit
+	case "disown":
+		all, running, keep := false, false, false
+		fp := flagParser{remaining: args}
+		for fp.more() {
+			switch flag := fp.flag(); flag {
+			case "-a":
+				all = true
+			case "-r":
+				running = true
+			case "-h":
+				// Jobs are never sent SIGHUP, so there is nothing to do
+				// besides keeping the jobs in the table.
+				keep = true
+			default:
+				r.errf("disown: invalid option %q\n", flag)
+				return 2
+			}
+		}
+		args := fp.args()
+		var jobs []*job
+		switch {
+		case all || (running && len(args) == 0):
+			jobs = slices.Clone(r.jobs)
+		case len(args) == 0:
+			j := r.findJob("%+")
+			if j == nil {
+				r.errf("disown: current: no such job\n")
+				return 1
+			}
+			jobs = append(jobs, j)
+		}
+		exit := 0
+		for _, spec := range args {
+			j := r.findJob(spec)
+			if j == nil {
+				r.errf("disown: %s: no such job\n", spec)
+				exit = 1
+				continue
+			}
+			jobs = append(jobs, j)
+		}
+		for _, j := range jobs {
+			if !keep && !(running && j.stopped != nil) {
+				r.removeJob(j)
+			}
+		}
+		return exit
+	case "suspend":
+		force := false
+		fp := flagParser{remaining: args}
+		for fp.more() {
+			switch flag := fp.flag(); flag {
+			case "-f":
+				force = true
+			default:
+				r.errf("suspend: invalid option %q\n", flag)
+				return 2
+			}
+		}
+		if len(fp.args()) > 0 {
+			r.errf("suspend: too many arguments\n")
+			return 2
+		}
+		if !r.jobControl {
+			r.errf("suspend: cannot suspend: no job control\n")
+			return 1
+		}
+		if _, login := r.optByName("login_shell", true); *login && !force {
+			r.errf("suspend: cannot suspend a login shell\n")
+			return 1
+		}
+		if err := suspendShell(); err != nil {
+			r.errf("suspend: %v\n", err)
+			return 1
 		}
 	case "builtin":
 		if len(args) < 1 {
@@ -268,7 +481,7 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		args := fp.args()
 		for _, arg := range args {
 			if mode == "-p" {
-				if path, err := LookPathDir(r.Dir, r.writeEnv, arg); err == nil {
+				if path, err := r.lookPath(ctx, arg); err == nil {
 					r.outf("%s\n", path)
 				} else {
 					anyNotFound = true
@@ -317,7 +530,7 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 				}
 				continue
 			}
-			if path, err := LookPathDir(r.Dir, r.writeEnv, arg); err == nil {
+			if path, err := r.lookPath(ctx, arg); err == nil {
 				if mode == "-t" {
 					r.out("file\n")
 				} else {
@@ -334,6 +547,10 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 			return 1
 		}
 	case "eval":
+		if r.policy.NoEval {
+			r.setErr(&PolicyError{Pos: pos, Action: "eval"})
+			return 1
+		}
 		src := strings.Join(args, " ")
 		p := syntax.NewParser()
 		file, err := p.Parse(strings.NewReader(src), "")
@@ -348,7 +565,7 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 			r.errf("%v: source: need filename\n", pos)
 			return 2
 		}
-		path, err := scriptFromPathDir(r.Dir, r.writeEnv, args[0])
+		path, err := r.scriptFromPath(ctx, args[0])
 		if err != nil {
 			// If the script was not found in PATH or there was any error, pass
 			// the source path to the open handler so it has a chance to look
@@ -356,6 +573,10 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 			// it to look for the sourced script in the current directory.
 			path = args[0]
 		}
+		if allow := r.policy.AllowSource; allow != nil && !allow(r.absPath(path)) {
+			r.setErr(&PolicyError{Pos: pos, Action: "source " + r.absPath(path)})
+			return 1
+		}
 		f, err := r.open(ctx, path, os.O_RDONLY, 0, false)
 		if err != nil {
 			r.errf("source: %v\n", err)
@@ -462,7 +683,7 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 			last = 0
 			if r.Funcs[arg] != nil || isBuiltin(arg) {
 				r.outf("%s\n", arg)
-			} else if path, err := LookPathDir(r.Dir, r.writeEnv, arg); err == nil {
+			} else if path, err := r.lookPath(ctx, arg); err == nil {
 				r.outf("%s\n", path)
 			} else {
 				last = 1
@@ -562,9 +783,10 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		}
 		r.setErr(returnStatus(code))
 	case "read":
-		var prompt string
+		var prompt, arrayName string
 		raw := false
 		silent := false
+		delim := byte('\n')
 		fp := flagParser{remaining: args}
 		for fp.more() {
 			switch flag := fp.flag(); flag {
@@ -578,6 +800,23 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 					r.errf("read: -p: option requires an argument\n")
 					return 2
 				}
+			case "-d":
+				if len(fp.remaining) == 0 {
+					r.errf("read: -d: option requires an argument\n")
+					return 2
+				}
+				// Like in Bash, an empty delimiter means a NUL byte,
+				// such as with "find -print0".
+				delim = 0
+				if value := fp.value(); value != "" {
+					delim = value[0]
+				}
+			case "-a":
+				if len(fp.remaining) == 0 {
+					r.errf("read: -a: option requires an argument\n")
+					return 2
+				}
+				arrayName = fp.value()
 			default:
 				r.errf("read: invalid option %q\n", flag)
 				return 2
@@ -585,6 +824,10 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		}
 
 		args := fp.args()
+		if arrayName != "" {
+			// Like in Bash, any other names are ignored.
+			args = []string{arrayName}
+		}
 		for _, name := range args {
 			if !syntax.ValidName(name) {
 				r.errf("read: invalid identifier %q\n", name)
@@ -598,16 +841,39 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 
 		var line []byte
 		var err error
-		if silent {
-			line, err = term.ReadPassword(int(syscall.Stdin))
+		if f, ok := r.stdin.(interface{ Fd() uintptr }); silent && ok && term.IsTerminal(int(f.Fd())) {
+			// Like in Bash, the input is not echoed back,
+			// which is useful for passwords.
+			line, err = term.ReadPassword(int(f.Fd()))
 		} else {
-			line, err = r.readLine(ctx, raw)
-		}
-		if len(args) == 0 {
+			line, err = r.readLine(ctx, raw, delim)
+		}
+		var values []string
+		if arrayName != "" {
+			values = expand.ReadFields(r.ecfg, string(line), -1, raw)
+			r.setVar(arrayName, nil, expand.Variable{Kind: expand.Indexed, List: values})
+			args = nil
+		} else if len(args) == 0 {
+			// Like in Bash, $REPLY is not split into fields,
+			// so leading and trailing IFS characters are kept.
 			args = append(args, shellReplyVar)
+			reply := string(line)
+			if !raw {
+				var sb strings.Builder
+				for i := 0; i < len(reply); i++ {
+					if reply[i] == '\\' {
+						if i++; i == len(reply) {
+							break
+						}
+					}
+					sb.WriteByte(reply[i])
+				}
+				reply = sb.String()
+			}
+			values = []string{reply}
+		} else {
+			values = expand.ReadFields(r.ecfg, string(line), len(args), raw)
 		}
-
-		values := expand.ReadFields(r.ecfg, string(line), len(args), raw)
 		for i, name := range args {
 			val := ""
 			if i < len(values) {
@@ -823,6 +1089,10 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		if callback == "-" {
 			callback = ""
 		}
+		if callback != "" && r.policy.NoEval {
+			r.setErr(&PolicyError{Pos: pos, Action: "trap"})
+			return 1
+		}
 		for _, arg := range args {
 			switch arg {
 			case "ERR":
@@ -893,7 +1163,7 @@ func (r *Runner) builtinCode(ctx context.Context, pos syntax.Pos, name string, a
 		return 0
 
 	default:
-		// "umask", "fg", "bg",
+		// "umask",
 		r.errf("%s: unimplemented builtin\n", name)
 		return 2
 	}
@@ -933,7 +1203,10 @@ func (r *Runner) printOptLine(name string, enabled, supported bool) {
 	r.outf("%s\t%s\t(%q not supported)\n", name, state, r.optStatusText(!enabled))
 }
 
-func (r *Runner) readLine(ctx context.Context, raw bool) ([]byte, error) {
+// readLine reads from standard input until the delimiter byte, which is not
+// included in the result. Unless raw is set, backslash escapes are kept for
+// [expand.ReadFields], and backslash-newline pairs continue the line.
+func (r *Runner) readLine(ctx context.Context, raw bool, delim byte) ([]byte, error) {
 	if r.stdin == nil {
 		return nil, errors.New("interp: can't read, there's no stdin")
 	}
@@ -941,37 +1214,43 @@ func (r *Runner) readLine(ctx context.Context, raw bool) ([]byte, error) {
 	var line []byte
 	esc := false
 
-	stopc := make(chan struct{})
-	stop := context.AfterFunc(ctx, func() {
-		r.stdin.SetReadDeadline(time.Now())
-		close(stopc)
-	})
-	defer func() {
-		if !stop() {
-			// The AfterFunc was started.
-			// Wait for it to complete, and reset the file's deadline.
-			<-stopc
-			r.stdin.SetReadDeadline(time.Time{})
-		}
-	}()
+	// Reads from an [*os.File] can be cancelled via a deadline.
+	if f, ok := r.stdin.(interface{ SetReadDeadline(time.Time) error }); ok {
+		stopc := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			f.SetReadDeadline(time.Now())
+			close(stopc)
+		})
+		defer func() {
+			if !stop() {
+				// The AfterFunc was started.
+				// Wait for it to complete, and reset the file's deadline.
+				<-stopc
+				f.SetReadDeadline(time.Time{})
+			}
+		}()
+	}
 	for {
 		var buf [1]byte
 		n, err := r.stdin.Read(buf[:])
 		if n > 0 {
 			b := buf[0]
 			switch {
-			case !raw && b == '\\':
-				line = append(line, b)
-				esc = !esc
-			case !raw && b == '\n' && esc:
+			case esc && b == '\n':
 				// line continuation
-				line = line[len(line)-1:]
+				line = line[:len(line)-1]
+				esc = false
+			case esc:
+				// An escaped delimiter does not end the line.
+				line = append(line, b)
 				esc = false
-			case b == '\n':
+			case !raw && b == '\\':
+				line = append(line, b)
+				esc = true
+			case b == delim:
 				return line, nil
 			default:
 				line = append(line, b)
-				esc = false
 			}
 		}
 		if err != nil {
@@ -987,12 +1266,12 @@ func (r *Runner) changeDir(ctx context.Context, path string) int {
 	if err != nil || !info.IsDir() {
 		return 1
 	}
-	if !hasPermissionToDir(path) {
+	if !hasPermissionToDir(info) {
 		return 1
 	}
 	r.Dir = path
 	r.setVarString("OLDPWD", r.envGet("PWD"))
-	r.setVarString("PWD", path)
+	r.setVarString("PWD", r.shellPath(path))
 	return 0
 }
 
@@ -1007,7 +1286,7 @@ func absPath(dir, path string) string {
 }
 
 func (r *Runner) absPath(path string) string {
-	return absPath(r.Dir, path)
+	return absPath(r.Dir, r.nativePath(path))
 }
 
 // flagParser is used to parse builtin flags.
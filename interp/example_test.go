@@ -38,6 +38,18 @@ func Example() {
 	// global_value
 }
 
+func ExampleRunner_Output() {
+	src := "echo foo; echo bar >&2"
+	file, _ := syntax.NewParser().Parse(strings.NewReader(src), "")
+	runner, _ := interp.New()
+	stdout, stderr, err := runner.Output(context.TODO(), file)
+	fmt.Printf("stdout: %q\nstderr: %q\nerror: %v\n", stdout, stderr, err)
+	// Output:
+	// stdout: "foo\n"
+	// stderr: "bar\n"
+	// error: <nil>
+}
+
 func ExampleExecHandlers() {
 	src := "echo foo; join ! foo bar baz; missing-program bar"
 	file, _ := syntax.NewParser().Parse(strings.NewReader(src), "")
@@ -91,3 +103,46 @@ func ExampleOpenHandler() {
 	// Output:
 	// foo
 }
+
+func ExampleOpenHandlers() {
+	src := "read line </etc/motd; echo $line; echo bar >/dev/null"
+	file, _ := syntax.NewParser().Parse(strings.NewReader(src), "")
+
+	openVirtual := func(next interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+		return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+			if path == "/etc/motd" {
+				return nopCloser{strings.NewReader("hello, world\n")}, nil
+			}
+			return next(ctx, path, flag, perm)
+		}
+	}
+	openDevNull := func(next interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+		return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+			if runtime.GOOS == "windows" && path == "/dev/null" {
+				path = "NUL"
+			}
+			return next(ctx, path, flag, perm)
+		}
+	}
+	openLog := func(next interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+		return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+			fmt.Printf("opening %s\n", path)
+			return next(ctx, path, flag, perm)
+		}
+	}
+	runner, _ := interp.New(
+		interp.StdIO(nil, os.Stdout, os.Stdout),
+		interp.OpenHandlers(openLog, openVirtual, openDevNull),
+	)
+	runner.Run(context.TODO(), file)
+	// Output:
+	// opening /etc/motd
+	// hello, world
+	// opening /dev/null
+}
+
+// nopCloser turns a reader into an [io.ReadWriteCloser] which cannot be written to.
+type nopCloser struct{ io.Reader }
+
+func (nopCloser) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
+func (nopCloser) Close() error                { return nil }
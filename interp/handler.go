@@ -13,6 +13,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -48,6 +49,10 @@ type HandlerContext struct {
 	Stdout io.Writer
 	// Stderr is the interpreter's current standard error writer.
 	Stderr io.Writer
+
+	// jobControl is set when foreground processes may be stopped,
+	// such as via Ctrl-Z in an interactive shell.
+	jobControl bool
 }
 
 // CallHandlerFunc is a handler which runs on every [syntax.CallExpr].
@@ -89,17 +94,40 @@ type ExecHandlerFunc func(ctx context.Context, args []string) error
 // On Windows, the kill signal is always sent immediately,
 // because Go doesn't currently support sending Interrupt on Windows.
 // [Runner] defaults to a killTimeout of 2 seconds.
+//
+// When the [Runner] is [Interactive], a process which is stopped while running
+// in the foreground, such as via Ctrl-Z, is added to the job table so that it
+// can be resumed later. This is currently only supported on Linux.
+//
+// On js/wasm and wasip1/wasm, where processes cannot be started, the handler
+// always fails with exit status 127. Programs targeting those platforms should
+// use [ExecHandlers] to implement the commands which their scripts may run.
 func DefaultExecHandler(killTimeout time.Duration) ExecHandlerFunc {
 	return func(ctx context.Context, args []string) error {
 		hc := HandlerCtx(ctx)
+		if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+			fmt.Fprintf(hc.Stderr, "%s: cannot run programs on %s\n", args[0], runtime.GOOS)
+			return NewExitStatus(127)
+		}
 		path, err := LookPathDir(hc.Dir, hc.Env, args[0])
 		if err != nil {
 			fmt.Fprintln(hc.Stderr, err)
 			return NewExitStatus(127)
 		}
+		cmdArgs := args
+		if runtime.GOOS == "windows" {
+			if scriptArgs := winScriptArgs(path, args); scriptArgs != nil {
+				// The script is run by another program, found in PATH.
+				cmdArgs = scriptArgs
+				if path, err = LookPathDir(hc.Dir, hc.Env, cmdArgs[0]); err != nil {
+					fmt.Fprintln(hc.Stderr, err)
+					return NewExitStatus(127)
+				}
+			}
+		}
 		cmd := exec.Cmd{
 			Path:   path,
-			Args:   args,
+			Args:   cmdArgs,
 			Env:    execEnv(hc.Env),
 			Dir:    hc.Dir,
 			Stdin:  hc.Stdin,
@@ -122,36 +150,105 @@ func DefaultExecHandler(killTimeout time.Duration) ExecHandlerFunc {
 			})
 			defer stopf()
 
-			err = cmd.Wait()
+			return waitCmd(ctx, hc, &cmd)
 		}
+		if isExecFormatError(err) {
+			return &shellScript{path: path}
+		}
+		return execError(ctx, hc, err)
+	}
+}
 
-		switch err := err.(type) {
-		case *exec.ExitError:
-			// Windows and Plan9 do not have support for [syscall.WaitStatus]
-			// with methods like Signaled and Signal, so for those, [waitStatus] is a no-op.
-			// Note: [waitStatus] is an alias [syscall.WaitStatus]
-			if status, ok := err.Sys().(waitStatus); ok && status.Signaled() {
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-				return NewExitStatus(uint8(128 + status.Signal()))
+// winScriptRunners holds the programs which run script files on Windows
+// whose extensions may be listed in PATHEXT, but which the system cannot
+// execute directly. Note that ".bat" and ".cmd" files can be executed directly.
+var winScriptRunners = map[string][]string{
+	".ps1": {"powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File"},
+	".vbs": {"cscript", "//Nologo"},
+	".js":  {"cscript", "//Nologo"},
+	".wsf": {"cscript", "//Nologo"},
+}
+
+// winScriptArgs returns the arguments to run a script file on Windows via one of
+// [winScriptRunners], or nil if the file can be executed directly.
+func winScriptArgs(path string, args []string) []string {
+	runner := winScriptRunners[strings.ToLower(filepath.Ext(path))]
+	if runner == nil {
+		return nil
+	}
+	scriptArgs := append(slices.Clone(runner), path)
+	return append(scriptArgs, args[1:]...)
+}
+
+// shellScript is returned by [DefaultExecHandler] when a file cannot be
+// executed directly, such as a script without a shebang line. Like Bash,
+// the runner then interprets the file as a shell script in a new shell.
+type shellScript struct {
+	path string
+}
+
+func (*shellScript) Error() string { return "exec format error" }
+
+// stoppedProcess is returned by [DefaultExecHandler] when a foreground process
+// is stopped with job control enabled. It can be resumed via [continueProcess]
+// and then waited on again.
+type stoppedProcess struct {
+	cmd *exec.Cmd
+	hc  HandlerContext
+}
+
+func (*stoppedProcess) Error() string { return "process stopped" }
+
+// wait waits for a resumed process like [DefaultExecHandler] does.
+// If jobControl is true, the process may be stopped again.
+func (sp *stoppedProcess) wait(ctx context.Context, jobControl bool) error {
+	hc := sp.hc
+	hc.jobControl = jobControl
+	return waitCmd(ctx, hc, sp.cmd)
+}
+
+// waitCmd waits for a started command to finish, or to be stopped if job
+// control is enabled, and returns its result like an [ExecHandlerFunc].
+func waitCmd(ctx context.Context, hc HandlerContext, cmd *exec.Cmd) error {
+	if hc.jobControl && waitStopped(cmd.Process) {
+		return &stoppedProcess{cmd: cmd, hc: hc}
+	}
+	return execError(ctx, hc, cmd.Wait())
+}
+
+// execError turns the error from starting or waiting for a command
+// into an exit status, where appropriate.
+func execError(ctx context.Context, hc HandlerContext, err error) error {
+	switch err := err.(type) {
+	case *exec.ExitError:
+		// Windows and Plan9 do not have support for [syscall.WaitStatus]
+		// with methods like Signaled and Signal, so for those, [waitStatus] is a no-op.
+		// Note: [waitStatus] is an alias [syscall.WaitStatus]
+		if status, ok := err.Sys().(waitStatus); ok && status.Signaled() {
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-			return NewExitStatus(uint8(err.ExitCode()))
-		case *exec.Error:
-			// did not start
-			fmt.Fprintf(hc.Stderr, "%v\n", err)
-			return NewExitStatus(127)
-		default:
-			return err
+			return NewExitStatus(uint8(128 + status.Signal()))
 		}
+		return NewExitStatus(uint8(err.ExitCode()))
+	case *exec.Error:
+		// did not start
+		fmt.Fprintf(hc.Stderr, "%v\n", err)
+		return NewExitStatus(127)
+	default:
+		return err
 	}
 }
 
-func checkStat(dir, file string, checkExec bool) (string, error) {
+// statFunc is a function like [os.Stat], which allows looking up programs
+// via a [StatHandlerFunc] rather than the operating system directly.
+type statFunc = func(name string) (fs.FileInfo, error)
+
+func checkStat(stat statFunc, dir, file string, checkExec bool) (string, error) {
 	if !filepath.IsAbs(file) {
 		file = filepath.Join(dir, file)
 	}
-	info, err := os.Stat(file)
+	info, err := stat(file)
 	if err != nil {
 		return "", err
 	}
@@ -174,19 +271,19 @@ func winHasExt(file string) bool {
 }
 
 // findExecutable returns the path to an existing executable file.
-func findExecutable(dir, file string, exts []string) (string, error) {
+func findExecutable(stat statFunc, dir, file string, exts []string) (string, error) {
 	if len(exts) == 0 {
 		// non-windows
-		return checkStat(dir, file, true)
+		return checkStat(stat, dir, file, true)
 	}
 	if winHasExt(file) {
-		if file, err := checkStat(dir, file, true); err == nil {
+		if file, err := checkStat(stat, dir, file, true); err == nil {
 			return file, nil
 		}
 	}
 	for _, e := range exts {
 		f := file + e
-		if f, err := checkStat(dir, f, true); err == nil {
+		if f, err := checkStat(stat, dir, f, true); err == nil {
 			return f, nil
 		}
 	}
@@ -194,8 +291,8 @@ func findExecutable(dir, file string, exts []string) (string, error) {
 }
 
 // findFile returns the path to an existing file.
-func findFile(dir, file string, _ []string) (string, error) {
-	return checkStat(dir, file, false)
+func findFile(stat statFunc, dir, file string, _ []string) (string, error) {
+	return checkStat(stat, dir, file, false)
 }
 
 // LookPath is deprecated; see [LookPathDir].
@@ -207,20 +304,23 @@ func LookPath(env expand.Environ, file string) (string, error) {
 // provided environment. env is used to fetch relevant environment variables
 // such as PWD and PATH.
 //
+// On Windows, PATH may also be an MSYS-style list such as "/c/bin:/usr/bin",
+// in which case its elements are translated into native paths.
+//
 // If no error is returned, the returned path must be valid.
 func LookPathDir(cwd string, env expand.Environ, file string) (string, error) {
-	return lookPathDir(cwd, env, file, findExecutable)
+	return lookPathDir(cwd, env, file, os.Stat, findExecutable)
 }
 
 // findAny defines a function to pass to [lookPathDir].
-type findAny = func(dir string, file string, exts []string) (string, error)
+type findAny = func(stat statFunc, dir string, file string, exts []string) (string, error)
 
-func lookPathDir(cwd string, env expand.Environ, file string, find findAny) (string, error) {
+func lookPathDir(cwd string, env expand.Environ, file string, stat statFunc, find findAny) (string, error) {
 	if find == nil {
 		panic("no find function found")
 	}
 
-	pathList := filepath.SplitList(env.Get("PATH").String())
+	pathList := splitPathList(env.Get("PATH").String())
 	if len(pathList) == 0 {
 		pathList = []string{""}
 	}
@@ -230,7 +330,7 @@ func lookPathDir(cwd string, env expand.Environ, file string, find findAny) (str
 	}
 	exts := pathExts(env)
 	if strings.ContainsAny(file, chars) {
-		return find(cwd, file, exts)
+		return find(stat, cwd, file, exts)
 	}
 	for _, elem := range pathList {
 		var path string
@@ -241,17 +341,23 @@ func lookPathDir(cwd string, env expand.Environ, file string, find findAny) (str
 		default:
 			path = filepath.Join(elem, file)
 		}
-		if f, err := find(cwd, path, exts); err == nil {
+		if f, err := find(stat, cwd, path, exts); err == nil {
 			return f, nil
 		}
 	}
 	return "", fmt.Errorf("%q: executable file not found in $PATH", file)
 }
 
-// scriptFromPathDir is similar to [LookPathDir], with the difference that it looks
+// lookPath is like [LookPathDir], but it uses the runner's stat handler
+// so that builtins like "type" see the same files as redirections do.
+func (r *Runner) lookPath(ctx context.Context, file string) (string, error) {
+	return lookPathDir(r.Dir, r.writeEnv, file, r.statFunc(ctx), findExecutable)
+}
+
+// scriptFromPath is similar to [Runner.lookPath], with the difference that it looks
 // for both executable and non-executable files.
-func scriptFromPathDir(cwd string, env expand.Environ, file string) (string, error) {
-	return lookPathDir(cwd, env, file, findFile)
+func (r *Runner) scriptFromPath(ctx context.Context, file string) (string, error) {
+	return lookPathDir(r.Dir, r.writeEnv, file, r.statFunc(ctx), findFile)
 }
 
 func pathExts(env expand.Environ) []string {
@@ -282,12 +388,22 @@ func pathExts(env expand.Environ) []string {
 // The path parameter may be relative to the current directory,
 // which can be fetched via [HandlerCtx].
 //
+// The flag and perm parameters follow [os.OpenFile]. For example, "<" and
+// "source" use [os.O_RDONLY], ">" uses [os.O_WRONLY], [os.O_CREATE], and
+// [os.O_TRUNC], and ">>" uses [os.O_APPEND] instead of [os.O_TRUNC].
+// The perm parameter is 0o644 for redirections, and zero otherwise.
+// Like with [os.OpenFile], perm is given before the umask is applied;
+// implementations which do not create files via the operating system
+// should apply a umask themselves where it matters.
+//
 // Use a return error of type [*os.PathError] to have the error printed to
 // stderr and the exit status set to 1. If the error is of any other type, the
 // interpreter will come to a stop.
 //
 // Note that implementations which do not return [os.File] will cause
 // extra files and goroutines for input redirections; see [StdIO].
+//
+// Multiple handlers can be composed as middlewares via [OpenHandlers].
 type OpenHandlerFunc func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error)
 
 // DefaultOpenHandler returns the [OpenHandlerFunc] used by default.
@@ -327,6 +443,12 @@ func DefaultReadDirHandler2() ReadDirHandlerFunc2 {
 }
 
 // StatHandlerFunc is a handler which gets a file's information.
+// It is used for all files which the shell inspects directly, such as in
+// test expressions like "[[ -f path ]]", when changing directories with
+// "cd" or "pushd", and when looking up programs for builtins like "type".
+// Lookups done by [DefaultExecHandler] to run programs are not included.
+//
+// The current directory can be fetched via [HandlerCtx].
 type StatHandlerFunc func(ctx context.Context, name string, followSymlinks bool) (fs.FileInfo, error)
 
 // DefaultStatHandler returns the [StatHandlerFunc] used by default.
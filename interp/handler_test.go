@@ -11,12 +11,15 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"mvdan.cc/sh/v3/interp"
@@ -60,6 +63,25 @@ func execPrint(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
 	}
 }
 
+func openLog(next interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+	return func(ctx context.Context, path string, flags int, mode os.FileMode) (io.ReadWriteCloser, error) {
+		hc := interp.HandlerCtx(ctx)
+		fmt.Fprintf(hc.Stderr, "open: %s\n", path)
+		return next(ctx, path, flags, mode)
+	}
+}
+
+func openDevNullAs(path string) func(interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+	return func(next interp.OpenHandlerFunc) interp.OpenHandlerFunc {
+		return func(ctx context.Context, p string, flags int, mode os.FileMode) (io.ReadWriteCloser, error) {
+			if p == path {
+				p = "/dev/null"
+			}
+			return next(ctx, p, flags, mode)
+		}
+	}
+}
+
 // runnerCtx allows us to give handler functions access to the Runner, if needed.
 var runnerCtx = new(int)
 
@@ -175,6 +197,34 @@ var modCases = []struct {
 		src:  "echo foo >/dev/null; echo bar >/tmp/x",
 		want: "non-dev: /tmp/x",
 	},
+	{
+		name: "OpenLogAndForbidNonDev",
+		opts: []interp.RunnerOption{
+			interp.OpenHandler(blocklistNondevOpen),
+			interp.OpenHandlers(openLog),
+		},
+		src:  "echo foo >/dev/null; echo bar >/tmp/x",
+		want: "open: /dev/null\nopen: /tmp/x\nnon-dev: /tmp/x",
+	},
+	{
+		name: "OpenRewriteAndLog",
+		opts: []interp.RunnerOption{
+			interp.OpenHandler(blocklistNondevOpen),
+			interp.OpenHandlers(openDevNullAs("/tmp/x"), openLog),
+		},
+		src:  "echo foo >/tmp/x; echo bar >/tmp/y",
+		want: "open: /dev/null\nopen: /tmp/y\nnon-dev: /tmp/y",
+	},
+	{
+		name: "OpenLogAndRewriteSeparate",
+		opts: []interp.RunnerOption{
+			interp.OpenHandlers(openLog),
+			interp.OpenHandlers(openDevNullAs("/tmp/x")),
+			interp.OpenHandler(blocklistNondevOpen),
+		},
+		src:  "echo foo >/tmp/x; echo bar >/tmp/y",
+		want: "open: /tmp/x\nopen: /tmp/y\nnon-dev: /tmp/y",
+	},
 	{
 		name: "CallReplaceWithBlank",
 		opts: []interp.RunnerOption{
@@ -209,6 +259,49 @@ var modCases = []struct {
 		src:  "echo foo; echo foo bar",
 		want: "foo\nrefusing to run echo builtin with multiple args",
 	},
+	{
+		name: "PolicyNoEval",
+		opts: []interp.RunnerOption{
+			interp.Restrict(interp.Policy{NoEval: true}),
+		},
+		src:  "echo foo; (eval echo bar); echo baz",
+		want: "foo\n1:12: eval is not allowed",
+	},
+	{
+		name: "PolicyNoEvalTrap",
+		opts: []interp.RunnerOption{
+			interp.Restrict(interp.Policy{NoEval: true}),
+		},
+		src:  "trap - EXIT; echo foo; trap 'echo bar' EXIT; echo baz",
+		want: "foo\n1:24: trap is not allowed",
+	},
+	{
+		name: "PolicyNoCmdSubst",
+		opts: []interp.RunnerOption{
+			interp.Restrict(interp.Policy{NoCmdSubst: true}),
+		},
+		src:  "echo foo; echo bar >$(echo /dev/null); echo baz",
+		want: "foo\n1:21: command substitution is not allowed",
+	},
+	{
+		name: "PolicyNoProcSubst",
+		opts: []interp.RunnerOption{
+			interp.Restrict(interp.Policy{NoCmdSubst: true}),
+		},
+		src:  "echo foo; cat <(echo bar); echo baz",
+		want: "foo\n1:15: process substitution is not allowed",
+	},
+	{
+		name: "PolicyAllowSource",
+		opts: append(mapFSHandlers("/", fstest.MapFS{
+			"allowed.sh": {Data: []byte("echo sourced\n")},
+			"other.sh":   {Data: []byte("echo sourced\n")},
+		}), interp.Restrict(interp.Policy{AllowSource: func(path string) bool {
+			return path == "/allowed.sh"
+		}})),
+		src:  "source /allowed.sh; . /other.sh; echo baz",
+		want: "sourced\n1:21: source /other.sh is not allowed",
+	},
 	{
 		name: "GlobForbid",
 		opts: []interp.RunnerOption{
@@ -398,3 +491,202 @@ func TestKillSignal(t *testing.T) {
 		})
 	}
 }
+
+// execFake pretends to run programs, which print their arguments,
+// and fail if their first argument is "fail".
+func execFake(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		hc := interp.HandlerCtx(ctx)
+		fmt.Fprintf(hc.Stdout, "out: %s\n", strings.Join(args, " "))
+		if len(args) > 1 && args[1] == "fail" {
+			fmt.Fprintf(hc.Stderr, "err: %s\n", args[0])
+			return interp.NewExitStatus(3)
+		}
+		return nil
+	}
+}
+
+func TestExecRecordReplay(t *testing.T) {
+	t.Parallel()
+
+	p := syntax.NewParser()
+	run := func(t *testing.T, src string, opts ...interp.RunnerOption) (string, error) {
+		t.Helper()
+		var cb concBuffer
+		r, err := interp.New(append([]interp.RunnerOption{interp.StdIO(nil, &cb, &cb)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.Run(context.Background(), parse(t, p, src))
+		return cb.String(), err
+	}
+
+	const src = "foo a; bar fail || echo $?; foo a"
+	const want = "out: foo a\nout: bar fail\nerr: bar\n3\nout: foo a\n"
+	var rec interp.ExecRecorder
+	out, err := run(t, src, interp.ExecHandlers(rec.Middleware, execFake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != want {
+		t.Fatalf("want:\n%q\ngot:\n%q", want, out)
+	}
+	path := filepath.Join(t.TempDir(), "exec.json")
+	if err := rec.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	records, err := interp.ReadExecRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRecords := []interp.ExecRecord{
+		{Args: []string{"foo", "a"}, Stdout: "out: foo a\n"},
+		{Args: []string{"bar", "fail"}, Stdout: "out: bar fail\n", Stderr: "err: bar\n", Status: 3},
+		{Args: []string{"foo", "a"}, Stdout: "out: foo a\n"},
+	}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Fatalf("want records:\n%#v\ngot:\n%#v", wantRecords, records)
+	}
+
+	tests := []struct {
+		strict     bool
+		src        string
+		want       string
+		wantErr    string
+		wantUnused int
+	}{
+		{true, src, want, "", 0},
+		{false, src, want, "", 0},
+		{true, "foo a", "out: foo a\n", "", 2},
+		{true, "bar fail", "", `unexpected call: ["bar" "fail"], want ["foo" "a"]`, 3},
+		{true, src + "; foo a", want, `unexpected call after all recorded results: ["foo" "a"]`, 0},
+		{false, "bar fail; foo a; foo a; foo a", "out: bar fail\nerr: bar\n" + strings.Repeat("out: foo a\n", 3), "", 0},
+		{false, "foo b", "foo: no recorded result\n", "exit status 127", 3},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			rep := interp.NewExecReplayer(records, test.strict)
+			// The replayer must not call the fake handler.
+			out, err := run(t, test.src, interp.ExecHandlers(rep.Middleware, blocklistAllExec))
+			if out != test.want {
+				t.Fatalf("want:\n%q\ngot:\n%q", test.want, out)
+			}
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if err == nil || err.Error() != test.wantErr {
+				t.Fatalf("want error %q, got %v", test.wantErr, err)
+			}
+			if n := len(rep.Unused()); n != test.wantUnused {
+				t.Fatalf("want %d unused records, got %d", test.wantUnused, n)
+			}
+		})
+	}
+}
+
+// mapFSFile adapts a read-only [fs.File] to the interface returned by [interp.OpenHandlerFunc].
+type mapFSFile struct{ fs.File }
+
+func (mapFSFile) Write(p []byte) (int, error) { return 0, fs.ErrPermission }
+
+// mapFSHandlers returns open, stat, and read directory handlers
+// which serve the given files as if they were inside dir.
+// Any direct use of the operating system would not see the files.
+func mapFSHandlers(dir string, fsys fstest.MapFS) []interp.RunnerOption {
+	fsPath := func(op, path string) (string, error) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || !fs.ValidPath(filepath.ToSlash(rel)) {
+			return "", &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+		}
+		return filepath.ToSlash(rel), nil
+	}
+	return []interp.RunnerOption{
+		interp.OpenHandler(func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(interp.HandlerCtx(ctx).Dir, path)
+			}
+			name, err := fsPath("open", path)
+			if err != nil {
+				return nil, err
+			}
+			if flag != os.O_RDONLY {
+				return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+			}
+			f, err := fsys.Open(name)
+			if err != nil {
+				return nil, err
+			}
+			return mapFSFile{f}, nil
+		}),
+		interp.StatHandler(func(ctx context.Context, path string, followSymlinks bool) (fs.FileInfo, error) {
+			name, err := fsPath("stat", path)
+			if err != nil {
+				return nil, err
+			}
+			return fsys.Stat(name)
+		}),
+		interp.ReadDirHandler2(func(ctx context.Context, path string) ([]fs.DirEntry, error) {
+			name, err := fsPath("readdir", path)
+			if err != nil {
+				return nil, err
+			}
+			return fsys.ReadDir(name)
+		}),
+	}
+}
+
+func TestRunnerVirtualFS(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("the expected output uses Unix-like paths")
+	}
+
+	// An empty directory on disk, so that the script can only see its files
+	// if the runner consistently goes through its handlers.
+	dir := t.TempDir()
+	fsys := fstest.MapFS{
+		"file":            {Data: []byte("foo\n"), Mode: 0o644},
+		"bin/tool":        {Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+		"sub/nested/file": {Data: []byte("echo sourced $1\n"), Mode: 0o644},
+		"noexec":          {Mode: 0o644 | fs.ModeDir},
+	}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"[[ -e file ]] && [[ -f file ]] && [[ -s file ]] && echo yes", "yes\n"},
+		{"[[ -e missing ]] || [[ -f sub ]] || echo no", "no\n"},
+		{"[ -d sub ] && test -r file && echo yes", "yes\n"},
+		{"[[ -x bin/tool ]] && [[ ! -x file ]] && echo yes", "yes\n"},
+		{"read line <file; echo $line", "foo\n"},
+		{"echo *; echo sub/*/*", "bin file noexec sub\nsub/nested/file\n"},
+		{"cd sub/nested && echo ${PWD#$OLDPWD}", "/sub/nested\n"},
+		{"cd noexec || echo fail", "fail\n"},
+		{"pushd sub && popd", "DIR/sub DIR\nDIR\n"},
+		{"PATH=$PWD/bin; type tool; command -v tool", "tool is DIR/bin/tool\nDIR/bin/tool\n"},
+		{"PATH=$PWD/sub/nested; source file bar", "sourced bar\n"},
+		{"source sub/nested/file baz", "sourced baz\n"},
+	}
+	p := syntax.NewParser()
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			var cb concBuffer
+			opts := append([]interp.RunnerOption{
+				interp.Dir(dir),
+				interp.StdIO(nil, &cb, &cb),
+			}, mapFSHandlers(dir, fsys)...)
+			r, err := interp.New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Run(context.Background(), parse(t, p, test.src)); err != nil {
+				cb.WriteString(err.Error())
+			}
+			want := strings.ReplaceAll(test.want, "DIR", dir)
+			if got := cb.String(); got != want {
+				t.Fatalf("want:\n%q\ngot:\n%q", want, got)
+			}
+		})
+	}
+}
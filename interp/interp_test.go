@@ -82,6 +82,27 @@ let i=(2 + 3)
 	}
 }
 
+func BenchmarkRunPipeline(b *testing.B) {
+	for _, width := range []int{2, 8, 32} {
+		b.Run(fmt.Sprintf("Width%d", width), func(b *testing.B) {
+			b.ReportAllocs()
+			// Only builtins, so that we measure the pipeline plumbing.
+			src := "for i in 1 2 3 4 5 6 7 8; do echo $i; done" +
+				strings.Repeat(" | while read l; do echo $l; done", width-1)
+			file := parse(b, nil, src)
+			r, _ := interp.New(interp.StdIO(nil, io.Discard, io.Discard))
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Reset()
+				if err := r.Run(ctx, file); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 var hasBash52 bool
 
 func TestMain(m *testing.M) {
@@ -246,7 +267,7 @@ var runTests = []runTest{
 	{"false; exit", "exit status 1"},
 	{"exit; echo foo_interp_missing", ""},
 	{"exit 0; echo foo_interp_missing", ""},
-	{"printf", "usage: printf format [arguments]\nexit status 2 #JUSTERR"},
+	{"printf", "usage: printf [-v var] format [arguments]\nexit status 2 #JUSTERR"},
 	{"break", "break is only useful in a loop\n #JUSTERR"},
 	{"continue", "continue is only useful in a loop\n #JUSTERR"},
 	{"cd a b", "usage: cd [dir]\nexit status 2 #JUSTERR"},
@@ -308,6 +329,22 @@ var runTests = []runTest{
 	{`printf '0%s1' 'a\bc'`, `0a\bc1`},
 	{`printf '0%b1' 'a\bc'`, "0a\bc1"},
 	{"printf 'a%bc'", "ac"},
+	{"printf -v a '%s-%d' x 3; echo $a", "x-3\n"},
+	{"printf -v a '%s,' x y; echo $a", "x,y,\n"},
+	{"printf -v a ''; echo \"[$a]\"", "[]\n"},
+	{"a=(x y z); i=1; printf -v 'a[i+1]' %s foo; echo ${a[@]}", "x y foo\n"},
+	{"declare -A m; printf -v 'm[k]' %s foo; echo \"${m[k]}\"", "foo\n"},
+	{"printf -v", "printf: -v: option requires an argument\nexit status 2 #JUSTERR"},
+	{"printf -v 'a b' x", "printf: \"a b\": not a valid identifier\nexit status 2 #IGNORE"},
+	{"printf -x foo", "printf: -x: invalid option\nusage: printf [-v var] format [arguments]\nexit status 2 #IGNORE"},
+	{"printf -- -%s x", "-x"},
+	// Positional specifiers are supported by ksh93 and zsh, but not Bash.
+	{"printf '%2$s %1$s\n' a b", "b a\n #IGNORE"},
+	{"printf '%2$s %1$s|' a b c d", "b a|d c| #IGNORE"},
+	{"printf '%1$s%1$s %s|' a b", "aa a|bb b| #IGNORE"},
+	{"printf '%3$s|' a", "| #IGNORE"},
+	{"printf '%1$-3s|%2$03d|%1$c' ab 7", "ab |007|a #IGNORE"},
+	{"printf '%0$s' a", "invalid format char: $\nexit status 1 #JUSTERR"},
 
 	// words and quotes
 	{"echo  foo_interp_missing ", "foo_interp_missing\n"},
@@ -380,7 +417,11 @@ var runTests = []runTest{
 	},
 	{
 		"echo $(GOSH_CMD=foo_interp_missing_null_bar_interp_missing $GOSH_PROG)",
-		"foo_interp_missingbar_interp_missing\n #IGNORE",
+		"warning: command substitution: ignored null byte in input\nfoo_interp_missingbar_interp_missing\n #IGNORE",
+	},
+	{
+		"x=$(printf 'a\\0b\\n\\0\\n'); echo \"[$x]\"",
+		"warning: command substitution: ignored null byte in input\n[ab]\n #IGNORE bash adds a prefix",
 	},
 	// See the TODO where FOO_INTERP_MISSING_NULL_BAR_INTERP_MISSING is set.
 	// {
@@ -1372,6 +1413,22 @@ var runTests = []runTest{
 		"f() { echo 1; }; { sleep 0.01; f; } & f() { echo 2; }; wait",
 		"1\n",
 	},
+	{"true & disown; echo $?; wait", "0\n"},
+	{"true & true & disown %1 %2; disown", "disown: current: no such job\nexit status 1 #JUSTERR"},
+	{"true & disown -a; disown %1", "disown: %1: no such job\nexit status 1 #JUSTERR"},
+	{"true & disown -h; wait; echo $?", "0\n"},
+	{"disown -x", "disown: invalid option \"-x\"\nexit status 2 #JUSTERR"},
+	{"sleep 0.2 & jobs; jobs -r; jobs -s; jobs %1; wait", "[1]+  Running                 sleep 0.2 &\n[1]+  Running                 sleep 0.2 &\n[1]+  Running                 sleep 0.2 &\n"},
+	{"sleep 0.2 & sleep 0.2 & jobs %1 %2 %3; wait", "[1]-  Running                 sleep 0.2 &\n[2]+  Running                 sleep 0.2 &\njobs: %3: no such job\n #IGNORE bash adds a prefix"},
+	{"(exit 3) & wait %1; echo $?; sleep 0.01 & wait %%; echo $?", "3\n0\n"},
+	{"(exit 3) & false & wait %1 %2; echo $?; jobs", "1\n"},
+	{"(exit 3) & sleep 0.01; jobs; jobs", "[1]+  Exit 3                  (exit 3)\n #IGNORE bash reaps jobs when not interactive"},
+	{"wait %1", "wait: %1: no such job\nexit status 127 #JUSTERR"},
+	{"wait 12345", "wait: pid 12345 is not a child of this shell\nexit status 127 #JUSTERR"},
+	{"jobs -y", "jobs: invalid option \"-y\"\nexit status 2 #JUSTERR"},
+	{"fg", "fg: no job control\nexit status 1 #JUSTERR"},
+	{"true & bg %1", "bg: no job control\nexit status 1 #JUSTERR"},
+	{"suspend", "suspend: cannot suspend: no job control\nexit status 1 #JUSTERR"},
 
 	// bash test
 	{
@@ -1550,6 +1607,38 @@ var runTests = []runTest{
 		"[[ a =~ [ ]]",
 		"exit status 2",
 	},
+	{
+		"[[ ab =~ (a)(x)?b ]]; echo ${#BASH_REMATCH[@]} \"${BASH_REMATCH[@]}\"; [[ x =~ y ]]; echo ${#BASH_REMATCH[@]}",
+		"3 ab a \n0\n",
+	},
+	{
+		"[[ key=val =~ ^([a-z]+)=(.*)$ ]] && echo ${BASH_REMATCH[1]} ${BASH_REMATCH[2]}",
+		"key val\n",
+	},
+	{
+		"a=(x '' z); [[ -v a[1] && -v a[-1] && -v a[@] && ! -v a[3] ]]",
+		"",
+	},
+	{
+		"a=([2]=x); i=1; [[ -v a[i+1] && ! -v a[i] && ! -v a[0] ]]",
+		"",
+	},
+	{
+		"declare -A m=([k]=v); [[ -v m[k] && ! -v m[z] ]]",
+		"",
+	},
+	{
+		"s=x; [[ -v s[0] && ! -v s[1] && ! -v u[0] ]]",
+		"",
+	},
+	{
+		"a=(x); test -v 'a[0]' && test ! -v 'a[1]'",
+		"",
+	},
+	{
+		"[[ -N missing ]]",
+		"exit status 1",
+	},
 	{
 		"[[ -e a ]] && echo x; >a; [[ -e a ]] && echo y",
 		"y\n",
@@ -1642,6 +1731,10 @@ var runTests = []runTest{
 	{"set -n; [[ -o noexec ]]", ""}, // actually does nothing, but oh well
 	{"[[ -o pipefail ]]", "exit status 1"},
 	{"set -o pipefail; [[ -o pipefail ]]", ""},
+	{"[[ -o notify ]]", "exit status 1"},
+	{"set -b; [[ -o notify ]]", ""},
+	{"set -o ignoreeof; [[ -o ignoreeof ]]; echo $IGNOREEOF", "10\n"},
+	{"set -o ignoreeof; set +o ignoreeof; echo ${IGNOREEOF-unset}", "unset\n"},
 	// TODO: we don't implement precedence of && over ||.
 	// {"[[ a == x && b == x || c == c ]]", ""},
 	{"[[ (a == x && b == x) || c == c ]]", ""},
@@ -1825,6 +1918,38 @@ var runTests = []runTest{
 		"echo $((1 ? 2 : 3)) $((0 ? 2 : 3))",
 		"2 3\n",
 	},
+	{
+		"x=1; echo $((2 ? 3 : 4)) $((x ? 2 : x ? 3 : 4)) $((0 ? 1 : 0 ? 2 : 3)) $((1 ? 0 ? 5 : 6 : 7))",
+		"3 2 3 6\n",
+	},
+	{
+		"i=0; echo $((i++ ? i++ : i--)) $i; i=1; echo $((0 ? i++ : i)) $i",
+		"1 0\n1 1\n",
+	},
+	{
+		"i=5; echo $((1 || i++)) $((0 && i++)) $((0 || i++)) $((1 && i++)) $i",
+		"1 0 1 1 7\n",
+	},
+	{
+		"a=(10 20 30); i=0; echo $((a[i++] + a[i++])) $((a[i--] * 10 + a[i])) $i",
+		"30 320 1\n",
+	},
+	{
+		"i=0; a=(1 2 3); echo $((a[i++] = i)) ${a[@]} $i",
+		"0 0 2 3 1\n",
+	},
+	{
+		"i=2; a=(0 0 0 0); echo $((a[i++] += i)) ${a[@]} $i",
+		"3 0 0 3 0 3\n",
+	},
+	{
+		"a=(5 6); i=0; ((a[i++]++)); ((a[++i]--)); echo ${a[@]} $i",
+		"6 6 -1 2\n",
+	},
+	{
+		"declare -A m; k=x; ((m[$k]++)); ((m[$k] += 2)); echo ${m[x]}",
+		"3\n",
+	},
 	{
 		"((1))",
 		"",
@@ -1963,6 +2088,61 @@ var runTests = []runTest{
 		"set -e -o pipefail; false | :; echo next",
 		"exit status 1",
 	},
+	{
+		"set -o pipefail; exit 3 | true; echo $?",
+		"3\n",
+	},
+	{
+		"set -o pipefail; true | (exit 4) | (exit 5) | true; echo $?",
+		"5\n",
+	},
+	{
+		"set -o pipefail; ! false | true; echo $? ${PIPESTATUS[@]}",
+		"0 1 0\n",
+	},
+	{
+		"set -e; true | false; echo next",
+		"exit status 1",
+	},
+	{
+		"set -e; false | true; echo next",
+		"next\n",
+	},
+	{
+		"trap 'echo trapped' ERR; true | false; echo next",
+		"trapped\nnext\n",
+	},
+
+	// PIPESTATUS
+	{"false | true; echo ${PIPESTATUS[@]}", "1 0\n"},
+	{"true | (exit 4) | false; echo ${PIPESTATUS[@]} ${#PIPESTATUS[@]}", "0 4 1 3\n"},
+	{"! true | false; echo $? ${PIPESTATUS[@]}", "0 0 1\n"},
+	{"false; echo ${PIPESTATUS[@]}", "1\n"},
+	{"! false; echo ${PIPESTATUS[@]}", "1\n"},
+	{"{ false | true; }; echo ${PIPESTATUS[@]}", "1 0\n"},
+	{"(exit 1) | (exit 2) | { false | (exit 3); } | (exit 4) | true; echo ${PIPESTATUS[@]}", "1 2 3 4 0\n"},
+	{"set -o pipefail; (exit 1) | (exit 2) | true | true; echo $?", "2\n"},
+	{"x=1; echo a | { x=2; cat; } | { read y; echo $x $y; }; echo $x", "1 a\n1\n"},
+	{"shopt -s lastpipe; echo a | cat | cat | read y; echo $y", "a\n"},
+	{"{ echo a; echo b >&2; } |& cat | { echo c >&2; cat; } 2>/dev/null | sort", "a\nb\n"},
+	{"yes | head -n 2 | cat | head -n 1", "y\n"},
+	{"(exit 2); echo ${PIPESTATUS[@]}", "2\n"},
+	{"while false; do :; done; echo ${PIPESTATUS[@]}", "1\n"},
+	{"f() { false | true; }; f; echo ${PIPESTATUS[@]}", "0\n"},
+	{"f() { return 5; }; f | f; echo ${PIPESTATUS[@]}", "5 5\n"},
+	{"((0)); echo ${PIPESTATUS[@]}; [[ a == b ]]; echo ${PIPESTATUS[0]}", "1\n1\n"},
+	{"x=$(exit 3); echo ${PIPESTATUS[@]}", "3\n"},
+	{"false | true; (echo ${PIPESTATUS[@]})", "1 0\n"},
+	{"false | true; echo ${PIPESTATUS[@]} | cat", "1 0\n"},
+
+	// lastpipe
+	{"echo a | read v; echo \"[$v]\"", "[]\n"},
+	{"shopt -s lastpipe; echo a | read v; echo \"[$v]\"", "[a]\n"},
+	{"shopt -s lastpipe; echo a | read v | cat; echo \"[$v]\"", "[]\n"},
+	{"shopt -s lastpipe; printf 'a\\nb\\n' | while read l; do n=$l; done; echo $n", "b\n"},
+	{"true | exit 7; echo after $?", "after 7\n"},
+	{"shopt -s lastpipe; true | exit 7; echo after", "exit status 7"},
+	{"shopt -s lastpipe; set -e; true | false; echo next", "exit status 1"},
 	{
 		"set -f; >a.x; echo *.x;",
 		"*.x\n",
@@ -2073,8 +2253,10 @@ var runTests = []runTest{
 set +o errexit
 set +o noexec
 set +o noglob
+set +o notify
 set +o nounset
 set +o xtrace
+set +o ignoreeof
 set +o pipefail
  #IGNORE`,
 	},
@@ -2190,6 +2372,22 @@ done <<< 2`,
 		"touch a ab abB Ac Ad; shopt -s nocaseglob; echo *b",
 		"ab abB\n",
 	},
+	{
+		"for a in y Y yes NO; do case $a in y|yes) echo y ;; *) echo n ;; esac; done",
+		"y\nn\ny\nn\n",
+	},
+	{
+		"shopt -s nocasematch; for a in y Y YeS NO; do case $a in y|yes) echo y ;; *) echo n ;; esac; done",
+		"y\ny\ny\nn\n",
+	},
+	{
+		"shopt -s nocasematch; [[ ABC == a*c && ABC != *d && ABC =~ ^ab ]] && echo ok; shopt -u nocasematch; [[ ABC == a*c ]]",
+		"ok\nexit status 1",
+	},
+	{
+		"shopt -s nocasematch; test ABC = abc",
+		"exit status 1",
+	},
 
 	// IFS
 	{`echo -n "$IFS"`, " \t\n"},
@@ -2205,6 +2403,10 @@ done <<< 2`,
 	{`set -- x y z; IFS=-; echo "$*"`, "x-y-z\n"},
 	{`set -- x y z; IFS=; echo $*`, "x y z\n"},
 	{`set -- x y z; IFS=; echo "$*"`, "xyz\n"},
+	{`a="x::y"; IFS=:; set -- $a; echo $#`, "3\n"},
+	{`a=":x:"; IFS=:; set -- $a; echo $#`, "2\n"},
+	{`a=" : x :: y "; IFS=': '; set -- $a; printf '<%s>' "$@"`, "<><x><><y>"},
+	{`a="x:"; IFS=:; set -- $a""; printf '<%s>' "$@"`, "<x><>"},
 
 	// builtin
 	{"builtin", ""},
@@ -2244,6 +2446,12 @@ done <<< 2`,
 	// TODO: our builtin appears to not receive the piped bytes?
 	// {"trap 'echo on_err' ERR; trap | grep -q '.*echo on_err.*'", "trap -- \"echo on_err\" ERR\n"},
 	{"trap 'false' ERR EXIT; false", "exit status 1"},
+	{"trap 'echo \"$? $BASH_COMMAND\"' ERR; f() { return 3; }; f; echo after $?", "3 return 3\nafter 3\n"},
+	{"trap 'echo trap $?; false' ERR; false; echo after $?", "trap 1\nafter 1\n"},
+	{"trap 'echo $LINENO' ERR\necho a\nfalse\necho b", "a\n3\nb\n"},
+	{"trap 'echo \"exit $?\"' EXIT; exit 5", "exit 5\nexit status 5"},
+	{"trap 'echo \"$BASH_COMMAND\"' EXIT; echo foo_interp_missing", "foo_interp_missing\necho foo_interp_missing\n"},
+	{"trap 'echo $?' ERR; false", "1\nexit status 1"},
 
 	// eval
 	{"eval", ""},
@@ -2360,6 +2568,26 @@ done <<< 2`,
 		"a=(1 2 3); echo ${a[2-1]}; echo $((a[1+1]))",
 		"2\n3\n",
 	},
+	{
+		"a=(1 2); (( a[1] = 5, a[-1] *= 2, a[0]-- )); echo ${a[@]}",
+		"0 10\n",
+	},
+	{
+		"a[1000000]=3; (( a[-1]++ )); (( a[3]=4 )); echo $(( a[3] + a[-1] )); declare -p a",
+		"8\n" + `declare -a a=([3]="4" [1000000]="4")` + "\n",
+	},
+	{
+		"s=5; (( s[1]=2 )); declare -p s",
+		`declare -a s=([0]="5" [1]="2")` + "\n",
+	},
+	{
+		"declare -A m=(); (( m[k] += 2 )); (( m[k]++ )); declare -p m",
+		`declare -A m=([k]="3" )` + "\n",
+	},
+	{
+		"a=(1); (( a[-5] = 1 ))",
+		"a[-5]: bad array subscript\nexit status 1 #JUSTERR",
+	},
 	{
 		"a=(1 2) x=(); a+=b x+=c; echo ${a[@]}; echo ${x[@]}",
 		"1b 2\nc\n",
@@ -2410,9 +2638,68 @@ done <<< 2`,
 	},
 	{
 		`a=(b); echo ${a[-2]}`,
-		"negative array index\n #JUSTERR",
+		"bad array subscript\n #JUSTERR",
+	},
+	{
+		`a=(x y z); echo ${a[-1]} ${a[-3]}`,
+		"z x\n",
+	},
+	{
+		`a=(x y z); a[-1]=w; a[-3]=v; echo ${a[@]}`,
+		"v y w\n",
+	},
+	{
+		`a=(x); a[-2]=y`,
+		"a[-2]: bad array subscript\nexit status 1 #JUSTERR",
+	},
+	{
+		`a=(); a[-1]=y`,
+		"a[-1]: bad array subscript\nexit status 1 #JUSTERR",
+	},
+	{
+		`a=([-1]=y)`,
+		"a[-1]: bad array subscript\nexit status 1 #JUSTERR",
+	},
+	{
+		`a=(x y); a+=([-1]=z); echo ${a[@]}`,
+		"x z\n",
+	},
+	{
+		`a=([2]=x [7]=y); echo ${a[-1]} "${a[-2]}" ${#a[@]}; echo ${!a[@]}`,
+		"y  2\n2 7\n",
+	},
+	{
+		`a=([2]=x [7]=y); a[-1]=z; a[-3]=w; declare -p a`,
+		`declare -a a=([2]="x" [5]="w" [7]="z")` + "\n",
+	},
+	{
+		`a=([2]=x [7]=y); a+=(z); a[0]=w; declare -p a`,
+		`declare -a a=([0]="w" [2]="x" [7]="y" [8]="z")` + "\n",
+	},
+	{
+		`a=([5]=x y [1]=z); declare -p a`,
+		`declare -a a=([1]="z" [5]="x" [6]="y")` + "\n",
+	},
+	{
+		`a=([2]=x [5]=w [7]=z); echo ${a[@]:1:2}; echo ${a[@]: -3}; echo ${a[@]:6}`,
+		"x w\nw z\nz\n",
+	},
+	{
+		`a=([3]=x); echo "$a" ${a[0]} ${a[3]}`,
+		" x\n",
+	},
+	{
+		`a=(x "" z); echo ${!a[@]}`,
+		"0 1 2\n",
+	},
+	{
+		`a=(); for i in 2 10 9; do a[i]=$i; done; echo ${!a[@]}`,
+		"2 9 10\n",
+	},
+	{
+		`a[1000000000000]=x; a[5]=y; echo ${#a[@]} ${a[1000000000000]}; declare -p a`,
+		"2 x\n" + `declare -a a=([5]="y" [1000000000000]="x")` + "\n",
 	},
-	// TODO: also test with gaps in arrays.
 	{
 		`a=([0]=' x ' [1]=' y '); for v in "${a[@]}"; do echo "$v"; done`,
 		" x \n y \n",
@@ -2684,6 +2971,92 @@ done <<< 2`,
 		"foo_interp_missing: readonly variable\nexit status 1 #JUSTERR",
 	},
 
+	// removing var attributes
+	{"export a=1; declare +x a; declare -p a", "declare -- a=\"1\"\n"},
+	{
+		"export foo_interp_missing=bar; declare +x foo_interp_missing; $ENV_PROG | grep '^foo_interp_missing='",
+		"exit status 1",
+	},
+	{
+		"export foo_interp_missing=bar; export -n foo_interp_missing; foo_interp_missing=baz; $ENV_PROG | grep '^foo_interp_missing='",
+		"exit status 1",
+	},
+	{"declare -x +x a=1; declare -p a", "declare -- a=\"1\"\n"},
+	{"declare +x -x a=1; declare -p a", "declare -- a=\"1\"\n"},
+	{"declare -xr a=1; declare +x a; declare -p a", "declare -r a=\"1\"\n"},
+	{"typeset -x a=1; typeset +x a; declare -p a", "declare -- a=\"1\"\n"},
+	{"f() { local -x a=1; local +x a; declare -p a; }; f", "declare -- a=\"1\"\n"},
+	{"declare +r a=1; declare -p a", "declare -- a=\"1\"\n"},
+	{
+		"readonly a=1; declare +r a",
+		"declare: a: readonly variable\nexit status 1 #JUSTERR",
+	},
+	{
+		"readonly a=1; declare -x a=2",
+		"a: readonly variable\nexit status 1 #JUSTERR",
+	},
+	{"readonly a=1; export a; declare -p a", "declare -rx a=\"1\"\n"},
+	{"x=1; declare -n r=x; declare +n r; declare -p r", "declare -- r=\"x\"\n"},
+	{"x=1; declare -n r=x; declare +n r=y; declare -p r x", "declare -- r=\"x\"\ndeclare -- x=\"y\"\n"},
+	{
+		"declare -a a=(1); declare +a a",
+		"declare: a: cannot destroy array variables in this way\nexit status 1 #JUSTERR",
+	},
+	{
+		"declare -A a=([k]=v); declare +A a",
+		"declare: a: cannot destroy array variables in this way\nexit status 1 #JUSTERR",
+	},
+	{"export foo_interp_missing=bar; export -n | grep foo_interp_missing", "declare -x foo_interp_missing=\"bar\"\n"},
+	{
+		"f() { echo foo_interp_missing; }; export -f f; export -n -f f; $GOSH_PROG f",
+		"\"f\": executable file not found in $PATH\nexit status 127 #JUSTERR",
+	},
+
+	// listing vars
+	{"a=b; declare -p a", "declare -- a=\"b\"\n"},
+	{`a='x"$y'; declare -p a`, `declare -- a="x\"\$y"` + "\n"},
+	{`a=$'x\ny'; declare -p a`, `declare -- a=$'x\ny'` + "\n"},
+	{"declare -a a=(x 'y z'); declare -p a", "declare -a a=([0]=\"x\" [1]=\"y z\")\n"},
+	{"declare -A a=([k]=v); declare -p a", "declare -A a=([k]=\"v\" )\n"},
+	{"declare -n a=b; declare -p a", "declare -n a=\"b\"\n"},
+	{"declare -rx a=b; declare -p a", "declare -rx a=\"b\"\n"},
+	{"export a; declare -p a", "declare -x a\n"},
+	{"a=1 b=2; declare -p a b", "declare -- a=\"1\"\ndeclare -- b=\"2\"\n"},
+	{"f() { local a=1; declare -p a; }; f", "declare -- a=\"1\"\n"},
+	{"declare -p foo_interp_missing", "declare: foo_interp_missing: not found\nexit status 1 #JUSTERR"},
+	{
+		"export foo_interp_missing=bar; export -p | grep foo_interp_missing",
+		"declare -x foo_interp_missing=\"bar\"\n",
+	},
+	{
+		"export foo_interp_missing=bar; export | grep foo_interp_missing",
+		"declare -x foo_interp_missing=\"bar\"\n",
+	},
+	{
+		"readonly foo_interp_missing=bar; readonly -p | grep foo_interp_missing",
+		"declare -r foo_interp_missing=\"bar\"\n",
+	},
+	{
+		"foo_interp_missing=bar; export -p | grep foo_interp_missing",
+		"exit status 1",
+	},
+	{
+		`a='x"$y'; declare -p a >f; unset a; . ./f; echo "$a"`,
+		"x\"$y\n",
+	},
+	{
+		"declare -a a=(x y); export -p >f; declare -p a >>f; unset a; . ./f; echo ${a[1]}",
+		"y\n",
+	},
+
+	// exporting functions
+	{"f() { echo foo_interp_missing; }; export -f f; $GOSH_PROG f", "foo_interp_missing\n"},
+	{"f() { echo a; }; export -f f; f() { echo b; }; $GOSH_PROG f", "b\n"},
+	{"f() { echo a; }; export -f f; $ENV_PROG | grep -q '^BASH_FUNC_f%%='", ""},
+	{"f() { echo a; }; export -f f; unset -f f; $ENV_PROG | grep -q '^BASH_FUNC_f%%='", "exit status 1"},
+	{"f() { echo a; }; $ENV_PROG | grep -q '^BASH_FUNC_f%%='", "exit status 1"},
+	{"export -f foo_interp_missing", "declare: foo_interp_missing: not a function\nexit status 1 #JUSTERR"},
+
 	// globbing
 	{"echo .", ".\n"},
 	{"echo ..", "..\n"},
@@ -2849,6 +3222,11 @@ done <<< 2`,
 	{"{ time echo -n; } |& wc | tr -s ' '", " 4 6 42\n"},
 	{"{ time -p; } |& wc | tr -s ' '", " 3 6 29\n"},
 	{"{ time -p echo -n; } |& wc | tr -s ' '", " 3 6 29\n"},
+	{"TIMEFORMAT=foo; { time -p; } |& wc | tr -s ' '", " 3 6 29\n"},
+	{"{ time; } 2>/dev/null", ""},
+	{"TIMEFORMAT=; time true", ""},
+	{"TIMEFORMAT='%%|%0R|%0lR|x%'; { time true; } 2>&1 >/dev/null", "%|0|0m0s|x%\n"},
+	{"TIMEFORMAT='a%Zb'; time true", "TIMEFORMAT: `Z': invalid format character\n #JUSTERR"},
 
 	// exec
 	{"exec", ""},
@@ -2959,6 +3337,26 @@ done <<< 2`,
 		"IFS=: read a b c <<< '1\\:2:3'; echo \"$a\"; echo $b; echo $c",
 		"1:2\n3\n\n",
 	},
+	{
+		"IFS=': ' read a b c <<< ' x : y : z : w '; echo \"<$a><$b><$c>\"",
+		"<x><y><z : w>\n",
+	},
+	{
+		"IFS=: read a b c <<< 'x::y:z:'; echo \"<$a><$b><$c>\"",
+		"<x><><y:z:>\n",
+	},
+	{
+		"IFS=: read a <<< 'x:'; echo \"<$a>\"",
+		"<x>\n",
+	},
+	{
+		"read -r <<< '  a\\b  '; echo \"<$REPLY>\"",
+		"<  a\\b  >\n",
+	},
+	{
+		"read a <<< '  a b  '; echo \"<$a>\"",
+		"<a b>\n",
+	},
 	{
 		"read -p",
 		"read: -p: option requires an argument\nexit status 2 #JUSTERR",
@@ -2975,6 +3373,50 @@ done <<< 2`,
 		"read -r -p 'Prompt and raw flag together: ' a <<< '\\a\\b\\c'; echo $a",
 		"Prompt and raw flag together: \\a\\b\\c\n #IGNORE bash requires a terminal",
 	},
+	{
+		"printf 'a\\\\\\nb c\\n' | { read x y; echo \"[$x][$y]\"; }",
+		"[ab][c]\n",
+	},
+	{
+		"printf 'a\\\\\\nb c\\n' | { read -r x y; echo \"[$x][$y]\"; }",
+		"[a\\][]\n",
+	},
+	{
+		"printf 'a b\\0c\\0' | while IFS= read -r -d '' f; do echo \"[$f]\"; done",
+		"[a b]\n[c]\n",
+	},
+	{
+		"printf 'x:y\\\\:z:w' | { read -d : a; echo \"[$a]\"; read -d : a; echo \"[$a]\"; read -d : a; echo \"[$a] $?\"; }",
+		"[x]\n[y:z]\n[w] 1\n",
+	},
+	{
+		"printf 'a\\nb' | { read -d '' x; echo \"[$x] $?\"; }",
+		"[a\nb] 1\n",
+	},
+	{
+		"read -d",
+		"read: -d: option requires an argument\nexit status 2 #JUSTERR",
+	},
+	{
+		"a=(9 9 9); read -a a <<< ' a  b '; echo ${#a[@]} ${a[@]}",
+		"2 a b\n",
+	},
+	{
+		"IFS=, read -a arr x <<< 'a,,b'; echo ${#arr[@]} \"${arr[1]}\" ${arr[2]} \"[$x]\"",
+		"3  b []\n",
+	},
+	{
+		"read -a <<< x",
+		"read: -a: option requires an argument\nexit status 2 #JUSTERR",
+	},
+	{
+		"read -s a <<< 'foo bar'; echo \"[$a]\"",
+		"[foo bar]\n",
+	},
+	{
+		"read -sra a <<< 'x\\y z'; echo ${a[@]}",
+		"x\\y z\n",
+	},
 	{
 		`a=a; echo | (read a; echo -n "$a")`,
 		"",
@@ -3103,6 +3545,28 @@ var runTestsUnix = []runTest{
 	{"sh() { :; }; sh -c 'echo foo_interp_missing'", ""},
 	{"sh() { :; }; command sh -c 'echo foo_interp_missing'", "foo_interp_missing\n"},
 
+	// scripts without a shebang line are run by a new shell
+	{
+		"printf 'echo \"[$x] [$y] $1 $#\"\\nexit 3\\n' >s; chmod +x s; x=1; export y=2; ./s a b; echo $?",
+		"[] [2] a 2\n3\n",
+	},
+	{
+		"printf 'f; g\\n' >s; chmod +x s; f() { echo f; }; g() { echo g; }; export -f g; ./s",
+		"\"f\": executable file not found in $PATH\ng\n #IGNORE bash prints a different error",
+	},
+	{
+		"printf 'false; echo $?\\n' >s; chmod +x s; set -e; ./s",
+		"1\n",
+	},
+	{
+		"printf 'echo (\\n' >s; chmod +x s; ./s; echo $?",
+		"./s:1:1: \"foo(\" must be followed by )\n2\n #IGNORE bash prints a different error",
+	},
+	{
+		"printf 'ab\\000c\\n' >b; chmod +x b; ./b",
+		"./b: cannot execute binary file: Exec format error\nexit status 126 #JUSTERR",
+	},
+
 	// chmod is practically useless on Windows
 	{
 		"[ -x a ] && echo x; >a; chmod 0755 a; [ -x a ] && echo y",
@@ -3468,6 +3932,12 @@ var runTestsWindows = []runTest{
 
 // These tests are specific to 64-bit architectures, and that's fine. We don't
 // need to add explicit versions for 32-bit.
+// File access times are only implemented on Linux.
+var runTestsLinux = []runTest{
+	{">a; [[ -N a ]]", "exit status 1"},
+	{">a; sleep 0.01; echo x >>a; [[ -N a ]]", ""},
+}
+
 var runTests64bit = []runTest{
 	{"printf %i,%u -3 -3", "-3,18446744073709551613"},
 	{"printf %o -3", "1777777777777777777775"},
@@ -3480,6 +3950,9 @@ func init() {
 	} else { // Unix-y
 		runTests = append(runTests, runTestsUnix...)
 	}
+	if runtime.GOOS == "linux" {
+		runTests = append(runTests, runTestsLinux...)
+	}
 	if bits.UintSize == 64 {
 		runTests = append(runTests, runTests64bit...)
 	}
@@ -3962,6 +4435,26 @@ func TestRunnerOpts(t *testing.T) {
 			"(echo $foo); echo x | echo $foo",
 			"bar\nbar\n",
 		},
+		{
+			opts(withPath("BASH_FUNC_foo_interp_missing%%=() { echo imported; }")),
+			"foo_interp_missing; $ENV_PROG | grep '^BASH_FUNC'",
+			"imported\nBASH_FUNC_foo_interp_missing%%=() { echo imported; }\n",
+		},
+		{
+			opts(withPath("BASH_FUNC_foo_interp_missing%%=() { echo imported; }; echo injected")),
+			"type foo_interp_missing",
+			"type: foo_interp_missing: not found\nexit status 1",
+		},
+		{
+			opts(withPath("BASH_FUNC_foo_interp_missing%%=() { echo imported; }"), interp.ExportFuncs(false)),
+			"type foo_interp_missing; $ENV_PROG | grep '^BASH_FUNC'",
+			"type: foo_interp_missing: not found\nBASH_FUNC_foo_interp_missing%%=() { echo imported; }\n",
+		},
+		{
+			opts(withPath(), interp.ExportFuncs(false)),
+			"f() { echo a; }; export -f f; $ENV_PROG | grep '^BASH_FUNC'",
+			"exit status 1",
+		},
 	}
 	p := syntax.NewParser()
 	for _, c := range cases {
@@ -4216,6 +4709,71 @@ func TestRunnerIncremental(t *testing.T) {
 	}
 }
 
+func TestRunnerJobs(t *testing.T) {
+	t.Parallel()
+
+	pr, pw := io.Pipe()
+	r, _ := interp.New(interp.StdIO(pr, io.Discard, io.Discard))
+	ctx, cancel := context.WithTimeout(context.Background(), runnerRunTimeout)
+	defer cancel()
+	run := func(src string) {
+		t.Helper()
+		err := r.Run(ctx, parse(t, nil, src).Stmts[0])
+		if _, ok := interp.IsExitStatus(err); !ok && err != nil {
+			t.Fatal(err)
+		}
+	}
+	qt.Assert(t, qt.HasLen(r.Jobs(), 0))
+	// The first job blocks until we write to its stdin.
+	run("read x &")
+	run("(exit 3) &")
+	run("wait %2")
+	run("false &")
+
+	want := []interp.Job{
+		{ID: 1, State: interp.JobRunning, Command: "read x"},
+		{ID: 2, State: interp.JobDone, ExitStatus: 1, Command: "false"},
+	}
+	// Wait for the second job to finish.
+	for jobs := r.Jobs(); jobs[1].State != interp.JobDone; jobs = r.Jobs() {
+		time.Sleep(time.Millisecond)
+	}
+	qt.Assert(t, qt.DeepEquals(r.Jobs(), want))
+
+	io.WriteString(pw, "foo\n")
+	run("wait")
+	qt.Assert(t, qt.HasLen(r.Jobs(), 0))
+}
+
+func TestRunnerOutput(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	r, _ := interp.New(interp.StdIO(nil, &b, &b))
+	ctx, cancel := context.WithTimeout(context.Background(), runnerRunTimeout)
+	defer cancel()
+
+	file := parse(t, nil, "echo out; echo err >&2; echo pipe >&2 | cat; $GOSH_PROG 'echo exec'; exit 3")
+	stdout, stderr, err := r.Output(ctx, file)
+	qt.Assert(t, qt.Equals(string(stdout), "out\nexec\n"))
+	qt.Assert(t, qt.Equals(string(stderr), "err\npipe\n"))
+	status, ok := interp.IsExitStatus(err)
+	qt.Assert(t, qt.IsTrue(ok))
+	qt.Assert(t, qt.Equals(status, 3))
+
+	// Large outputs are captured in full.
+	file = parse(t, nil, "for ((i=0; i<20000; i++)); do echo line $i; done; $GOSH_PROG 'for ((i=0; i<20000; i++)); do echo exec $i; done'")
+	stdout, stderr, err = r.Output(ctx, file)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(strings.Count(string(stdout), "\n"), 40000))
+	qt.Assert(t, qt.HasLen(stderr, 0))
+
+	// The original writers are restored, including after a Reset.
+	r.Reset()
+	qt.Assert(t, qt.IsNil(r.Run(ctx, parse(t, nil, "echo restored"))))
+	qt.Assert(t, qt.Equals(b.String(), "restored\n"))
+}
+
 func TestRunnerResetFields(t *testing.T) {
 	t.Parallel()
 
@@ -4392,6 +4950,59 @@ func TestRunnerVars(t *testing.T) {
 	}
 }
 
+func TestRunnerVarChanges(t *testing.T) {
+	t.Parallel()
+
+	r, err := interp.New(
+		interp.Env(expand.ListEnviron("SAME=x", "MOD=old", "GONE=y", "EXP=z")),
+		interp.TrackVarChanges(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), runnerRunTimeout)
+	defer cancel()
+
+	type change struct {
+		Name     string
+		Old, New string
+		Exported bool
+	}
+	run := func(src string) []change {
+		t.Helper()
+		if err := r.Run(ctx, parse(t, nil, src)); err != nil {
+			t.Fatal(err)
+		}
+		var got []change
+		for _, c := range r.VarChanges() {
+			got = append(got, change{c.Name, c.Old.String(), c.New.String(), c.New.Exported})
+		}
+		return got
+	}
+
+	got := run(`
+SAME=x MOD=new NEW=(a b)
+unset GONE
+declare +x EXP
+f() { local LOCAL=1; GLOBAL=2; }; f
+(SUBSHELL=1)
+TMP=1; unset TMP
+`)
+	qt.Assert(t, qt.DeepEquals(got, []change{
+		{"EXP", "z", "z", false},
+		{"GLOBAL", "", "2", false},
+		{"GONE", "y", "", false},
+		{"MOD", "old", "new", true},
+		{"NEW", "", "a", false},
+	}))
+
+	// Each run only records its own changes.
+	got = run("MOD=newer; MOD+=er")
+	qt.Assert(t, qt.DeepEquals(got, []change{{"MOD", "new", "newerer", true}}))
+	got = run("true")
+	qt.Assert(t, qt.HasLen(got, 0))
+}
+
 func TestRunnerSubshell(t *testing.T) {
 	t.Parallel()
 
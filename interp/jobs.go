@@ -0,0 +1,319 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// job is an entry in the job table, which holds background statements as well
+// as foreground processes which were stopped, such as via Ctrl-Z.
+type job struct {
+	id   int    // the job number, as in "%1"
+	text string // the command as shown in job notifications
+
+	done chan struct{} // closed once the job has finished
+	err  error         // the job's result, set before done is closed
+
+	// stopped is set while the job is a stopped process.
+	stopped *stoppedProcess
+}
+
+// JobState is the state of a job in a [Runner]'s job table.
+type JobState int
+
+const (
+	JobRunning JobState = iota // running in the background
+	JobStopped                 // a process stopped via job control
+	JobDone                    // finished, but not yet reported or waited on
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobRunning:
+		return "Running"
+	case JobStopped:
+		return "Stopped"
+	default:
+		return "Done"
+	}
+}
+
+// Job describes an entry in a [Runner]'s job table, as returned by [Runner.Jobs].
+type Job struct {
+	// ID is the job number, as in "%1".
+	ID int
+
+	// PID is the process ID of a stopped process, or zero if unknown.
+	// Background jobs run as goroutines, so they don't have a single
+	// process ID.
+	PID int
+
+	State JobState
+
+	// ExitStatus is the job's exit status once it is [JobDone].
+	ExitStatus uint8
+
+	// Command is the job's command as shown by the "jobs" builtin.
+	Command string
+}
+
+// Jobs returns the jobs in the runner's job table, which holds the
+// background commands which have not been waited on yet, as well as any
+// stopped processes when the runner is [Interactive].
+//
+// Jobs may be called while the runner is running a program, such as to show
+// the state of long-running jobs.
+func (r *Runner) Jobs() []Job {
+	r.jobsMu.Lock()
+	defer r.jobsMu.Unlock()
+	jobs := make([]Job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		jobs = append(jobs, j.info())
+	}
+	return jobs
+}
+
+// info returns the exported view of a job.
+// The caller must hold jobsMu if other goroutines may modify the job.
+func (j *job) info() Job {
+	info := Job{ID: j.id, Command: j.text}
+	if sp := j.stopped; sp != nil {
+		info.State = JobStopped
+		info.PID = sp.cmd.Process.Pid
+		return info
+	}
+	select {
+	case <-j.done:
+		info.State = JobDone
+		info.ExitStatus, _ = IsExitStatus(j.err)
+	default:
+		info.State = JobRunning
+	}
+	return info
+}
+
+// addJob adds a job to the table. Like in Bash, it is numbered after the
+// highest job number in use.
+func (r *Runner) addJob(node syntax.Node) *job {
+	id := 1
+	for _, j := range r.jobs {
+		id = max(id, j.id+1)
+	}
+	j := &job{id: id, text: singleLine(node), done: make(chan struct{})}
+	r.jobsMu.Lock()
+	r.jobs = append(r.jobs, j)
+	r.jobsMu.Unlock()
+	return j
+}
+
+func (r *Runner) removeJob(j *job) {
+	r.jobsMu.Lock()
+	r.jobs = slices.DeleteFunc(r.jobs, func(j2 *job) bool { return j2 == j })
+	r.jobsMu.Unlock()
+}
+
+// setStopped sets or clears a job's stopped process.
+func (r *Runner) setStopped(j *job, sp *stoppedProcess) {
+	r.jobsMu.Lock()
+	j.stopped = sp
+	r.jobsMu.Unlock()
+}
+
+// findJob returns the job named by a job specification such as "%1", "%+",
+// "%-", "%name", or "%?name", or by the process ID of a stopped process.
+// The most recently added job is the current one.
+func (r *Runner) findJob(spec string) *job {
+	if len(r.jobs) == 0 {
+		return nil
+	}
+	name, ok := strings.CutPrefix(spec, "%")
+	if !ok {
+		pid, err := strconv.Atoi(spec)
+		if err != nil {
+			return nil
+		}
+		for _, j := range r.jobs {
+			if j.stopped != nil && j.stopped.cmd.Process.Pid == pid {
+				return j
+			}
+		}
+		return nil
+	}
+	switch name {
+	case "", "%", "+":
+		return r.jobs[len(r.jobs)-1]
+	case "-":
+		return r.jobs[max(0, len(r.jobs)-2)]
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		for _, j := range r.jobs {
+			if j.id == n {
+				return j
+			}
+		}
+		return nil
+	}
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		j := r.jobs[i]
+		if sub, ok := strings.CutPrefix(name, "?"); ok {
+			if strings.Contains(j.text, sub) {
+				return j
+			}
+		} else if strings.HasPrefix(j.text, name) {
+			return j
+		}
+	}
+	return nil
+}
+
+// jobMark returns the character marking the current job with '+' and the
+// previous job with '-', as in job notifications.
+func (r *Runner) jobMark(j *job) byte {
+	if n := len(r.jobs); n > 0 && r.jobs[n-1] == j {
+		return '+'
+	} else if n > 1 && r.jobs[n-2] == j {
+		return '-'
+	}
+	return ' '
+}
+
+// printJob prints a job notification to stderr, such as
+// "[1]+  Stopped                 sleep 10".
+func (r *Runner) printJob(j *job, state string) {
+	r.errf("[%d]%c  %-24s%s\n", j.id, r.jobMark(j), state, j.text)
+}
+
+// reportJobs prints a notification for each finished job and removes it from
+// the table, like interactive shells do before each prompt.
+func (r *Runner) reportJobs() {
+	if !r.jobControl {
+		return
+	}
+	for _, j := range slices.Clone(r.jobs) {
+		if j.stopped != nil {
+			continue
+		}
+		select {
+		case <-j.done:
+		default:
+			continue
+		}
+		state := "Done"
+		if status, ok := IsExitStatus(j.err); ok {
+			state = fmt.Sprintf("Exit %d", status)
+		}
+		r.printJob(j, state)
+		r.removeJob(j)
+	}
+}
+
+// stopJob adds a stopped foreground process to the job table.
+func (r *Runner) stopJob(sp *stoppedProcess, j *job) {
+	if j == nil {
+		j = r.addJob(r.bashCommand)
+	}
+	r.setStopped(j, sp)
+	r.errf("\n")
+	r.printJob(j, "Stopped")
+	r.exit = 128 + 20 // SIGTSTP
+}
+
+// foreground waits for a job to finish, as done by the "fg" builtin.
+func (r *Runner) foreground(ctx context.Context, j *job) {
+	r.outf("%s\n", j.text)
+	sp := j.stopped
+	if sp == nil {
+		<-j.done
+		r.removeJob(j)
+		r.jobResult(j.err)
+		return
+	}
+	if err := continueProcess(sp.cmd.Process); err != nil {
+		r.errf("fg: %v\n", err)
+		r.exit = 1
+		return
+	}
+	r.setStopped(j, nil)
+	err := sp.wait(ctx, true)
+	if sp, ok := err.(*stoppedProcess); ok {
+		r.stopJob(sp, j)
+		return
+	}
+	r.removeJob(j)
+	close(j.done)
+	r.jobResult(err)
+}
+
+// background resumes a stopped job in the background, as done by the "bg"
+// builtin.
+func (r *Runner) background(ctx context.Context, j *job) {
+	sp := j.stopped
+	if sp == nil {
+		r.errf("bg: job %d already in background\n", j.id)
+		return
+	}
+	r.outf("[%d]%c %s &\n", j.id, r.jobMark(j), j.text)
+	if err := continueProcess(sp.cmd.Process); err != nil {
+		r.errf("bg: %v\n", err)
+		r.exit = 1
+		return
+	}
+	r.setStopped(j, nil)
+	go func() {
+		// Background jobs can't be stopped via Ctrl-Z.
+		j.err = sp.wait(ctx, false)
+		close(j.done)
+	}()
+}
+
+// printJobs prints the job table for the "jobs" builtin, such as
+// "[1]+  Running                 sleep 10 &". Like in Bash, finished jobs are
+// removed from the table once they are reported.
+func (r *Runner) printJobs(jobs []*job, long, pidsOnly bool) {
+	var done []*job
+	for _, j := range jobs {
+		info := j.info()
+		if pidsOnly {
+			if info.PID > 0 {
+				r.outf("%d\n", info.PID)
+			}
+			continue
+		}
+		state := info.State.String()
+		text := j.text
+		switch info.State {
+		case JobRunning:
+			text += " &"
+		case JobDone:
+			if info.ExitStatus != 0 {
+				state = fmt.Sprintf("Exit %d", info.ExitStatus)
+			}
+			done = append(done, j)
+		}
+		if long && info.PID > 0 {
+			r.outf("[%d]%c %d %-24s%s\n", j.id, r.jobMark(j), info.PID, state, text)
+		} else {
+			r.outf("[%d]%c  %-24s%s\n", j.id, r.jobMark(j), state, text)
+		}
+	}
+	for _, j := range done {
+		r.removeJob(j)
+	}
+}
+
+// jobResult sets the exit status from a job's result.
+func (r *Runner) jobResult(err error) {
+	if status, ok := IsExitStatus(err); ok {
+		r.exit = int(status)
+	} else if err != nil {
+		r.setErr(err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build linux
+
+package interp_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+func TestRunnerReadSilent(t *testing.T) {
+	t.Parallel()
+	primary, secondary, err := pty.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	defer secondary.Close()
+
+	file := parse(t, nil, `read -s pass; echo "got $pass"`)
+	r, _ := interp.New(interp.StdIO(secondary, secondary, secondary))
+	go func() {
+		if err := r.Run(context.Background(), file); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// Only type the input once echoing has been disabled.
+	for {
+		termios, err := unix.IoctlGetTermios(int(secondary.Fd()), unix.TCGETS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if termios.Lflag&unix.ECHO == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := io.WriteString(primary, "secret\n"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := bufio.NewReader(primary).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "got secret\r\n"; got != want {
+		t.Fatalf("\nwant: %q\ngot:  %q", want, got)
+	}
+}
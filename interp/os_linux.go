@@ -0,0 +1,37 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// cldStopped is the CLD_STOPPED value of siginfo_t's si_code.
+const cldStopped = 5
+
+// waitStopped blocks until a process either exits or is stopped, and reports
+// whether it was stopped. The process is not reaped, so it can still be
+// waited on via [os.Process.Wait].
+func waitStopped(p *os.Process) bool {
+	var info unix.Siginfo
+	for {
+		err := unix.Waitid(unix.P_PID, p.Pid, &info, unix.WEXITED|unix.WSTOPPED|unix.WNOWAIT, nil)
+		if err != unix.EINTR {
+			return err == nil && info.Code == cldStopped
+		}
+	}
+}
+
+// accessTime returns the last access time of a file, if known.
+func accessTime(info os.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Atim.Unix()), true
+}
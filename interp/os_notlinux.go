@@ -0,0 +1,19 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build !linux
+
+package interp
+
+import (
+	"os"
+	"time"
+)
+
+// waitStopped always reports false, as detecting stopped processes is only
+// implemented on Linux for now.
+func waitStopped(*os.Process) bool { return false }
+
+// accessTime always reports false, as access times are only implemented
+// on Linux for now.
+func accessTime(os.FileInfo) (time.Time, bool) { return time.Time{}, false }
@@ -8,6 +8,9 @@ package interp
 import (
 	"context"
 	"fmt"
+	"io/fs"
+	"os"
+	"time"
 
 	"mvdan.cc/sh/v3/syntax"
 )
@@ -17,7 +20,7 @@ func mkfifo(path string, mode uint32) error {
 }
 
 // hasPermissionToDir is a no-op on Windows.
-func hasPermissionToDir(string) bool {
+func hasPermissionToDir(fs.FileInfo) bool {
 	return true
 }
 
@@ -34,3 +37,19 @@ type waitStatus struct{}
 
 func (waitStatus) Signaled() bool { return false }
 func (waitStatus) Signal() int    { return 0 }
+
+// continueProcess is unsupported, as processes cannot be stopped.
+func continueProcess(*os.Process) error {
+	return fmt.Errorf("unsupported")
+}
+
+// suspendShell is unsupported.
+func suspendShell() error {
+	return fmt.Errorf("unsupported")
+}
+
+// cpuTimes always returns zero, as CPU times are not measured.
+func cpuTimes() (user, sys time.Duration) { return 0, 0 }
+
+// isExecFormatError always returns false, as scripts need a known extension.
+func isExecFormatError(error) bool { return false }
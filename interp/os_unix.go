@@ -7,9 +7,13 @@ package interp
 
 import (
 	"context"
+	"errors"
+	"io/fs"
+	"os"
 	"os/user"
 	"strconv"
 	"syscall"
+	"time"
 
 	"golang.org/x/sys/unix"
 	"mvdan.cc/sh/v3/syntax"
@@ -20,9 +24,39 @@ func mkfifo(path string, mode uint32) error {
 }
 
 // hasPermissionToDir returns true if the OS current user has execute
-// permission to the given directory
-func hasPermissionToDir(path string) bool {
-	return unix.Access(path, unix.X_OK) == nil
+// permission to the given directory. Since the info may come from a
+// [StatHandlerFunc], only its permission bits and owner are used.
+func hasPermissionToDir(info fs.FileInfo) bool {
+	perm := info.Mode().Perm()
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		// Not a file on disk; we can't tell who owns it.
+		return perm&0o111 != 0
+	}
+	uid := os.Geteuid()
+	switch {
+	case uid == 0:
+		return true
+	case uint32(uid) == st.Uid:
+		return perm&0o100 != 0
+	case inGroup(st.Gid):
+		return perm&0o010 != 0
+	}
+	return perm&0o001 != 0
+}
+
+// inGroup returns true if the OS current user is a member of the given group.
+func inGroup(gid uint32) bool {
+	if uint32(os.Getegid()) == gid {
+		return true
+	}
+	groups, _ := os.Getgroups()
+	for _, g := range groups {
+		if uint32(g) == gid {
+			return true
+		}
+	}
+	return false
 }
 
 // unTestOwnOrGrp implements the -O and -G unary tests. If the file does not
@@ -45,3 +79,31 @@ func (r *Runner) unTestOwnOrGrp(ctx context.Context, op syntax.UnTestOperator, x
 }
 
 type waitStatus = syscall.WaitStatus
+
+// continueProcess resumes a stopped process.
+func continueProcess(p *os.Process) error {
+	return p.Signal(unix.SIGCONT)
+}
+
+// suspendShell stops the current process until it receives SIGCONT.
+func suspendShell() error {
+	return unix.Kill(os.Getpid(), unix.SIGSTOP)
+}
+
+// cpuTimes returns the user and system CPU time used so far by the current
+// process and its children which have been waited for.
+func cpuTimes() (user, sys time.Duration) {
+	var self, children unix.Rusage
+	_ = unix.Getrusage(unix.RUSAGE_SELF, &self)
+	_ = unix.Getrusage(unix.RUSAGE_CHILDREN, &children)
+	user = time.Duration(self.Utime.Nano() + children.Utime.Nano())
+	sys = time.Duration(self.Stime.Nano() + children.Stime.Nano())
+	return user, sys
+}
+
+// isExecFormatError reports whether starting a program failed because the file
+// is not in a format that the system can execute, such as a script without
+// a shebang line.
+func isExecFormatError(err error) bool {
+	return errors.Is(err, unix.ENOEXEC)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// nativePath returns the native form of a path given to the shell.
+// With [MSYSPaths] on Windows, "/c/Users" is translated to `C:\Users`.
+func (r *Runner) nativePath(path string) string {
+	if r.msysPaths && runtime.GOOS == "windows" {
+		return msysToNative(path)
+	}
+	return path
+}
+
+// shellPath returns the form of a native path which the shell should show,
+// such as in "pwd" and $PWD. It is the inverse of [Runner.nativePath].
+func (r *Runner) shellPath(path string) string {
+	if r.msysPaths && runtime.GOOS == "windows" {
+		return nativeToMSYS(path)
+	}
+	return path
+}
+
+func isDriveLetter(b byte) bool {
+	return ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// msysToNative translates an MSYS-style absolute path such as "/c/Users" into
+// a Windows path such as `C:\Users`. Any other path is returned as-is.
+func msysToNative(path string) string {
+	if len(path) < 2 || path[0] != '/' || !isDriveLetter(path[1]) {
+		return path
+	}
+	rest := path[2:]
+	switch {
+	case rest == "":
+		rest = "/"
+	case rest[0] != '/':
+		return path // e.g. "/cd/foo"
+	}
+	return strings.ToUpper(path[1:2]) + ":" + strings.ReplaceAll(rest, "/", `\`)
+}
+
+// nativeToMSYS translates an absolute Windows path such as `C:\Users` into an
+// MSYS-style path such as "/c/Users". Any other path is returned as-is.
+func nativeToMSYS(path string) string {
+	if len(path) < 3 || !isDriveLetter(path[0]) || path[1] != ':' {
+		return path
+	}
+	if path[2] != '\\' && path[2] != '/' {
+		return path // relative to the drive's directory, like "C:foo"
+	}
+	rest := strings.TrimRight(strings.ReplaceAll(path[2:], `\`, "/"), "/")
+	return "/" + strings.ToLower(path[:1]) + rest
+}
+
+// splitPathList is like [filepath.SplitList], but on Windows it also accepts
+// MSYS-style lists such as "/c/bin:/usr/bin". A native list never starts with
+// a slash while lacking semicolons, so the two can't be confused.
+func splitPathList(list string) []string {
+	if runtime.GOOS != "windows" || !strings.HasPrefix(list, "/") || strings.Contains(list, ";") {
+		return filepath.SplitList(list)
+	}
+	elems := strings.Split(list, ":")
+	for i, elem := range elems {
+		elems[i] = msysToNative(elem)
+	}
+	return elems
+}
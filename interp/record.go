@@ -0,0 +1,197 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// ExecRecord is the result of running a program,
+// as recorded by [ExecRecorder] and replayed by [ExecReplayer].
+type ExecRecord struct {
+	Args   []string `json:"args"`
+	Stdout string   `json:"stdout,omitempty"`
+	Stderr string   `json:"stderr,omitempty"`
+	Status uint8    `json:"status,omitempty"`
+}
+
+// ExecRecorder records the programs run by a [Runner] along with their output
+// and exit status, so that they can later be replayed by an [ExecReplayer].
+// It is used via [ExecHandlers] with its Middleware method:
+//
+//	var rec interp.ExecRecorder
+//	runner, _ := interp.New(interp.ExecHandlers(rec.Middleware))
+//	// run some scripts...
+//	err := rec.WriteFile("testdata/exec.json")
+//
+// Note that standard input, the environment, and the current directory
+// are not recorded.
+//
+// The zero value is ready to use. An ExecRecorder may be used by multiple
+// runners at once.
+type ExecRecorder struct {
+	mu      sync.Mutex
+	records []ExecRecord
+}
+
+// Middleware calls the next exec handler and records the result.
+// The output is still written to the runner's standard output and error.
+// Errors other than exit statuses are not recorded.
+func (rec *ExecRecorder) Middleware(next ExecHandlerFunc) ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		hc := HandlerCtx(ctx)
+		var stdout, stderr bytes.Buffer
+		hc.Stdout = io.MultiWriter(hc.Stdout, &stdout)
+		hc.Stderr = io.MultiWriter(hc.Stderr, &stderr)
+		err := next(context.WithValue(ctx, handlerCtxKey{}, hc), args)
+		status, ok := IsExitStatus(err)
+		if err != nil && !ok {
+			return err
+		}
+		rec.mu.Lock()
+		rec.records = append(rec.records, ExecRecord{
+			Args:   slices.Clone(args),
+			Stdout: stdout.String(),
+			Stderr: stderr.String(),
+			Status: status,
+		})
+		rec.mu.Unlock()
+		return err
+	}
+}
+
+// Records returns a copy of the results recorded so far, in order.
+func (rec *ExecRecorder) Records() []ExecRecord {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return slices.Clone(rec.records)
+}
+
+// WriteFile writes the recorded results to a JSON file,
+// which can be read with [ReadExecRecords].
+func (rec *ExecRecorder) WriteFile(path string) error {
+	data, err := json.MarshalIndent(rec.Records(), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o666)
+}
+
+// ReadExecRecords reads the results from a file written by [ExecRecorder.WriteFile].
+func ReadExecRecords(path string) ([]ExecRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []ExecRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// ExecReplayer replays the results recorded by an [ExecRecorder] without
+// running any programs. It is used via [ExecHandlers] with its Middleware method.
+//
+// In strict mode, the programs must be run in the same order and with the same
+// arguments as when recording; any other call is an error which halts the runner.
+// Note that the order of concurrent programs, such as those in a pipeline,
+// may change between runs.
+//
+// Otherwise, in lenient mode, each call uses the next unused result with the same
+// arguments, or the last one if all were already used. Calls with no such result
+// fail with exit status 127, like a missing program.
+type ExecReplayer struct {
+	strict bool
+
+	mu      sync.Mutex
+	records []ExecRecord
+	used    []bool
+	next    int // for strict mode
+}
+
+// NewExecReplayer creates an [ExecReplayer] for the given results.
+func NewExecReplayer(records []ExecRecord, strict bool) *ExecReplayer {
+	return &ExecReplayer{
+		strict:  strict,
+		records: records,
+		used:    make([]bool, len(records)),
+	}
+}
+
+// Middleware replays the recorded results. It never calls the next exec handler.
+func (rep *ExecReplayer) Middleware(next ExecHandlerFunc) ExecHandlerFunc {
+	return func(ctx context.Context, args []string) error {
+		record, err := rep.find(args)
+		if err != nil {
+			return err
+		}
+		hc := HandlerCtx(ctx)
+		if record == nil {
+			fmt.Fprintf(hc.Stderr, "%s: no recorded result\n", args[0])
+			return NewExitStatus(127)
+		}
+		if _, err := io.WriteString(hc.Stdout, record.Stdout); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(hc.Stderr, record.Stderr); err != nil {
+			return err
+		}
+		if record.Status != 0 {
+			return NewExitStatus(record.Status)
+		}
+		return nil
+	}
+}
+
+func (rep *ExecReplayer) find(args []string) (*ExecRecord, error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.strict {
+		if rep.next >= len(rep.records) {
+			return nil, fmt.Errorf("unexpected call after all recorded results: %q", args)
+		}
+		record := &rep.records[rep.next]
+		if !slices.Equal(record.Args, args) {
+			return nil, fmt.Errorf("unexpected call: %q, want %q", args, record.Args)
+		}
+		rep.used[rep.next] = true
+		rep.next++
+		return record, nil
+	}
+	var last *ExecRecord
+	for i := range rep.records {
+		record := &rep.records[i]
+		if !slices.Equal(record.Args, args) {
+			continue
+		}
+		if !rep.used[i] {
+			rep.used[i] = true
+			return record, nil
+		}
+		last = record
+	}
+	return last, nil
+}
+
+// Unused returns the recorded results which have not been replayed yet,
+// which can be useful to check that a script ran all the expected programs.
+func (rep *ExecReplayer) Unused() []ExecRecord {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	var unused []ExecRecord
+	for i, record := range rep.records {
+		if !rep.used[i] {
+			unused = append(unused, record)
+		}
+	}
+	return unused
+}
@@ -10,11 +10,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"math"
 	"math/rand"
 	"os"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,6 +41,9 @@ func (r *Runner) fillExpandConfig(ctx context.Context) {
 	r.ecfg = &expand.Config{
 		Env: expandEnv{r},
 		CmdSubst: func(w io.Writer, cs *syntax.CmdSubst) error {
+			if r.policy.NoCmdSubst {
+				return &PolicyError{Pos: cs.Pos(), Action: "command substitution"}
+			}
 			switch len(cs.Stmts) {
 			case 0: // nothing to do
 				return nil
@@ -64,7 +67,14 @@ func (r *Runner) fillExpandConfig(ctx context.Context) {
 			r.lastExpandExit = r2.exit
 			return r2.err
 		},
+		CmdSubstNulls: func(cs *syntax.CmdSubst, out string) (string, error) {
+			r.errf("warning: command substitution: ignored null byte in input\n")
+			return strings.ReplaceAll(out, "\x00", ""), nil
+		},
 		ProcSubst: func(ps *syntax.ProcSubst) (string, error) {
+			if r.policy.NoCmdSubst {
+				return "", &PolicyError{Pos: ps.Pos(), Action: "process substitution"}
+			}
 			if runtime.GOOS == "windows" {
 				return "", fmt.Errorf("TODO: support process substitution on Windows")
 			}
@@ -134,6 +144,9 @@ func (r *Runner) fillExpandConfig(ctx context.Context) {
 			}()
 			return path, nil
 		},
+		ReadDir2: func(s string) ([]fs.DirEntry, error) {
+			return r.readDirHandler(r.handlerCtx(context.Background()), s)
+		},
 	}
 	r.updateExpandOpts()
 }
@@ -155,13 +168,7 @@ func catShortcutArg(stmt *syntax.Stmt) *syntax.Word {
 }
 
 func (r *Runner) updateExpandOpts() {
-	if r.opts[optNoGlob] {
-		r.ecfg.ReadDir2 = nil
-	} else {
-		r.ecfg.ReadDir2 = func(s string) ([]fs.DirEntry, error) {
-			return r.readDirHandler(r.handlerCtx(context.Background()), s)
-		}
-	}
+	r.ecfg.NoGlob = r.opts[optNoGlob]
 	r.ecfg.GlobStar = r.opts[optGlobStar]
 	r.ecfg.NoCaseGlob = r.opts[optNoCaseGlob]
 	r.ecfg.NullGlob = r.opts[optNullGlob]
@@ -169,6 +176,11 @@ func (r *Runner) updateExpandOpts() {
 }
 
 func (r *Runner) expandErr(err error) {
+	var perr *PolicyError
+	if errors.As(err, &perr) {
+		r.setErr(perr) // stop the runner without printing anything
+		return
+	}
 	if err != nil {
 		errMsg := err.Error()
 		fmt.Fprintln(r.stderr, errMsg)
@@ -239,10 +251,12 @@ func (e expandEnv) Each(fn func(name string, vr expand.Variable) bool) {
 
 func (r *Runner) handlerCtx(ctx context.Context) context.Context {
 	hc := HandlerContext{
-		Env:    &overlayEnviron{parent: r.writeEnv},
+		Env:    &overlayEnviron{parent: r.writeEnv, values: r.funcsEnv()},
 		Dir:    r.Dir,
 		Stdout: r.stdout,
 		Stderr: r.stderr,
+
+		jobControl: r.jobControl,
 	}
 	if r.stdin != nil { // do not leave hc.Stdin as a typed nil
 		hc.Stdin = r.stdin
@@ -286,14 +300,19 @@ func (r *Runner) stmt(ctx context.Context, st *syntax.Stmt) {
 	if r.stop(ctx) {
 		return
 	}
+	if r.opts[optNotify] {
+		r.reportJobs()
+	}
 	r.exit = 0
 	if st.Background {
 		r2 := r.Subshell()
 		st2 := *st
 		st2.Background = false
-		r.bgShells.Go(func() error {
-			return r2.Run(ctx, &st2)
-		})
+		j := r.addJob(&st2)
+		go func() {
+			j.err = r2.Run(ctx, &st2)
+			close(j.done)
+		}()
 	} else {
 		r.stmtSync(ctx, st)
 	}
@@ -302,6 +321,9 @@ func (r *Runner) stmt(ctx context.Context, st *syntax.Stmt) {
 
 func (r *Runner) stmtSync(ctx context.Context, st *syntax.Stmt) {
 	defer r.wgProcSubsts.Wait()
+	if !r.handlingTrap {
+		r.bashCommand = st
+	}
 	oldIn, oldOut, oldErr := r.stdin, r.stdout, r.stderr
 	for _, rd := range st.Redirs {
 		cls, err := r.redir(ctx, rd)
@@ -316,9 +338,16 @@ func (r *Runner) stmtSync(ctx context.Context, st *syntax.Stmt) {
 	if r.exit == 0 && st.Cmd != nil {
 		r.cmd(ctx, st.Cmd)
 	}
+	switch st.Cmd.(type) {
+	case *syntax.CallExpr, *syntax.Subshell, *syntax.ArithmCmd,
+		*syntax.TestClause, *syntax.DeclClause, *syntax.LetClause:
+		// Compound commands like blocks and loops keep the $PIPESTATUS
+		// of the last pipeline they ran, and pipelines set their own.
+		r.pipeStatus = append(r.pipeStatus[:0], r.exit)
+	}
 	if st.Negated {
 		r.exit = oneIf(r.exit == 0)
-	} else if _, ok := st.Cmd.(*syntax.CallExpr); !ok {
+	} else if _, ok := st.Cmd.(*syntax.CallExpr); !ok && !isPipeline(st) {
 	} else if r.exit != 0 && !r.noErrExit && r.opts[optErrExit] {
 		// If the "errexit" option is set and a simple command failed,
 		// exit the shell. Exceptions:
@@ -326,15 +355,124 @@ func (r *Runner) stmtSync(ctx context.Context, st *syntax.Stmt) {
 		//   conditions (if <cond>, while <cond>, etc)
 		//   part of && or || lists
 		//   preceded by !
+		r.trapCallback(ctx, r.callbackErr, "error", st.Pos().Line())
 		r.exitShell(ctx, r.exit)
 	} else if r.exit != 0 && !r.noErrExit {
-		r.trapCallback(ctx, r.callbackErr, "error")
+		r.trapCallback(ctx, r.callbackErr, "error", st.Pos().Line())
 	}
 	if !r.keepRedirs {
 		r.stdin, r.stdout, r.stderr = oldIn, oldOut, oldErr
 	}
 }
 
+// pipeline runs a pipeline of multiple commands, such as "a | b |& c".
+// Like in Bash, each command runs in a subshell, except for the last one
+// when "lastpipe" is set and job control is not active.
+//
+// The parser nests pipelines to the left, like "(a | b) |& c".
+// They are flattened first, so that each command runs in a direct subshell
+// of r, rather than in a chain of nested subshells as long as the pipeline.
+func (r *Runner) pipeline(ctx context.Context, cm *syntax.BinaryCmd) {
+	// ops[i] is the operator to the right of stmts[i].
+	stmts := []*syntax.Stmt{cm.Y}
+	ops := []syntax.BinCmdOperator{cm.Op}
+	for {
+		st := cm.X
+		if !isPipeline(st) || st.Negated || st.Background || st.Coprocess || len(st.Redirs) > 0 {
+			stmts = append(stmts, st)
+			break
+		}
+		cm = st.Cmd.(*syntax.BinaryCmd)
+		stmts = append(stmts, cm.Y)
+		ops = append(ops, cm.Op)
+	}
+	slices.Reverse(stmts)
+	slices.Reverse(ops)
+
+	runners := make([]*Runner, len(stmts))
+	last := len(stmts) - 1
+	for i := range stmts {
+		if i == last && r.opts[optLastPipe] && !r.jobControl {
+			runners[i] = r
+		} else {
+			runners[i] = r.Subshell()
+		}
+	}
+	// Command i writes to pws[i] and reads from prs[i-1].
+	prs := make([]io.ReadCloser, len(ops))
+	pws := make([]io.WriteCloser, len(ops))
+	for i, op := range ops {
+		pr, pw, err := pipe()
+		if err != nil {
+			for j := range i {
+				prs[j].Close()
+				pws[j].Close()
+			}
+			r.setErr(err)
+			return
+		}
+		prs[i], pws[i] = pr, pw
+		runners[i].stdout = pw
+		if op == syntax.PipeAll {
+			runners[i].stderr = pw
+		}
+		runners[i+1].stdin = pr
+	}
+
+	var wg sync.WaitGroup
+	for i, r2 := range runners[:last] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r2.stmt(ctx, stmts[i])
+			// Let the next command see the end of its input,
+			// and the previous command see that nobody is reading.
+			pws[i].Close()
+			if i > 0 {
+				prs[i-1].Close()
+			}
+		}()
+	}
+	if runners[last] == r {
+		// The whole pipeline's status decides whether to
+		// trigger "errexit" or the ERR trap, not the last command.
+		oldNoErrExit := r.noErrExit
+		r.noErrExit = true
+		r.stmt(ctx, stmts[last])
+		r.noErrExit = oldNoErrExit
+	} else {
+		runners[last].stmt(ctx, stmts[last])
+	}
+	prs[last-1].Close()
+	wg.Wait()
+
+	status := make([]int, len(runners))
+	for i, r2 := range runners {
+		status[i] = r2.exit
+	}
+	r.exit = status[last]
+	if r.opts[optPipeFail] {
+		// The rightmost command to fail sets the exit status.
+		for _, code := range status {
+			if code != 0 {
+				r.exit = code
+			}
+		}
+	}
+	r.pipeStatus = status
+	for _, r2 := range runners {
+		if r2 != r {
+			r.setErr(r2.err)
+		}
+	}
+}
+
+// isPipeline reports whether a statement is a pipeline of multiple commands.
+func isPipeline(st *syntax.Stmt) bool {
+	bc, ok := st.Cmd.(*syntax.BinaryCmd)
+	return ok && (bc.Op == syntax.Pipe || bc.Op == syntax.PipeAll)
+}
+
 func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 	if r.stop(ctx) {
 		return
@@ -371,7 +509,7 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 		fields := r.fields(args...)
 		if len(fields) == 0 {
 			for _, as := range cm.Assigns {
-				vr := r.assignVal(as, "")
+				vr := r.assignVal(as, 0)
 				r.setVar(as.Name.Value, as.Index, vr)
 
 				if !tracingEnabled {
@@ -411,7 +549,7 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 			name := as.Name.Value
 			origVr := r.lookupVar(name)
 
-			vr := r.assignVal(as, "")
+			vr := r.assignVal(as, 0)
 			// Inline command vars are always exported.
 			vr.Exported = true
 
@@ -438,34 +576,7 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 				r.stmt(ctx, cm.Y)
 			}
 		case syntax.Pipe, syntax.PipeAll:
-			pr, pw, err := os.Pipe()
-			if err != nil {
-				r.setErr(err)
-				return
-			}
-			r2 := r.Subshell()
-			r2.stdout = pw
-			if cm.Op == syntax.PipeAll {
-				r2.stderr = pw
-			} else {
-				r2.stderr = r.stderr
-			}
-			r.stdin = pr
-			var wg sync.WaitGroup
-			wg.Add(1)
-			go func() {
-				r2.stmt(ctx, cm.X)
-				pw.Close()
-				wg.Done()
-			}()
-			r.stmt(ctx, cm.Y)
-			pr.Close()
-			wg.Wait()
-			if r.opts[optPipeFail] && r2.exit != 0 && r.exit == 0 {
-				r.exit = r2.exit
-				r.shellExited = r2.shellExited
-			}
-			r.setErr(r2.err)
+			r.pipeline(ctx, cm)
 		}
 	case *syntax.IfClause:
 		oldNoErrExit := r.noErrExit
@@ -518,7 +629,7 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 					}
 					r.errf("%s", ps3)
 
-					line, err := r.readLine(ctx, true)
+					line, err := r.readLine(ctx, true, '\n')
 					if err != nil {
 						r.exit = 1
 						return nil
@@ -613,7 +724,7 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 		for _, ci := range cm.Items {
 			for _, word := range ci.Patterns {
 				pattern := r.pattern(word)
-				if match(pattern, str) {
+				if r.match(pattern, str) {
 					r.stmts(ctx, ci.Stmts)
 					return
 				}
@@ -625,11 +736,13 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 			r.exit = 1
 		}
 	case *syntax.DeclClause:
-		local, global := false, false
-		var modes []string
-		valType := ""
+		local, global, listing, funcs := false, false, false, false
+		// Attributes are added with options like "-x" and removed with
+		// options like "+x". Removing an attribute takes precedence.
+		var add, remove varAttr
+		printed, named := false, false
 		switch cm.Variant.Value {
-		case "declare":
+		case "declare", "typeset":
 			// When used in a function, "declare" acts as "local"
 			// unless the "-g" option is used.
 			local = r.inFunc
@@ -641,27 +754,63 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 			}
 			local = true
 		case "export":
-			modes = append(modes, "-x")
+			add |= attrExport
 		case "readonly":
-			modes = append(modes, "-r")
+			add |= attrReadOnly
 		case "nameref":
-			valType = "-n"
+			add |= attrNameRef
 		}
 		for _, as := range cm.Args {
 			for _, as := range r.flattenAssign(as) {
 				name := as.Name.Value
-				if strings.HasPrefix(name, "-") {
-					switch name {
-					case "-x", "-r":
-						modes = append(modes, name)
-					case "-a", "-A", "-n":
-						valType = name
-					case "-g":
-						global = true
-					default:
-						r.errf("declare: invalid option %q\n", name)
-						r.exit = 2
-						return
+				plus := strings.HasPrefix(name, "+") && cm.Variant.Value != "export" && cm.Variant.Value != "readonly"
+				if strings.HasPrefix(name, "-") || plus {
+					if name == "--" {
+						continue
+					}
+					for _, opt := range name[1:] {
+						switch opt {
+						case 'g':
+							global = true
+						case 'p':
+							listing = true
+						case 'f':
+							funcs = true
+						default:
+							attr := parseAttr(opt)
+							if cm.Variant.Value == "export" && opt == 'n' {
+								// "export -n" is like "declare +x".
+								remove |= attrExport
+								continue
+							}
+							if attr == 0 {
+								r.errf("declare: invalid option %q\n", name)
+								r.exit = 2
+								return
+							}
+							if plus {
+								remove |= attr
+							} else {
+								add |= attr
+							}
+						}
+					}
+					continue
+				}
+				named = true
+				if funcs && !listing {
+					if r.Funcs[name] == nil {
+						r.errf("declare: %s: not a function\n", name)
+						r.exit = 1
+						continue
+					}
+					if remove&attrExport != 0 {
+						delete(r.exportedFuncs, name)
+					} else if add&attrExport != 0 {
+						if r.exportedFuncs == nil {
+							r.exportedFuncs = make(map[string]bool)
+						}
+						r.exportedFuncs[name] = true
 					}
 					continue
 				}
@@ -670,22 +819,45 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 					r.exit = 1
 					return
 				}
+				if listing {
+					printed = true
+					vr := r.writeEnv.Get(name)
+					if !declared(vr) {
+						r.errf("declare: %s: not found\n", name)
+						r.exit = 1
+						continue
+					}
+					r.printDecl(name, vr)
+					continue
+				}
+				cur := r.lookupVar(name)
+				if remove&attrReadOnly != 0 && cur.ReadOnly {
+					r.errf("declare: %s: readonly variable\n", name)
+					r.exit = 1
+					continue
+				}
+				if remove&(attrIndexed|attrAssoc) != 0 {
+					if _, target := cur.Resolve(r.writeEnv); target.Kind == expand.Indexed || target.Kind == expand.Associative {
+						r.errf("declare: %s: cannot destroy array variables in this way\n", name)
+						r.exit = 1
+						continue
+					}
+				}
+				attrs := add &^ remove
 				var vr expand.Variable
 				if !as.Naked {
-					vr = r.assignVal(as, valType)
+					vr = r.assignVal(as, attrs)
 				}
 				if global {
 					vr.Local = false
 				} else if local {
 					vr.Local = true
 				}
-				for _, mode := range modes {
-					switch mode {
-					case "-x":
-						vr.Exported = true
-					case "-r":
-						vr.ReadOnly = true
-					}
+				if attrs&attrExport != 0 {
+					vr.Exported = true
+				}
+				if attrs&attrReadOnly != 0 {
+					vr.ReadOnly = true
 				}
 				if as.Naked {
 					if vr.Exported || vr.Local || vr.ReadOnly {
@@ -694,37 +866,61 @@ func (r *Runner) cmd(ctx context.Context, cm syntax.Command) {
 				} else {
 					r.setVar(name, as.Index, vr)
 				}
+				if remove != 0 {
+					r.writeEnv.(*overlayEnviron).clearAttrs(name, remove)
+				}
 			}
 		}
+		switch cm.Variant.Value {
+		case "export":
+			// "export -n" with no names still lists exported variables.
+			remove &^= attrExport
+			listing = listing || !named
+		case "readonly":
+			// With no names, these list variables like "declare -p".
+			listing = listing || !named
+		}
+		if listing && !printed {
+			r.printDecls(add &^ remove)
+		}
 	case *syntax.TimeClause:
 		start := time.Now()
+		startUser, startSys := cpuTimes()
 		if cm.Stmt != nil {
 			r.stmt(ctx, cm.Stmt)
 		}
-		format := "%s\t%s\n"
+		real := time.Since(start)
+		user, sys := cpuTimes()
+		format := "\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS"
 		if cm.PosixFormat {
-			format = "%s %s\n"
-		} else {
-			r.outf("\n")
+			format = "real %2R\nuser %2U\nsys %2S"
+		} else if vr := r.lookupVar("TIMEFORMAT"); vr.IsSet() {
+			format = vr.String()
 		}
-		real := time.Since(start)
-		r.outf(format, "real", elapsedString(real, cm.PosixFormat))
-		// TODO: can we do these?
-		r.outf(format, "user", elapsedString(0, cm.PosixFormat))
-		r.outf(format, "sys", elapsedString(0, cm.PosixFormat))
+		if format == "" {
+			break
+		}
+		out, err := timeFormat(format, real, user-startUser, sys-startSys)
+		if err != nil {
+			r.errf("%v\n", err)
+			break
+		}
+		r.errf("%s\n", out)
 	default:
 		panic(fmt.Sprintf("unhandled command node: %T", cm))
 	}
 }
 
-func (r *Runner) trapCallback(ctx context.Context, callback, name string) {
+// trapCallback runs a trap's callback, where line is the line number of the
+// statement which triggered it. The callback sees the current exit status as $?,
+// and the exit status is restored once the callback is done.
+func (r *Runner) trapCallback(ctx context.Context, callback, name string, line uint) {
 	if callback == "" {
 		return // nothing to do
 	}
 	if r.handlingTrap {
 		return // don't recurse, as that could lead to cycles
 	}
-	r.handlingTrap = true
 
 	p := syntax.NewParser()
 	// TODO: do this parsing when "trap" is called?
@@ -734,17 +930,25 @@ func (r *Runner) trapCallback(ctx context.Context, callback, name string) {
 		// ignore errors in the callback
 		return
 	}
-	r.stmts(ctx, file.Stmts)
 
+	r.handlingTrap = true
+	r.trapLine = line
+	oldExit, oldLastExit := r.exit, r.lastExit
+	r.lastExit = r.exit
+	r.stmts(ctx, file.Stmts)
+	r.exit, r.lastExit = oldExit, oldLastExit
+	r.trapLine = 0
 	r.handlingTrap = false
 }
 
 // exitShell exits the current shell session with the given status code.
 func (r *Runner) exitShell(ctx context.Context, status int) {
-	if status != 0 {
-		r.trapCallback(ctx, r.callbackErr, "error")
+	var line uint
+	if r.bashCommand != nil {
+		line = r.bashCommand.Pos().Line()
 	}
-	r.trapCallback(ctx, r.callbackExit, "exit")
+	r.exit = status
+	r.trapCallback(ctx, r.callbackExit, "exit", line)
 
 	r.shellExited = true
 	// Restore the original exit status. We ignore the callbacks.
@@ -775,8 +979,14 @@ func (r *Runner) flattenAssign(as *syntax.Assign) []*syntax.Assign {
 	return asgns
 }
 
-func match(pat, name string) bool {
-	expr, err := pattern.Regexp(pat, pattern.EntireString)
+// match reports whether a pattern matches an entire string,
+// such as in case clauses, following the nocasematch option.
+func (r *Runner) match(pat, name string) bool {
+	mode := pattern.EntireString
+	if r.opts[optNoCaseMatch] {
+		mode |= pattern.NoGlobCase
+	}
+	expr, err := pattern.Regexp(pat, mode)
 	if err != nil {
 		return false
 	}
@@ -784,13 +994,92 @@ func match(pat, name string) bool {
 	return rx.MatchString(name)
 }
 
-func elapsedString(d time.Duration, posix bool) string {
-	if posix {
-		return fmt.Sprintf("%.2f", d.Seconds())
+// timeFormat formats the times reported by the "time" keyword as per a
+// TIMEFORMAT string, which supports the following sequences:
+//
+//	%%        a literal %
+//	%[p][l]R  the elapsed real time in seconds
+//	%[p][l]U  the user CPU time in seconds
+//	%[p][l]S  the system CPU time in seconds
+//	%P        the CPU percentage, computed as (%U + %S) / %R
+//
+// See [elapsedString] for the meaning of the precision p and the l modifier.
+func timeFormat(format string, real, user, sys time.Duration) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = format[i]; c {
+		case '%':
+			sb.WriteByte('%')
+			continue
+		case 'P':
+			var cpu time.Duration // in hundredths of a percent
+			if real > 0 {
+				cpu = min((user+sys)*10000/real, 10000)
+			}
+			fmt.Fprintf(&sb, "%d.%02d", cpu/100, cpu%100)
+			continue
+		}
+		prec, long := 3, false
+		if '0' <= c && c <= '9' {
+			prec = min(int(c-'0'), 3)
+			if i++; i < len(format) {
+				c = format[i]
+			} else {
+				c = 0
+			}
+		}
+		if c == 'l' {
+			long = true
+			if i++; i < len(format) {
+				c = format[i]
+			} else {
+				c = 0
+			}
+		}
+		var d time.Duration
+		switch c {
+		case 'R':
+			d = real
+		case 'U':
+			d = user
+		case 'S':
+			d = sys
+		default:
+			return "", fmt.Errorf("TIMEFORMAT: `%c': invalid format character", c)
+		}
+		sb.WriteString(elapsedString(d, prec, long))
 	}
-	min := int(d.Minutes())
-	sec := math.Mod(d.Seconds(), 60.0)
-	return fmt.Sprintf("%dm%.3fs", min, sec)
+	return sb.String(), nil
+}
+
+// elapsedString formats a duration in seconds with a number of decimal places
+// between 0 and 3. The long form also includes minutes, like "1m2.345s".
+// Like in Bash, the fraction is truncated rather than rounded.
+func elapsedString(d time.Duration, prec int, long bool) string {
+	ms := d.Milliseconds()
+	sec, frac := ms/1000, ms%1000
+	var sb strings.Builder
+	if long {
+		fmt.Fprintf(&sb, "%dm", sec/60)
+		sec %= 60
+	}
+	fmt.Fprintf(&sb, "%d", sec)
+	if prec > 0 {
+		for range 3 - prec {
+			frac /= 10
+		}
+		fmt.Fprintf(&sb, ".%0*d", prec, frac)
+	}
+	if long {
+		sb.WriteByte('s')
+	}
+	return sb.String()
 }
 
 func (r *Runner) stmts(ctx context.Context, stmts []*syntax.Stmt) {
@@ -799,8 +1088,8 @@ func (r *Runner) stmts(ctx context.Context, stmts []*syntax.Stmt) {
 	}
 }
 
-func (r *Runner) hdocReader(rd *syntax.Redirect) (*os.File, error) {
-	pr, pw, err := os.Pipe()
+func (r *Runner) hdocReader(rd *syntax.Redirect) (io.ReadCloser, error) {
+	pr, pw, err := pipe()
 	if err != nil {
 		return nil, err
 	}
@@ -811,7 +1100,7 @@ func (r *Runner) hdocReader(rd *syntax.Redirect) (*os.File, error) {
 	if rd.Op != syntax.DashHdoc {
 		hdoc := r.document(rd.Hdoc)
 		go func() {
-			pw.WriteString(hdoc)
+			io.WriteString(pw, hdoc)
 			pw.Close()
 		}()
 		return pr, nil
@@ -873,9 +1162,12 @@ func (r *Runner) redir(ctx context.Context, rd *syntax.Redirect) (io.Closer, err
 		}
 	}
 	arg := r.literal(rd.Word)
+	if r.err != nil {
+		return nil, r.err // e.g. the expansion was not allowed by the policy
+	}
 	switch rd.Op {
 	case syntax.WordHdoc:
-		pr, pw, err := os.Pipe()
+		pr, pw, err := pipe()
 		if err != nil {
 			return nil, err
 		}
@@ -883,8 +1175,8 @@ func (r *Runner) redir(ctx context.Context, rd *syntax.Redirect) (io.Closer, err
 		// We write to the pipe in a new goroutine,
 		// as pipe writes may block once the buffer gets full.
 		go func() {
-			pw.WriteString(arg)
-			pw.WriteString("\n")
+			io.WriteString(pw, arg)
+			io.WriteString(pw, "\n")
 			pw.Close()
 		}()
 		return pr, nil
@@ -927,7 +1219,7 @@ func (r *Runner) redir(ctx context.Context, rd *syntax.Redirect) (io.Closer, err
 	}
 	switch rd.Op {
 	case syntax.RdrIn:
-		stdin, err := stdinFile(f)
+		stdin, err := stdinReader(f)
 		if err != nil {
 			return nil, err
 		}
@@ -1012,6 +1304,13 @@ func (r *Runner) call(ctx context.Context, pos syntax.Pos, args []string) {
 
 func (r *Runner) exec(ctx context.Context, args []string) {
 	err := r.execHandler(r.handlerCtx(ctx), args)
+	if sp, ok := err.(*stoppedProcess); ok {
+		r.stopJob(sp, nil)
+		return
+	}
+	if ss, ok := err.(*shellScript); ok {
+		err = r.runScript(ctx, ss.path, args)
+	}
 	if status, ok := IsExitStatus(err); ok {
 		r.exit = int(status)
 		return
@@ -1024,8 +1323,57 @@ func (r *Runner) exec(ctx context.Context, args []string) {
 	r.exit = 0
 }
 
+// runScript runs a file which could not be executed directly as a shell script,
+// as returned by [DefaultExecHandler] via [shellScript]. Like in Bash, the script
+// runs in a new shell which only inherits exported variables and functions.
+func (r *Runner) runScript(ctx context.Context, path string, args []string) error {
+	f, err := r.open(ctx, path, os.O_RDONLY, 0, true)
+	if err != nil {
+		return NewExitStatus(126)
+	}
+	defer f.Close()
+	src, err := io.ReadAll(f)
+	if err != nil {
+		r.errf("%s: %v\n", args[0], err)
+		return NewExitStatus(126)
+	}
+	// Like Bash, refuse to interpret binary files,
+	// which have a null byte before the first newline.
+	line, _, _ := bytes.Cut(src, []byte("\n"))
+	if bytes.IndexByte(line, 0) >= 0 {
+		r.errf("%s: cannot execute binary file: Exec format error\n", args[0])
+		return NewExitStatus(126)
+	}
+	file, err := syntax.NewParser().Parse(bytes.NewReader(src), args[0])
+	if err != nil {
+		r.errf("%v\n", err)
+		return NewExitStatus(2)
+	}
+	env := &overlayEnviron{parent: r.writeEnv, values: r.funcsEnv()}
+	r2 := &Runner{
+		Env:            expand.ListEnviron(execEnv(env)...),
+		Dir:            r.Dir,
+		Params:         args[1:],
+		callHandler:    r.callHandler,
+		execHandler:    r.execHandler,
+		openHandler:    r.openHandler,
+		readDirHandler: r.readDirHandler,
+		statHandler:    r.statHandler,
+		noExportFuncs:  r.noExportFuncs,
+		stdin:          r.stdin,
+		stdout:         r.stdout,
+		stderr:         r.stderr,
+		usedNew:        true,
+	}
+	for i, opt := range bashOptsTable {
+		r2.opts[len(shellOptsTable)+i] = opt.defaultState
+	}
+	r2.Reset()
+	return r2.Run(ctx, file)
+}
+
 func (r *Runner) open(ctx context.Context, path string, flags int, mode os.FileMode, print bool) (io.ReadWriteCloser, error) {
-	f, err := r.openHandler(r.handlerCtx(ctx), path, flags, mode)
+	f, err := r.openHandler(r.handlerCtx(ctx), r.nativePath(path), flags, mode)
 	// TODO: support wrapped PathError returned from openHandler.
 	switch err.(type) {
 	case nil:
@@ -1041,11 +1389,18 @@ func (r *Runner) open(ctx context.Context, path string, flags int, mode os.FileM
 }
 
 func (r *Runner) stat(ctx context.Context, name string) (fs.FileInfo, error) {
-	path := absPath(r.Dir, name)
-	return r.statHandler(ctx, path, true)
+	path := r.absPath(name)
+	return r.statHandler(r.handlerCtx(ctx), path, true)
 }
 
 func (r *Runner) lstat(ctx context.Context, name string) (fs.FileInfo, error) {
-	path := absPath(r.Dir, name)
-	return r.statHandler(ctx, path, false)
+	path := r.absPath(name)
+	return r.statHandler(r.handlerCtx(ctx), path, false)
+}
+
+// statFunc returns a function like [os.Stat] which uses the stat handler.
+func (r *Runner) statFunc(ctx context.Context) statFunc {
+	return func(name string) (fs.FileInfo, error) {
+		return r.stat(ctx, name)
+	}
 }
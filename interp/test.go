@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
 
 	"golang.org/x/term"
@@ -40,7 +39,7 @@ func (r *Runner) bashTest(ctx context.Context, expr syntax.TestExpr, classic boo
 				}
 			} else { // [[
 				pattern := r.pattern(yw)
-				if match(pattern, str) == (x.Op != syntax.TsNoMatch) {
+				if r.match(pattern, str) == (x.Op != syntax.TsNoMatch) {
 					return "1"
 				}
 			}
@@ -62,12 +61,19 @@ func (r *Runner) bashTest(ctx context.Context, expr syntax.TestExpr, classic boo
 func (r *Runner) binTest(ctx context.Context, op syntax.BinTestOperator, x, y string) bool {
 	switch op {
 	case syntax.TsReMatch:
+		if r.opts[optNoCaseMatch] {
+			y = "(?i)" + y
+		}
 		re, err := regexp.Compile(y)
 		if err != nil {
 			r.exit = 2
 			return false
 		}
-		return re.MatchString(x)
+		// Like Bash, unmatched groups result in empty strings,
+		// and no match at all results in an empty array.
+		m := re.FindStringSubmatch(x)
+		r.setVarInternal("BASH_REMATCH", expand.Variable{Kind: expand.Indexed, List: m})
+		return m != nil
 	case syntax.TsNewer:
 		info1, err1 := r.stat(ctx, x)
 		info2, err2 := r.stat(ctx, y)
@@ -148,7 +154,13 @@ func (r *Runner) unTest(ctx context.Context, op syntax.UnTestOperator, x string)
 		return r.statMode(ctx, x, os.ModeSetgid)
 	// case syntax.TsGrpOwn:
 	// case syntax.TsUsrOwn:
-	// case syntax.TsModif:
+	case syntax.TsModif:
+		info, err := r.stat(ctx, x)
+		if err != nil {
+			return false
+		}
+		atime, ok := accessTime(info)
+		return ok && info.ModTime().After(atime)
 	case syntax.TsRead:
 		f, err := r.open(ctx, x, os.O_RDONLY, 0, false)
 		if err == nil {
@@ -162,7 +174,7 @@ func (r *Runner) unTest(ctx context.Context, op syntax.UnTestOperator, x string)
 		}
 		return err == nil
 	case syntax.TsExec:
-		_, err := exec.LookPath(r.absPath(x))
+		_, err := findExecutable(r.statFunc(ctx), "", r.absPath(x), pathExts(r.writeEnv))
 		return err == nil
 	case syntax.TsNoEmpty:
 		info, err := r.stat(ctx, x)
@@ -195,7 +207,7 @@ func (r *Runner) unTest(ctx context.Context, op syntax.UnTestOperator, x string)
 		}
 		return false
 	case syntax.TsVarSet:
-		return r.lookupVar(x).IsSet()
+		return r.varRefSet(x)
 	case syntax.TsRefVar:
 		return r.lookupVar(x).Kind == expand.NameRef
 	case syntax.TsNot:
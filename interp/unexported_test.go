@@ -4,6 +4,8 @@
 package interp
 
 import (
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -12,34 +14,134 @@ func TestElapsedString(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		in    time.Duration
-		posix bool
-		want  string
+		in   time.Duration
+		prec int
+		long bool
+		want string
 	}{
-		{time.Nanosecond, false, "0m0.000s"},
-		{time.Millisecond, false, "0m0.001s"},
-		{time.Millisecond, true, "0.00"},
-		{2500 * time.Millisecond, false, "0m2.500s"},
-		{2500 * time.Millisecond, true, "2.50"},
+		{time.Nanosecond, 3, true, "0m0.000s"},
+		{time.Millisecond, 3, true, "0m0.001s"},
+		{time.Millisecond, 2, false, "0.00"},
+		{2500 * time.Millisecond, 3, true, "0m2.500s"},
+		{2500 * time.Millisecond, 2, false, "2.50"},
+		{2599 * time.Millisecond, 1, false, "2.5"},
+		{2599 * time.Millisecond, 0, false, "2"},
+		{2599 * time.Millisecond, 0, true, "0m2s"},
 		{
 			10*time.Minute + 10*time.Second,
-			false,
+			3, true,
 			"10m10.000s",
 		},
 		{
 			10*time.Minute + 10*time.Second,
-			true,
+			2, false,
 			"610.00",
 		},
-		{31 * time.Second, false, "0m31.000s"},
-		{102 * time.Second, false, "1m42.000s"},
+		{31 * time.Second, 3, true, "0m31.000s"},
+		{102 * time.Second, 3, true, "1m42.000s"},
 	}
 	for _, tc := range tests {
 		t.Run(tc.in.String(), func(t *testing.T) {
-			got := elapsedString(tc.in, tc.posix)
+			got := elapsedString(tc.in, tc.prec, tc.long)
 			if got != tc.want {
 				t.Fatalf("wanted %q, got %q", tc.want, got)
 			}
 		})
 	}
 }
+
+func TestTimeFormat(t *testing.T) {
+	t.Parallel()
+
+	const (
+		real = 2500 * time.Millisecond
+		user = 1234 * time.Millisecond
+		sys  = 16 * time.Millisecond
+	)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"%%R 100%", "%R 100%"},
+		{"%R %U %S", "2.500 1.234 0.016"},
+		{"%lR|%2lU|%0S", "0m2.500s|0m1.23s|0"},
+		{"%9R", "2.500"},
+		{"%P", "50.00"},
+		{"\nreal\t%3lR\nuser\t%3lU\nsys\t%3lS", "\nreal\t0m2.500s\nuser\t0m1.234s\nsys\t0m0.016s"},
+		{"%Z", "TIMEFORMAT: `Z': invalid format character"},
+		{"%3lX", "TIMEFORMAT: `X': invalid format character"},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			got, err := timeFormat(tc.format, real, user, sys)
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Fatalf("wanted %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWinScriptArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path string
+		args []string
+		want []string
+	}{
+		{`C:\bin\tool.exe`, []string{"tool", "x"}, nil},
+		{`C:\bin\tool.bat`, []string{"tool", "x"}, nil},
+		{
+			`C:\bin\tool.PS1`, []string{"tool", "x", "y"},
+			[]string{"powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", `C:\bin\tool.PS1`, "x", "y"},
+		},
+		{`C:\bin\tool.vbs`, []string{"tool"}, []string{"cscript", "//Nologo", `C:\bin\tool.vbs`}},
+	}
+	for _, test := range tests {
+		got := winScriptArgs(test.path, test.args)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("winScriptArgs(%q, %q) got %q, want %q", test.path, test.args, got, test.want)
+		}
+	}
+	// The runner arguments must not be modified.
+	winScriptArgs(`a.ps1`, []string{"a", "b"})
+	if got := winScriptRunners[".ps1"]; len(got) != 5 {
+		t.Errorf("winScriptRunners was modified: %q", got)
+	}
+}
+
+func TestMSYSPaths(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		msys, native string
+	}{
+		{"/c", `C:\`},
+		{"/c/Users/foo", `C:\Users\foo`},
+		{"/D/tmp", `D:\tmp`},
+	}
+	for _, test := range tests {
+		if got := msysToNative(test.msys); got != test.native {
+			t.Errorf("msysToNative(%q) got %q, want %q", test.msys, got, test.native)
+		}
+		if got, want := nativeToMSYS(test.native), strings.ToLower(test.msys[:2])+test.msys[2:]; got != want {
+			t.Errorf("nativeToMSYS(%q) got %q, want %q", test.native, got, want)
+		}
+	}
+	// Paths which aren't in the other form are left untouched.
+	for _, path := range []string{"", "/", "/cd/foo", "foo/bar", "/usr/bin"} {
+		if got := msysToNative(path); got != path {
+			t.Errorf("msysToNative(%q) got %q, want it unchanged", path, got)
+		}
+	}
+	for _, path := range []string{"", `C:`, `C:foo`, `\\server\share`, "/c/foo"} {
+		if got := nativeToMSYS(path); got != path {
+			t.Errorf("nativeToMSYS(%q) got %q, want it unchanged", path, got)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -150,6 +151,79 @@ func TestRunnerTerminalExec(t *testing.T) {
 	}
 }
 
+func TestRunnerJobControl(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("stopped processes are only detected on Linux")
+	}
+	t.Parallel()
+	const stopper = `sh -c 'kill -STOP $$; echo resumed'`
+	tests := []struct {
+		in   string
+		opts []interp.RunnerOption
+		want string
+	}{
+		{
+			stopper + "; echo $?; fg; echo $?",
+			nil,
+			"\n[1]+  Stopped                 " + stopper + "\n148\n" +
+				stopper + "\nresumed\n0\n",
+		},
+		{
+			stopper + "; fg %1; fg %1",
+			nil,
+			"\n[1]+  Stopped                 " + stopper + "\n" +
+				stopper + "\nresumed\nfg: %1: no such job\n",
+		},
+		{
+			stopper + "; bg; wait; echo $?; bg",
+			nil,
+			"\n[1]+  Stopped                 " + stopper + "\n" +
+				"[1]+ " + stopper + " &\nresumed\n0\nbg: current: no such job\n",
+		},
+		{
+			"true & disown; fg",
+			nil,
+			"fg: current: no such job\n",
+		},
+		{
+			"(exit 3) & sleep 0.2; echo next",
+			nil,
+			"next\n[1]+  Exit 3                  (exit 3)\n",
+		},
+		{
+			"set -b; true & sleep 0.2; echo next",
+			nil,
+			"[1]+  Done                    true\nnext\n",
+		},
+		{
+			"suspend",
+			[]interp.RunnerOption{interp.LoginShell(true)},
+			"suspend: cannot suspend a login shell\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			file := parse(t, nil, test.in)
+			var out concBuffer
+			opts := append([]interp.RunnerOption{
+				interp.Interactive(true),
+				interp.StdIO(nil, &out, &out),
+			}, test.opts...)
+			r, err := interp.New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), runnerRunTimeout)
+			defer cancel()
+			r.Run(ctx, file)
+			if got := out.String(); got != test.want {
+				t.Fatalf("\nwant: %q\ngot:  %q", test.want, got)
+			}
+		})
+	}
+}
+
 func shortPathName(path string) (string, error) {
 	panic("only works on windows")
 }
@@ -11,6 +11,8 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"mvdan.cc/sh/v3/expand"
 	"mvdan.cc/sh/v3/syntax"
@@ -23,6 +25,21 @@ type overlayEnviron struct {
 	// We need to know if the current scope is a function's scope, because
 	// functions can modify global variables.
 	funcScope bool
+
+	// changed holds the previous value of each variable modified since
+	// change tracking started, if non-nil. See [TrackVarChanges].
+	changed map[string]expand.Variable
+}
+
+// recordChange remembers the value of a variable before its first
+// modification, if change tracking is enabled.
+func (o *overlayEnviron) recordChange(name string) {
+	if o.changed == nil {
+		return
+	}
+	if _, ok := o.changed[name]; !ok {
+		o.changed[name] = o.Get(name)
+	}
 }
 
 func (o *overlayEnviron) Get(name string) expand.Variable {
@@ -48,6 +65,7 @@ func (o *overlayEnviron) Set(name string, vr expand.Variable) error {
 		return o.parent.(expand.WriteEnviron).Set(name, vr)
 	}
 
+	o.recordChange(name)
 	prev := o.Get(name)
 	if o.values == nil {
 		o.values = make(map[string]expand.Variable)
@@ -81,6 +99,31 @@ func (o *overlayEnviron) Set(name string, vr expand.Variable) error {
 	return nil
 }
 
+// clearAttrs removes attributes from a variable, such as with "declare +x".
+// Removing the readonly attribute or turning an array into a string is not
+// possible, so the caller must check for those beforehand.
+func (o *overlayEnviron) clearAttrs(name string, attrs varAttr) {
+	if o.funcScope && !o.values[name].Local {
+		// Like in Set, a function may modify global variables.
+		if parent, ok := o.parent.(*overlayEnviron); ok {
+			parent.clearAttrs(name, attrs)
+			return
+		}
+	}
+	o.recordChange(name)
+	vr := o.Get(name)
+	if attrs&attrExport != 0 {
+		vr.Exported = false
+	}
+	if attrs&attrNameRef != 0 && vr.Kind == expand.NameRef {
+		vr.Kind = expand.String
+	}
+	if o.values == nil {
+		o.values = make(map[string]expand.Variable)
+	}
+	o.values[name] = vr
+}
+
 func (o *overlayEnviron) Each(f func(name string, vr expand.Variable) bool) {
 	o.parent.Each(f)
 	for name, vr := range o.values {
@@ -90,6 +133,14 @@ func (o *overlayEnviron) Each(f func(name string, vr expand.Variable) bool) {
 	}
 }
 
+// varEqual reports whether two variables have the same value and attributes.
+func varEqual(a, b expand.Variable) bool {
+	return a.Local == b.Local && a.Exported == b.Exported && a.ReadOnly == b.ReadOnly &&
+		a.Kind == b.Kind && a.Str == b.Str &&
+		slices.Equal(a.List, b.List) && slices.Equal(a.Indices, b.Indices) &&
+		maps.Equal(a.Map, b.Map)
+}
+
 func execEnv(env expand.Environ) []string {
 	list := make([]string, 0, 64)
 	env.Each(func(name string, vr expand.Variable) bool {
@@ -113,6 +164,13 @@ func execEnv(env expand.Environ) []string {
 	return list
 }
 
+// singleLine prints a node on a single line, as done for $BASH_COMMAND.
+func singleLine(node syntax.Node) string {
+	var sb strings.Builder
+	syntax.NewPrinter(syntax.SingleLine(true)).Print(&sb, node)
+	return sb.String()
+}
+
 func (r *Runner) lookupVar(name string) expand.Variable {
 	if name == "" {
 		panic("variable name must not be empty")
@@ -137,6 +195,21 @@ func (r *Runner) lookupVar(name string) expand.Variable {
 		vr.Kind, vr.Str = expand.String, strconv.Itoa(os.Getppid())
 	case "DIRSTACK":
 		vr.Kind, vr.List = expand.Indexed, r.dirStack
+	case "PIPESTATUS":
+		vr.Kind, vr.List = expand.Indexed, make([]string, len(r.pipeStatus))
+		for i, code := range r.pipeStatus {
+			vr.List[i] = strconv.Itoa(code)
+		}
+	case "BASH_COMMAND":
+		if r.bashCommand != nil {
+			vr.Kind, vr.Str = expand.String, singleLine(r.bashCommand)
+		}
+	case "LINENO":
+		if r.trapLine > 0 {
+			vr.Kind, vr.Str = expand.String, strconv.FormatUint(uint64(r.trapLine), 10)
+		}
+		// Otherwise, the expand package uses the position of the expansion.
+		return vr
 	case "0":
 		vr.Kind = expand.String
 		if r.filename != "" {
@@ -184,6 +257,68 @@ func (r *Runner) setVarString(name, value string) {
 	r.setVar(name, nil, expand.Variable{Kind: expand.String, Str: value})
 }
 
+// parseVarRef parses a reference to a variable or to one of its array elements,
+// such as "foo" or "foo[3]", as given to builtins like "printf -v".
+// The index is evaluated by [Runner.setVar].
+func (r *Runner) parseVarRef(ref string) (name string, index syntax.ArithmExpr, ok bool) {
+	if syntax.ValidName(ref) {
+		return ref, nil, true
+	}
+	if !strings.HasSuffix(ref, "]") || !strings.Contains(ref, "[") {
+		return "", nil, false
+	}
+	file, err := syntax.NewParser().Parse(strings.NewReader(ref+"="), "")
+	if err != nil || len(file.Stmts) != 1 || len(file.Stmts[0].Redirs) > 0 {
+		return "", nil, false
+	}
+	call, ok := file.Stmts[0].Cmd.(*syntax.CallExpr)
+	if !ok || len(call.Args) > 0 || len(call.Assigns) != 1 {
+		return "", nil, false
+	}
+	as := call.Assigns[0]
+	if as.Index == nil || as.Value != nil || as.Append {
+		return "", nil, false
+	}
+	return as.Name.Value, as.Index, true
+}
+
+// varRefSet reports whether a variable or one of its array elements is set,
+// as used by the -v test.
+func (r *Runner) varRefSet(ref string) bool {
+	if base, ok := strings.CutSuffix(ref, "[@]"); ok {
+		ref = base
+	} else if base, ok := strings.CutSuffix(ref, "[*]"); ok {
+		ref = base
+	}
+	name, index, ok := r.parseVarRef(ref)
+	if !ok {
+		return false
+	}
+	vr := r.lookupVar(name)
+	if index == nil {
+		return vr.IsSet()
+	}
+	switch vr.Kind {
+	case expand.String:
+		return r.arithm(index) == 0
+	case expand.Indexed:
+		i, ok := vr.ArrayIndex(r.arithm(index))
+		if !ok {
+			return false
+		}
+		_, ok = vr.ArrayElem(i)
+		return ok
+	case expand.Associative:
+		w, ok := index.(*syntax.Word)
+		if !ok {
+			return false
+		}
+		_, ok = vr.Map[r.literal(w)]
+		return ok
+	}
+	return false
+}
+
 func (r *Runner) setVarInternal(name string, vr expand.Variable) {
 	if r.opts[optAllExport] {
 		vr.Exported = true
@@ -225,13 +360,17 @@ func (r *Runner) setVar(name string, index syntax.ArithmExpr, vr expand.Variable
 	// is non-nil; nested arrays are forbidden.
 	valStr := vr.Str
 
-	var list []string
 	switch cur.Kind {
+	case expand.Unset:
+		cur.Kind = expand.Indexed
 	case expand.String:
-		list = append(list, cur.Str)
+		cur.Kind = expand.Indexed
+		cur.List = []string{cur.Str}
+		cur.Str = ""
 	case expand.Indexed:
 		// TODO: only clone when inside a subshell and getting a var from outside for the first time
-		list = slices.Clone(cur.List)
+		cur.List = slices.Clone(cur.List)
+		cur.Indices = slices.Clone(cur.Indices)
 	case expand.Associative:
 		// if the existing variable is already an AssocArray, try our
 		// best to convert the key to a string
@@ -248,15 +387,233 @@ func (r *Runner) setVar(name string, index syntax.ArithmExpr, vr expand.Variable
 		return
 	}
 	k := r.arithm(index)
-	for len(list) < k+1 {
-		list = append(list, "")
+	i, ok := cur.ArrayIndex(k)
+	if !ok {
+		r.errf("%s[%d]: bad array subscript\n", name, k)
+		r.exit = 1
+		return
 	}
-	list[k] = valStr
-	cur.Kind = expand.Indexed
-	cur.List = list
+	cur.SetArrayElem(i, valStr)
 	r.setVarInternal(name, cur)
 }
 
+const (
+	funcEnvPrefix = "BASH_FUNC_"
+	funcEnvSuffix = "%%"
+)
+
+// funcsEnv returns the environment variables which export the functions
+// marked with "export -f" to child processes, encoded like Bash does.
+func (r *Runner) funcsEnv() map[string]expand.Variable {
+	if r.noExportFuncs || len(r.exportedFuncs) == 0 {
+		return nil
+	}
+	values := make(map[string]expand.Variable, len(r.exportedFuncs))
+	printer := syntax.NewPrinter()
+	for name := range r.exportedFuncs {
+		body := r.Funcs[name]
+		if body == nil {
+			continue
+		}
+		var sb strings.Builder
+		sb.WriteString("() ")
+		printer.Print(&sb, body)
+		values[funcEnvPrefix+name+funcEnvSuffix] = expand.Variable{
+			Exported: true,
+			Kind:     expand.String,
+			Str:      sb.String(),
+		}
+	}
+	return values
+}
+
+// importFuncs defines the functions exported by a parent process, as encoded
+// by [Runner.funcsEnv], and removes their environment variables.
+// Any variable which isn't a single well-formed function declaration is
+// skipped, so that the environment can't be used to run arbitrary code.
+func (r *Runner) importFuncs() {
+	var names []string
+	r.writeEnv.Each(func(name string, vr expand.Variable) bool {
+		if strings.HasPrefix(name, funcEnvPrefix) && strings.HasSuffix(name, funcEnvSuffix) &&
+			vr.Kind == expand.String && strings.HasPrefix(vr.Str, "() ") {
+			names = append(names, name)
+		}
+		return true
+	})
+	parser := syntax.NewParser()
+	for _, name := range names {
+		fname := strings.TrimSuffix(strings.TrimPrefix(name, funcEnvPrefix), funcEnvSuffix)
+		if fname == "" || strings.ContainsAny(fname, " \t\n;&|<>()$`'\"\\") {
+			continue
+		}
+		src := fname + " " + r.writeEnv.Get(name).Str
+		file, err := parser.Parse(strings.NewReader(src), "")
+		if err != nil || len(file.Stmts) != 1 {
+			continue
+		}
+		st := file.Stmts[0]
+		fn, ok := st.Cmd.(*syntax.FuncDecl)
+		if !ok || fn.Name.Value != fname || st.Negated || st.Background ||
+			st.Coprocess || len(st.Redirs) > 0 {
+			continue
+		}
+		r.setFunc(fname, fn.Body)
+		if r.exportedFuncs == nil {
+			r.exportedFuncs = make(map[string]bool)
+		}
+		r.exportedFuncs[fname] = true
+		r.delVar(name)
+	}
+}
+
+// declared reports whether a variable exists in the environment,
+// either because it is set or because it has any attributes.
+// varAttr is a set of variable attributes, as added by "declare -x" and
+// removed by "declare +x". The value kinds are included as attributes too,
+// as "declare" treats them in the same way.
+type varAttr uint8
+
+const (
+	attrIndexed  varAttr = 1 << iota // -a
+	attrAssoc                        // -A
+	attrNameRef                      // -n
+	attrReadOnly                     // -r
+	attrExport                       // -x
+)
+
+// attrFlags holds the "declare" option for each attribute, in the order in
+// which Bash prints them.
+const attrFlags = "aAnrx"
+
+// parseAttr returns the attribute for a "declare" option such as 'x',
+// or zero if the option is not an attribute.
+func parseAttr(flag rune) varAttr {
+	if i := strings.IndexRune(attrFlags, flag); i >= 0 {
+		return 1 << i
+	}
+	return 0
+}
+
+// attrsOf returns the attributes which a variable currently has.
+func attrsOf(vr expand.Variable) varAttr {
+	var attrs varAttr
+	switch vr.Kind {
+	case expand.Indexed:
+		attrs |= attrIndexed
+	case expand.Associative:
+		attrs |= attrAssoc
+	case expand.NameRef:
+		attrs |= attrNameRef
+	}
+	if vr.ReadOnly {
+		attrs |= attrReadOnly
+	}
+	if vr.Exported {
+		attrs |= attrExport
+	}
+	return attrs
+}
+
+// String returns the attributes as "declare" options, such as "rx".
+func (a varAttr) String() string {
+	var sb strings.Builder
+	for i, flag := range attrFlags {
+		if a&(1<<i) != 0 {
+			sb.WriteRune(flag)
+		}
+	}
+	return sb.String()
+}
+
+func declared(vr expand.Variable) bool {
+	return vr.IsSet() || vr.Exported || vr.ReadOnly || vr.Local
+}
+
+// printDecls prints all declared variables in the format used by "declare -p",
+// only including those with all of the given attributes.
+func (r *Runner) printDecls(attrs varAttr) {
+	var names []string
+	seen := make(map[string]bool)
+	r.writeEnv.Each(func(name string, vr expand.Variable) bool {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return true
+	})
+	slices.Sort(names)
+	for _, name := range names {
+		vr := r.writeEnv.Get(name)
+		if !declared(vr) || attrs&^attrsOf(vr) != 0 {
+			continue
+		}
+		r.printDecl(name, vr)
+	}
+}
+
+// printDecl prints a variable as a "declare" command which can be run to
+// recreate it, matching the output of "declare -p" in Bash.
+func (r *Runner) printDecl(name string, vr expand.Variable) {
+	flags := attrsOf(vr).String()
+	if flags == "" {
+		flags = "-"
+	}
+	r.outf("declare -%s %s", flags, name)
+	switch vr.Kind {
+	case expand.String, expand.NameRef:
+		r.outf("=%s", declQuote(vr.Str))
+	case expand.Indexed:
+		r.out("=(")
+		for i, index := range vr.ArrayIndices() {
+			if i > 0 {
+				r.out(" ")
+			}
+			r.outf("[%d]=%s", index, declQuote(vr.List[i]))
+		}
+		r.out(")")
+	case expand.Associative:
+		r.out("=(")
+		keys := make([]string, 0, len(vr.Map))
+		for k := range vr.Map {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			qk := k
+			if q, err := syntax.Quote(k, syntax.LangBash); err != nil || q != k {
+				qk = declQuote(k)
+			}
+			r.outf("[%s]=%s ", qk, declQuote(vr.Map[k]))
+		}
+		r.out(")")
+	}
+	r.out("\n")
+}
+
+// declQuote quotes a value like Bash does in the output of "declare -p";
+// double quotes are used unless the value has any non-printable characters.
+func declQuote(s string) string {
+	for _, r := range s {
+		if r == utf8.RuneError || (!unicode.IsPrint(r) && r != ' ') {
+			if q, err := syntax.Quote(s, syntax.LangBash); err == nil {
+				return q
+			}
+			break
+		}
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\', '$', '`':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
 func (r *Runner) setFunc(name string, body *syntax.Stmt) {
 	if r.Funcs == nil {
 		r.Funcs = make(map[string]*syntax.Stmt, 4)
@@ -278,13 +635,13 @@ func stringIndex(index syntax.ArithmExpr) bool {
 
 // TODO: make assignVal and [setVar] consistent with the [expand.WriteEnviron] interface
 
-func (r *Runner) assignVal(as *syntax.Assign, valType string) expand.Variable {
+func (r *Runner) assignVal(as *syntax.Assign, attrs varAttr) expand.Variable {
 	prev := r.lookupVar(as.Name.Value)
 	if as.Value != nil {
 		s := r.literal(as.Value)
 		if !as.Append || !prev.IsSet() {
 			prev.Kind = expand.String
-			if valType == "-n" {
+			if attrs&attrNameRef != 0 {
 				prev.Kind = expand.NameRef
 			}
 			prev.Str = s
@@ -294,10 +651,12 @@ func (r *Runner) assignVal(as *syntax.Assign, valType string) expand.Variable {
 		case expand.String:
 			prev.Str += s
 		case expand.Indexed:
-			if len(prev.List) == 0 {
-				prev.List = append(prev.List, "")
-			}
-			prev.List[0] += s
+			// The list may be shared, such as with a copy of the
+			// variable kept by [TrackVarChanges].
+			prev.List = slices.Clone(prev.List)
+			prev.Indices = slices.Clone(prev.Indices)
+			elem, _ := prev.ArrayElem(0)
+			prev.SetArrayElem(0, elem+s)
 		case expand.Associative:
 			// TODO
 		}
@@ -306,7 +665,7 @@ func (r *Runner) assignVal(as *syntax.Assign, valType string) expand.Variable {
 	if as.Array == nil {
 		// don't return the zero value, as that's an unset variable
 		prev.Kind = expand.String
-		if valType == "-n" {
+		if attrs&attrNameRef != 0 {
 			prev.Kind = expand.NameRef
 		}
 		prev.Str = ""
@@ -314,13 +673,13 @@ func (r *Runner) assignVal(as *syntax.Assign, valType string) expand.Variable {
 	}
 	// Array assignment.
 	elems := as.Array.Elems
-	if valType == "" {
-		valType = "-a" // indexed
+	if attrs&(attrIndexed|attrAssoc) == 0 {
+		attrs |= attrIndexed
 		if len(elems) > 0 && stringIndex(elems[0].Index) {
-			valType = "-A" // associative
+			attrs = attrs&^attrIndexed | attrAssoc
 		}
 	}
-	if valType == "-A" {
+	if attrs&attrAssoc != 0 {
 		amap := make(map[string]string, len(elems))
 		for _, elem := range elems {
 			k := r.literal(elem.Index.(*syntax.Word))
@@ -334,50 +693,45 @@ func (r *Runner) assignVal(as *syntax.Assign, valType string) expand.Variable {
 		// TODO
 		return prev
 	}
-	// Evaluate values for each array element.
-	elemValues := make([]struct {
-		index  int
-		values []string
-	}, len(elems))
-	var index, maxIndex int
-	for i, elem := range elems {
+	arr := expand.Variable{Kind: expand.Indexed, List: []string{}}
+	if as.Append {
+		switch prev.Kind {
+		case expand.String:
+			arr.List = []string{prev.Str}
+		case expand.Indexed:
+			arr.List = slices.Clone(prev.List)
+			arr.Indices = slices.Clone(prev.Indices)
+		case expand.Associative:
+			// TODO
+			return prev
+		}
+	}
+	// Appending starts after the highest index, like "a[-1]" plus one.
+	index, _ := arr.ArrayIndex(-1)
+	index++
+	for _, elem := range elems {
 		if elem.Index != nil {
 			// Index resets our index with a literal value.
-			index = r.arithm(elem.Index)
-			elemValues[i].values = []string{r.literal(elem.Value)}
-		} else {
-			// Implicit index, advancing for every word.
-			elemValues[i].values = r.fields(elem.Value)
+			k := r.arithm(elem.Index)
+			i, ok := arr.ArrayIndex(k)
+			if !ok {
+				r.errf("%s[%d]: bad array subscript\n", as.Name.Value, k)
+				r.exit = 1
+				continue
+			}
+			arr.SetArrayElem(i, r.literal(elem.Value))
+			index = i + 1
+			continue
 		}
-		elemValues[i].index = index
-		index += len(elemValues[i].values)
-		maxIndex = max(maxIndex, index)
-	}
-	// Flatten down the values.
-	strs := make([]string, maxIndex)
-	for _, ev := range elemValues {
-		for i, str := range ev.values {
-			strs[ev.index+i] = str
+		// Implicit index, advancing for every word.
+		for _, field := range r.fields(elem.Value) {
+			arr.SetArrayElem(index, field)
+			index++
 		}
 	}
-	if !as.Append {
-		prev.Kind = expand.Indexed
-		prev.List = strs
-		return prev
-	}
-	switch prev.Kind {
-	case expand.Unset:
-		prev.Kind = expand.Indexed
-		prev.List = strs
-	case expand.String:
-		prev.Kind = expand.Indexed
-		prev.List = append([]string{prev.Str}, strs...)
-	case expand.Indexed:
-		prev.List = append(prev.List, strs...)
-	case expand.Associative:
-		// TODO
-	default:
-		panic(fmt.Sprintf("unhandled conversion of kind %d", prev.Kind))
-	}
+	prev.Kind = expand.Indexed
+	prev.Str = ""
+	prev.List = arr.List
+	prev.Indices = arr.Indices
 	return prev
 }
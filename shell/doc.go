@@ -2,7 +2,8 @@
 // See LICENSE for licensing information
 
 // Package shell contains high-level features that use the syntax, expand, and
-// interp packages under the hood.
+// interp packages under the hood, such as [Run] to run a script without
+// having to set up a parser and an interpreter.
 //
 // Please note that this package uses POSIX Shell syntax. As such, path names on
 // Windows need to use double backslashes or be within single quotes when given
@@ -4,6 +4,7 @@
 package shell_test
 
 import (
+	"context"
 	"fmt"
 
 	"mvdan.cc/sh/v3/shell"
@@ -52,3 +53,13 @@ func ExampleFields() {
 	// []string{"unquoted", "bar", "baz"}
 	// []string{"quoted", "bar baz"}
 }
+
+func ExampleRun() {
+	res, err := shell.Run(context.Background(), `echo "Hello, $1!"; exit 2`,
+		shell.WithArgs("world"), shell.CaptureOutput())
+	fmt.Printf("%q %d\n", res.Stdout, res.ExitStatus)
+	fmt.Println(err)
+	// Output:
+	// "Hello, world!\n" 2
+	// exit status 2
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Result holds the output of a script run via [Run].
+type Result struct {
+	// Stdout and Stderr are only filled when using [CaptureOutput].
+	Stdout, Stderr string
+
+	// ExitStatus is the exit status of the script, where zero means success.
+	ExitStatus int
+}
+
+// RunOption can be passed to [Run] to alter how a script is run.
+type RunOption func(*runConfig)
+
+type runConfig struct {
+	env     []string
+	envSet  bool
+	dir     string
+	params  []string
+	stdin   io.Reader
+	capture bool
+}
+
+// WithEnv sets the environment variables for the script, as "key=value" pairs.
+// The current process's environment is used by default; to extend it rather
+// than replace it, use append(os.Environ(), pairs...).
+func WithEnv(pairs ...string) RunOption {
+	return func(c *runConfig) { c.env, c.envSet = pairs, true }
+}
+
+// WithDir sets the directory the script runs in.
+// The current process's directory is used by default.
+func WithDir(dir string) RunOption {
+	return func(c *runConfig) { c.dir = dir }
+}
+
+// WithArgs sets the script's positional parameters, such as $1.
+func WithArgs(args ...string) RunOption {
+	return func(c *runConfig) { c.params = args }
+}
+
+// WithStdin sets the script's standard input.
+// The current process's standard input is used by default.
+func WithStdin(r io.Reader) RunOption {
+	return func(c *runConfig) { c.stdin = r }
+}
+
+// CaptureOutput makes the script's standard output and standard error be
+// returned in [Result], rather than written to the current process's.
+func CaptureOutput() RunOption {
+	return func(c *runConfig) { c.capture = true }
+}
+
+// Run parses and runs a Bash script, such as:
+//
+//	res, err := shell.Run(ctx, "echo $FOO", shell.WithEnv("FOO=bar"), shell.CaptureOutput())
+//
+// Programs are run via [interp.DefaultExecHandler], and files are opened with
+// [interp.DefaultOpenHandler]. For more control, use the syntax and interp
+// packages directly.
+//
+// A script which exits with a non-zero status results in an error which works
+// with [interp.IsExitStatus], along with a [Result] with the output and exit
+// status. Parse errors and other fatal errors result in a nil Result.
+func Run(ctx context.Context, src string, opts ...RunOption) (*Result, error) {
+	var cfg runConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	file, err := syntax.NewParser().Parse(strings.NewReader(src), "")
+	if err != nil {
+		return nil, err
+	}
+	runOpts := []interp.RunnerOption{interp.Dir(cfg.dir)}
+	if cfg.envSet {
+		runOpts = append(runOpts, interp.Env(expand.ListEnviron(cfg.env...)))
+	}
+	if cfg.params != nil {
+		runOpts = append(runOpts, interp.Params(append([]string{"--"}, cfg.params...)...))
+	}
+	stdin := cfg.stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	var stdout, stderr bytes.Buffer
+	if cfg.capture {
+		runOpts = append(runOpts, interp.StdIO(stdin, &stdout, &stderr))
+	} else {
+		runOpts = append(runOpts, interp.StdIO(stdin, os.Stdout, os.Stderr))
+	}
+	runner, err := interp.New(runOpts...)
+	if err != nil {
+		return nil, err
+	}
+	err = runner.Run(ctx, file)
+	res := &Result{Stdout: stdout.String(), Stderr: stderr.String()}
+	if status, ok := interp.IsExitStatus(err); ok {
+		res.ExitStatus = int(status)
+		return res, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	tests := []struct {
+		src     string
+		opts    []RunOption
+		want    Result
+		wantErr string
+	}{
+		{src: "echo foo", want: Result{Stdout: "foo\n"}},
+		{src: "echo foo >&2; exit 3", want: Result{Stderr: "foo\n", ExitStatus: 3}, wantErr: "exit status 3"},
+		{src: "echo ${FOO-unset} ${BAR-unset}", opts: []RunOption{WithEnv("FOO=foo")}, want: Result{Stdout: "foo unset\n"}},
+		{src: "echo $# $1 $2", opts: []RunOption{WithArgs("-e", "a b")}, want: Result{Stdout: "2 -e a b\n"}},
+		{src: "pwd", opts: []RunOption{WithDir(dir)}, want: Result{Stdout: dir + "\n"}},
+		{src: "read -r line; echo \"$line\"", opts: []RunOption{WithStdin(strings.NewReader("input\n"))}, want: Result{Stdout: "input\n"}},
+		{src: "echo (", wantErr: "must be followed by"},
+		{src: "true", opts: []RunOption{WithDir(filepath.Join(dir, "missing"))}, wantErr: "could not stat"},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			opts := append([]RunOption{CaptureOutput()}, test.opts...)
+			res, err := Run(ctx, test.src, opts...)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("want error containing %q, got %v", test.wantErr, err)
+				}
+				if _, ok := interp.IsExitStatus(err); !ok {
+					if res != nil {
+						t.Fatalf("want nil result with error %v, got %#v", err, res)
+					}
+					return
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if *res != test.want {
+				t.Fatalf("want %#v, got %#v", test.want, *res)
+			}
+		})
+	}
+}
+
+func TestRunNoCapture(t *testing.T) {
+	// Not parallel, as we replace os.Stdout.
+	path := filepath.Join(t.TempDir(), "stdout")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	orig := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = orig }()
+
+	res, err := Run(context.Background(), "echo foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *res != (Result{}) {
+		t.Fatalf("want an empty result, got %#v", res)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "foo\n" {
+		t.Fatalf("want %q written to stdout, got %q", "foo\n", got)
+	}
+}
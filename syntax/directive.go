@@ -0,0 +1,157 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import "strings"
+
+// Directive is a comment which configures a tool for part of a program,
+// such as "# shfmt: off". Directives are written as "# tool: name args...",
+// where the tool is made up of lowercase letters, digits, dashes, and
+// underscores. For example, "# TODO: fix" is not a directive.
+//
+// The directives currently understood by [Printer.PrintSource] are:
+//
+//	# shfmt: off               print the following statements verbatim
+//	# shfmt: on                resume formatting after "shfmt: off"
+//	# shfmt: ignore-next-line  print the next statement verbatim
+type Directive struct {
+	Comment Comment
+
+	Tool string
+	Name string
+	Args []string
+}
+
+// ParseDirective parses a comment as a directive, reporting whether it is one.
+func ParseDirective(c Comment) (Directive, bool) {
+	tool, rest, ok := strings.Cut(strings.TrimSpace(c.Text), ":")
+	if !ok || !validToolName(tool) {
+		return Directive{}, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return Directive{}, false
+	}
+	return Directive{Comment: c, Tool: tool, Name: fields[0], Args: fields[1:]}, true
+}
+
+func validToolName(name string) bool {
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z':
+		case '0' <= r && r <= '9':
+		case r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Directives returns the directives found in the comments directly attached to
+// a node, such as a statement's comments or the trailing comments of a file.
+// Comments attached to the node's children are not included.
+func Directives(node Node) []Directive {
+	var comments []Comment
+	switch node := node.(type) {
+	case *File:
+		comments = node.Last
+	case *Stmt:
+		comments = node.Comments
+	case *CaseItem:
+		comments = node.Comments
+	case *ArrayElem:
+		comments = node.Comments
+	}
+	var dirs []Directive
+	for _, c := range comments {
+		if d, ok := ParseDirective(c); ok {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// shfmtDirective returns the last "shfmt" directive in a statement's
+// comments which appear before the statement itself.
+func shfmtDirective(s *Stmt) (Directive, bool) {
+	var last Directive
+	found := false
+	for _, c := range s.Comments {
+		if s.Pos().After(c.Pos()) {
+			if d, ok := ParseDirective(c); ok && d.Tool == "shfmt" {
+				last, found = d, true
+			}
+		}
+	}
+	return last, found
+}
+
+// directiveRegions returns the regions of src which must be printed verbatim
+// as per the "shfmt" directives in a program. An "off" directive lasts until an
+// "on" directive in the same list of statements, or until the end of the list.
+func directiveRegions(node Node, src []byte) []Region {
+	var regions []Region
+	addList := func(stmts []*Stmt) {
+		for i := 0; i < len(stmts); i++ {
+			d, ok := shfmtDirective(stmts[i])
+			if !ok {
+				continue
+			}
+			end := i
+			switch d.Name {
+			case "ignore-next-line":
+				end = i + 1
+			case "off":
+				for end = i + 1; end < len(stmts); end++ {
+					if d, ok := shfmtDirective(stmts[end]); ok && d.Name == "on" {
+						break
+					}
+				}
+			default:
+				continue
+			}
+			// The region starts on the line after the directive.
+			start := d.Comment.End().Offset()
+			if int(start) < len(src) && src[start] == '\n' {
+				start++
+			}
+			stop := stmts[end-1].End().Offset()
+			if start > stop || int(stop) > len(src) {
+				continue // src does not match the syntax tree
+			}
+			regions = append(regions, Region{Start: start, End: stop, Text: src[start:stop]})
+			i = end - 1
+		}
+	}
+	Walk(node, func(node Node) bool {
+		switch node := node.(type) {
+		case *File:
+			addList(node.Stmts)
+		case *Block:
+			addList(node.Stmts)
+		case *Subshell:
+			addList(node.Stmts)
+		case *CmdSubst:
+			addList(node.Stmts)
+		case *ProcSubst:
+			addList(node.Stmts)
+		case *IfClause:
+			addList(node.Cond)
+			addList(node.Then)
+		case *WhileClause:
+			addList(node.Cond)
+			addList(node.Do)
+		case *ForClause:
+			addList(node.Do)
+		case *CaseItem:
+			addList(node.Stmts)
+		}
+		return true
+	})
+	return regions
+}
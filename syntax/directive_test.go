@@ -0,0 +1,61 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestParseDirective(t *testing.T) {
+	t.Parallel()
+	tests := [...]struct {
+		text string
+		want *Directive
+	}{
+		{" shfmt: off", &Directive{Tool: "shfmt", Name: "off", Args: []string{}}},
+		{"shfmt:ignore-next-line", &Directive{Tool: "shfmt", Name: "ignore-next-line", Args: []string{}}},
+		{" my-tool: set a b ", &Directive{Tool: "my-tool", Name: "set", Args: []string{"a", "b"}}},
+		{" TODO: fix this", nil},
+		{" shfmt:", nil},
+		{" shfmt off", nil},
+		{" 2fa: on", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			c := Comment{Text: test.text}
+			got, ok := ParseDirective(c)
+			if test.want == nil {
+				qt.Assert(t, qt.IsFalse(ok))
+				return
+			}
+			qt.Assert(t, qt.IsTrue(ok))
+			qt.Assert(t, qt.Equals(got.Comment, c))
+			qt.Assert(t, qt.Equals(got.Tool, test.want.Tool))
+			qt.Assert(t, qt.Equals(got.Name, test.want.Name))
+			qt.Assert(t, qt.DeepEquals(got.Args, test.want.Args))
+		})
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	t.Parallel()
+	src := "# shfmt: off\n# other: x y\nfoo # lint: disable\n# TODO: bar\n# last: one\n"
+	f, err := NewParser(KeepComments(true)).Parse(strings.NewReader(src), "")
+	qt.Assert(t, qt.IsNil(err))
+
+	names := func(dirs []Directive) []string {
+		var list []string
+		for _, d := range dirs {
+			list = append(list, d.Tool+":"+d.Name)
+		}
+		return list
+	}
+	qt.Assert(t, qt.DeepEquals(names(Directives(f.Stmts[0])), []string{"shfmt:off", "other:x", "lint:disable"}))
+	qt.Assert(t, qt.DeepEquals(names(Directives(f)), []string{"last:one"}))
+	qt.Assert(t, qt.IsNil(Directives(f.Stmts[0].Cmd)))
+}
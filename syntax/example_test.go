@@ -161,6 +161,7 @@ func ExampleDebugPrint() {
 	// Output:
 	// *syntax.File {
 	// .  Name: ""
+	// .  Shebang: ""
 	// .  Stmts: []*syntax.Stmt (len = 1) {
 	// .  .  0: *syntax.Stmt {
 	// .  .  .  Comments: []syntax.Comment (len = 0) {}
@@ -0,0 +1,213 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"slices"
+	"strings"
+)
+
+// Feature is the use of a shell language feature which is not part of POSIX
+// Shell, as found by [Features].
+type Feature struct {
+	Pos Pos
+
+	// Name describes the feature, such as "arrays" or "process substitutions",
+	// using the same wording as the parser's [LangError].
+	Name string
+
+	// Langs are the language variants which support the feature.
+	// [LangBats] is only listed for its own features, as it supports all
+	// Bash features too.
+	Langs []LangVariant
+}
+
+// SupportedBy reports whether a language variant supports the feature.
+func (f Feature) SupportedBy(lang LangVariant) bool {
+	if lang == LangBats {
+		lang = LangBash
+	}
+	return slices.Contains(f.Langs, lang)
+}
+
+func (f Feature) String() string {
+	return LangError{Pos: f.Pos, Feature: f.Name, Langs: f.Langs}.Error()
+}
+
+// Features reports the uses of language features in a syntax tree which
+// are not part of POSIX Shell, sorted by position. This is useful to check
+// that a script parsed as Bash is portable, or to find which language
+// variants a script could be parsed as.
+//
+// Note that only the syntax is checked. For example, the use of builtins
+// such as "shopt" or of special variables such as $RANDOM is not reported.
+func Features(node Node) []Feature {
+	var features []Feature
+	add := func(pos Pos, name string, langs ...LangVariant) {
+		features = append(features, Feature{Pos: pos, Name: name, Langs: slices.Clone(langs)})
+	}
+	bashMksh := []LangVariant{LangBash, LangMirBSDKorn}
+	Walk(node, func(node Node) bool {
+		switch node := node.(type) {
+		case *CallExpr:
+			braceFeatures(node.Args, add)
+		case *WordIter:
+			braceFeatures(node.Items, add)
+		case *ArrayElem:
+			if node.Value != nil {
+				braceFeatures([]*Word{node.Value}, add)
+			}
+		case *SglQuoted:
+			if node.Dollar {
+				add(node.Pos(), "$'' strings", bashMksh...)
+			}
+		case *DblQuoted:
+			if node.Dollar {
+				add(node.Pos(), `$"" strings`, bashMksh...)
+			}
+		case *Assign:
+			switch {
+			case node.Array != nil, node.Index != nil:
+				add(node.Pos(), "arrays", bashMksh...)
+			case node.Append:
+				add(node.Pos(), "append assignments", bashMksh...)
+			}
+		case *ParamExp:
+			paramExpFeatures(node, add)
+		case *CmdSubst:
+			switch {
+			case node.TempFile:
+				add(node.Pos(), "${ stmts;} substitutions", LangMirBSDKorn)
+			case node.ReplyVar:
+				add(node.Pos(), "${|stmts;} substitutions", LangMirBSDKorn)
+			}
+		case *ArithmExp:
+			switch {
+			case node.Bracket:
+				add(node.Pos(), "$[expr] expansions", LangBash)
+			case node.Unsigned:
+				add(node.Pos(), "unsigned expressions", LangMirBSDKorn)
+			}
+		case *ProcSubst:
+			add(node.Pos(), "process substitutions", LangBash)
+		case *ExtGlob:
+			add(node.Pos(), "extended globs", bashMksh...)
+		case *Redirect:
+			switch node.Op {
+			case WordHdoc:
+				add(node.OpPos, "herestrings", bashMksh...)
+			case RdrAll, AppAll:
+				add(node.OpPos, "&> redirects", bashMksh...)
+			}
+			if node.N != nil && strings.HasPrefix(node.N.Value, "{") {
+				add(node.N.Pos(), "{varname} redirects", LangBash)
+			}
+		case *BinaryCmd:
+			if node.Op == PipeAll {
+				add(node.OpPos, "|& pipes", LangBash)
+			}
+		case *CaseClause:
+			if node.Braces {
+				add(node.Pos(), "case clauses with braces", LangMirBSDKorn)
+			}
+		case *CaseItem:
+			switch node.Op {
+			case Fallthrough:
+				add(node.OpPos, ";& case terminators", bashMksh...)
+			case Resume:
+				add(node.OpPos, ";;& case terminators", LangBash)
+			case ResumeKorn:
+				add(node.OpPos, ";| case terminators", LangMirBSDKorn)
+			}
+		case *ForClause:
+			switch {
+			case node.Select:
+				add(node.Pos(), "select loops", bashMksh...)
+			case node.Braces:
+				add(node.Pos(), "for loops with braces", bashMksh...)
+			}
+		case *CStyleLoop:
+			add(node.Pos(), "c-style fors", LangBash)
+		case *ArithmCmd:
+			add(node.Pos(), "arithmetic commands", bashMksh...)
+		case *TestClause:
+			add(node.Pos(), "test clauses", bashMksh...)
+		case *BinaryTest:
+			if node.Op == TsReMatch {
+				add(node.OpPos, "regex tests", LangBash)
+			}
+		case *LetClause:
+			add(node.Pos(), "let clauses", bashMksh...)
+		case *DeclClause:
+			switch node.Variant.Value {
+			case "declare":
+				add(node.Pos(), "declare clauses", LangBash)
+			case "local", "typeset", "nameref":
+				add(node.Pos(), node.Variant.Value+" clauses", bashMksh...)
+			}
+		case *FuncDecl:
+			if node.RsrvWord {
+				add(node.Pos(), `"function" declarations`, bashMksh...)
+			}
+		case *CoprocClause:
+			add(node.Pos(), "coprocesses", LangBash)
+		case *TestDecl:
+			add(node.Pos(), "@test declarations", LangBats)
+		}
+		return true
+	})
+	slices.SortStableFunc(features, func(a, b Feature) int {
+		return int(a.Pos.Offset()) - int(b.Pos.Offset())
+	})
+	return features
+}
+
+// braceFeatures reports brace expansions in words which are expanded as fields,
+// as brace expansion does not happen elsewhere, such as in assignments.
+func braceFeatures(words []*Word, add func(Pos, string, ...LangVariant)) {
+	for _, word := range words {
+		word2 := *word // SplitBraces replaces the Parts slice
+		if SplitBraces(&word2) {
+			add(word.Pos(), "brace expansions", LangBash, LangMirBSDKorn)
+		}
+	}
+}
+
+func paramExpFeatures(pe *ParamExp, add func(Pos, string, ...LangVariant)) {
+	bashMksh := []LangVariant{LangBash, LangMirBSDKorn}
+	switch {
+	case pe.Index != nil:
+		add(pe.Pos(), "arrays", bashMksh...)
+	case pe.Names != 0:
+		add(pe.Pos(), "name prefix expansions", LangBash)
+	case pe.Excl:
+		add(pe.Pos(), "indirect expansions", bashMksh...)
+	}
+	if pe.Width {
+		add(pe.Pos(), "width expansions", LangMirBSDKorn)
+	}
+	if pe.Slice != nil {
+		add(pe.Pos(), "slicing", bashMksh...)
+	}
+	if pe.Repl != nil {
+		add(pe.Pos(), "search and replace", bashMksh...)
+	}
+	if pe.Exp == nil {
+		return
+	}
+	switch pe.Exp.Op {
+	case UpperFirst, UpperAll, LowerFirst, LowerAll:
+		add(pe.Pos(), "case expansions", LangBash)
+	case OtherParamOps:
+		name := "${var@" + pe.Exp.Word.Lit() + "} expansions"
+		switch pe.Exp.Word.Lit() {
+		case "Q":
+			add(pe.Pos(), name, bashMksh...)
+		case "#":
+			add(pe.Pos(), name, LangMirBSDKorn)
+		default:
+			add(pe.Pos(), name, LangBash)
+		}
+	}
+}
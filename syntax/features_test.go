@@ -0,0 +1,112 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var featuresTests = []struct {
+	src  string
+	lang LangVariant
+	want []string
+}{
+	{src: "echo foo | bar; x=$(y) || z `w`", want: nil},
+	{src: "case $x in a) b ;; esac; f() { :; }; x=$((1 + 2))", want: nil},
+	{src: "a=(1 2); b[1]=x; c+=y", want: []string{
+		"1:1: arrays are a bash/mksh feature",
+		"1:10: arrays are a bash/mksh feature",
+		"1:18: append assignments are a bash/mksh feature",
+	}},
+	{src: `echo ${a[1]} ${!b} ${!c*} ${d:1} ${e/x/y} ${f^^} ${g@Q} ${h@U}`, want: []string{
+		"1:6: arrays are a bash/mksh feature",
+		"1:14: indirect expansions are a bash/mksh feature",
+		"1:20: name prefix expansions are a bash feature",
+		"1:27: slicing is a bash/mksh feature",
+		"1:34: search and replace is a bash/mksh feature",
+		"1:43: case expansions are a bash feature",
+		"1:50: ${var@Q} expansions are a bash/mksh feature",
+		"1:57: ${var@U} expansions are a bash feature",
+	}},
+	{src: `echo $'a' $"b" <(c) {x,y} @(z)`, want: []string{
+		"1:6: $'' strings are a bash/mksh feature",
+		`1:11: $"" strings are a bash/mksh feature`,
+		"1:16: process substitutions are a bash feature",
+		"1:21: brace expansions are a bash/mksh feature",
+		"1:27: extended globs are a bash/mksh feature",
+	}},
+	// Brace expansion does not happen in assignments.
+	{src: "x={a,b}; for i in {1..3}; do :; done", want: []string{
+		"1:19: brace expansions are a bash/mksh feature",
+	}},
+	{src: "a <<<x &>y {fd}>z |& b", want: []string{
+		"1:3: herestrings are a bash/mksh feature",
+		"1:8: &> redirects are a bash/mksh feature",
+		"1:12: {varname} redirects are a bash feature",
+		"1:19: |& pipes are a bash feature",
+	}},
+	{src: "case x in a) ;& b) ;;& esac", want: []string{
+		"1:14: ;& case terminators are a bash/mksh feature",
+		"1:20: ;;& case terminators are a bash feature",
+	}},
+	{src: "[[ a =~ b ]]; ((x++)); let y=1; for ((;;)); do :; done; select z in a; do :; done", want: []string{
+		"1:1: test clauses are a bash/mksh feature",
+		"1:6: regex tests are a bash feature",
+		"1:15: arithmetic commands are a bash/mksh feature",
+		"1:24: let clauses are a bash/mksh feature",
+		"1:37: c-style fors are a bash feature",
+		"1:57: select loops are a bash/mksh feature",
+	}},
+	{src: "function f { declare a; local b; export c; }; coproc d", want: []string{
+		`1:1: "function" declarations are a bash/mksh feature`,
+		"1:14: declare clauses are a bash feature",
+		"1:25: local clauses are a bash/mksh feature",
+		"1:47: coprocesses are a bash feature",
+	}},
+	{src: "echo ${ a;} ${|b;} $((# 1)) ${%c}", lang: LangMirBSDKorn, want: []string{
+		"1:6: ${ stmts;} substitutions are a mksh feature",
+		"1:13: ${|stmts;} substitutions are a mksh feature",
+		"1:20: unsigned expressions are a mksh feature",
+		"1:29: width expansions are a mksh feature",
+	}},
+	{src: "@test 'foo' { :; }", lang: LangBats, want: []string{
+		"1:1: @test declarations are a bats feature",
+	}},
+}
+
+func TestFeatures(t *testing.T) {
+	t.Parallel()
+	for _, tc := range featuresTests {
+		t.Run("", func(t *testing.T) {
+			f, err := NewParser(Variant(tc.lang)).Parse(strings.NewReader(tc.src), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, feature := range Features(f) {
+				got = append(got, feature.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("want:\n%s\ngot:\n%s", strings.Join(tc.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestFeatureSupportedBy(t *testing.T) {
+	t.Parallel()
+	f := Feature{Name: "arrays", Langs: []LangVariant{LangBash, LangMirBSDKorn}}
+	for lang, want := range map[LangVariant]bool{
+		LangBash:       true,
+		LangBats:       true,
+		LangMirBSDKorn: true,
+		LangPOSIX:      false,
+	} {
+		if got := f.SupportedBy(lang); got != want {
+			t.Errorf("SupportedBy(%v) = %v, want %v", lang, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+// FlowKind describes where a value flows into, as reported by [DataFlows].
+type FlowKind int
+
+const (
+	// FlowCommand is the first word of a simple command, such as "$x" in
+	// "$x arg", which decides what command runs.
+	FlowCommand FlowKind = iota
+	// FlowArg is any other word of a simple command, such as "$x" in "cmd $x".
+	FlowArg
+	// FlowRedirect is the word of a redirection, such as "$x" in "cmd >$x".
+	FlowRedirect
+)
+
+func (k FlowKind) String() string {
+	switch k {
+	case FlowCommand:
+		return "command"
+	case FlowArg:
+		return "argument"
+	case FlowRedirect:
+		return "redirect"
+	}
+	return "unknown"
+}
+
+// Flow is a place where the value of a tainted variable may end up,
+// as reported by [DataFlows].
+type Flow struct {
+	// Param is the expansion of a tainted variable,
+	// whose name is Param.Param.Value.
+	Param *ParamExp
+
+	// Sink is the word which Param is part of,
+	// such as an argument to a command.
+	Sink *Word
+	Kind FlowKind
+
+	// Quoted is true if Param is inside double quotes within Sink,
+	// so that its value is not subject to field splitting nor globbing.
+	Quoted bool
+}
+
+// DataFlows reports where the values of the given variables may end up within
+// a node, such as "$1" or "$QUERY_STRING" being used as a command argument.
+// This is useful to build security linters, which may check that untrusted
+// input is always quoted, or never used as a command name.
+//
+// The analysis is conservative: any variable assigned a value which uses
+// a tainted variable is tainted too, regardless of the order of statements or
+// the control flow, and any expansion found anywhere within a word makes the
+// whole word tainted, including inside command substitutions. Function calls
+// are not followed, as functions are analyzed like any other statements.
+//
+// Note that special parameters are not linked; for example, to track all the
+// positional parameters, list "@", "*", and each of "1", "2", and so on.
+func DataFlows(node Node, vars ...string) []Flow {
+	tainted := make(map[string]bool, len(vars))
+	for _, name := range vars {
+		tainted[name] = true
+	}
+	usesTainted := func(node Node) bool {
+		found := false
+		Walk(node, func(node Node) bool {
+			if pe, ok := node.(*ParamExp); ok && tainted[pe.Param.Value] {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+	// Find all the variables which may hold a tainted value.
+	// The analysis does not follow the order of statements, so we repeat it
+	// until no new variables are found, as in "b=$a; a=$1".
+	for changed := true; changed; {
+		changed = false
+		taint := func(name *Lit) {
+			if name != nil && !tainted[name.Value] {
+				tainted[name.Value] = true
+				changed = true
+			}
+		}
+		Walk(node, func(node Node) bool {
+			switch node := node.(type) {
+			case *Assign:
+				if node.Name != nil && !tainted[node.Name.Value] && usesTainted(node) {
+					taint(node.Name)
+				}
+			case *WordIter:
+				if node.InPos.IsValid() {
+					for _, item := range node.Items {
+						if usesTainted(item) {
+							taint(node.Name)
+						}
+					}
+				} else if tainted["@"] {
+					taint(node.Name) // "for name; do" loops over "$@"
+				}
+			}
+			return true
+		})
+	}
+
+	var flows []Flow
+	addFlows := func(sink *Word, kind FlowKind) {
+		for _, part := range sink.Parts {
+			_, quoted := part.(*DblQuoted)
+			Walk(part, func(node Node) bool {
+				if pe, ok := node.(*ParamExp); ok && tainted[pe.Param.Value] {
+					flows = append(flows, Flow{Param: pe, Sink: sink, Kind: kind, Quoted: quoted})
+				}
+				return true
+			})
+		}
+	}
+	Walk(node, func(node Node) bool {
+		switch node := node.(type) {
+		case *CallExpr:
+			for i, arg := range node.Args {
+				kind := FlowArg
+				if i == 0 {
+					kind = FlowCommand
+				}
+				addFlows(arg, kind)
+			}
+		case *Redirect:
+			if node.Word != nil && node.Hdoc == nil {
+				addFlows(node.Word, FlowRedirect)
+			}
+		}
+		return true
+	})
+	return flows
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestDataFlows(t *testing.T) {
+	t.Parallel()
+	tests := [...]struct {
+		src  string
+		vars []string
+		want []string
+	}{
+		{"echo $1 $2 $foo", []string{"1"}, []string{"argument $1 in $1"}},
+		{`echo "$1" x"$1"y`, []string{"1"}, []string{
+			`quoted argument $1 in "$1"`,
+			`quoted argument $1 in x"$1"y`,
+		}},
+		{"$Q foo; cat <$Q; echo >${Q}.log", []string{"Q"}, []string{
+			"command $Q in $Q",
+			"redirect $Q in $Q",
+			"redirect ${Q} in ${Q}.log",
+		}},
+		{"echo $1 $@", []string{"@"}, []string{"argument $@ in $@"}},
+		{"a=$1; b=${a%/}; echo $b", []string{"1"}, []string{"argument $b in $b"}},
+		{"echo $b; b=$a; a=x$1", []string{"1"}, []string{"argument $b in $b"}},
+		{"f() { local x=$1; rm -rf $x; }", []string{"1"}, []string{"argument $x in $x"}},
+		{"arr=(a $1); echo ${arr[0]}", []string{"1"}, []string{"argument ${arr[0]} in ${arr[0]}"}},
+		{"for x in a $1; do echo $x; done", []string{"1"}, []string{"argument $x in $x"}},
+		{"for x; do echo $x; done", []string{"@"}, []string{"argument $x in $x"}},
+		{"for x in a b; do echo $x; done", []string{"@"}, nil},
+		{`eval "$(echo $1)"`, []string{"1"}, []string{
+			`quoted argument $1 in "$(echo $1)"`,
+			"argument $1 in $1",
+		}},
+		{"echo ${x:-$1}", []string{"1"}, []string{"argument $1 in ${x:-$1}"}},
+		{"cat <<EOF\n$1\nEOF", []string{"1"}, nil},
+		{"x=$1; echo y", []string{"1"}, nil},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			f, err := NewParser().Parse(strings.NewReader(test.src), "")
+			qt.Assert(t, qt.IsNil(err))
+			var got []string
+			for _, flow := range DataFlows(f, test.vars...) {
+				quoted := ""
+				if flow.Quoted {
+					quoted = "quoted "
+				}
+				got = append(got, fmt.Sprintf("%s%s %s in %s", quoted, flow.Kind,
+					test.src[flow.Param.Pos().Offset():flow.Param.End().Offset()],
+					test.src[flow.Sink.Pos().Offset():flow.Sink.End().Offset()]))
+			}
+			qt.Assert(t, qt.DeepEquals(got, test.want))
+		})
+	}
+}
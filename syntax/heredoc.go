@@ -0,0 +1,95 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import "strings"
+
+// Heredoc is a here-document found in a program, as returned by
+// [File.Heredocs].
+type Heredoc struct {
+	// Redirect is the "<<" or "<<-" redirect which introduced the heredoc.
+	Redirect *Redirect
+
+	// Delim is the delimiter with any quoting removed,
+	// such as "EOF" for both "<<EOF" and "<<'EOF'".
+	Delim string
+
+	// Quoted is true if any part of the delimiter was quoted,
+	// in which case the body is not subject to any expansions.
+	Quoted bool
+
+	// Body is the heredoc body, the same as Redirect.Hdoc.
+	// It may be nil if the body is empty.
+	Body *Word
+}
+
+// Heredocs returns all the heredocs in a file in the order in which their
+// redirects appear, including those nested in other nodes such as functions
+// or command substitutions.
+func (f *File) Heredocs() []Heredoc {
+	var hdocs []Heredoc
+	Walk(f, func(node Node) bool {
+		rd, ok := node.(*Redirect)
+		if !ok || (rd.Op != Hdoc && rd.Op != DashHdoc) {
+			return true
+		}
+		delim, quoted := unquotedWordBytes(rd.Word)
+		hdocs = append(hdocs, Heredoc{
+			Redirect: rd,
+			Delim:    string(delim),
+			Quoted:   quoted,
+			Body:     rd.Hdoc,
+		})
+		return true
+	})
+	return hdocs
+}
+
+// Text returns the literal text of the heredoc body as a shell would feed it
+// to a command, reporting whether the body is fully literal.
+//
+// For quoted heredocs, the body is used verbatim. Otherwise, the body must not
+// contain any expansions, and backslash escapes for "\", "$", "`", and
+// newlines are removed. With "<<-", leading tab characters are removed from
+// each line.
+func (h Heredoc) Text() (string, bool) {
+	if h.Body == nil {
+		return "", true
+	}
+	var sb strings.Builder
+	for _, wp := range h.Body.Parts {
+		lit, ok := wp.(*Lit)
+		if !ok {
+			return "", false
+		}
+		sb.WriteString(lit.Value)
+	}
+	text := sb.String()
+	if h.Redirect.Op == DashHdoc {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimLeft(line, "\t")
+		}
+		text = strings.Join(lines, "\n")
+	}
+	if h.Quoted || !strings.Contains(text, `\`) {
+		return text, true
+	}
+	sb.Reset()
+	for i := 0; i < len(text); i++ {
+		b := text[i]
+		if b == '\\' && i+1 < len(text) {
+			switch text[i+1] {
+			case '\n':
+				i++
+				continue
+			case '\\', '$', '`':
+				i++
+				b = text[i]
+			}
+		}
+		sb.WriteByte(b)
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestHeredocs(t *testing.T) {
+	t.Parallel()
+	type hdoc struct {
+		Delim   string
+		Quoted  bool
+		Text    string
+		Literal bool
+	}
+	tests := [...]struct {
+		src  string
+		want []hdoc
+	}{
+		{"foo", nil},
+		{"cat <<EOF\nEOF", []hdoc{{"EOF", false, "", true}}},
+		{"cat <<EOF\nfoo\nbar\nEOF", []hdoc{{"EOF", false, "foo\nbar\n", true}}},
+		{"cat <<EOF\nfoo $bar\nEOF", []hdoc{{"EOF", false, "", false}}},
+		{"cat <<EOF\na \\$b \\\\ \\` \\c\\\nd\nEOF", []hdoc{{"EOF", false, "a $b \\ ` \\cd\n", true}}},
+		{"cat <<'EOF'\nfoo $bar \\$\nEOF", []hdoc{{"EOF", true, "foo $bar \\$\n", true}}},
+		{"cat <<\"E\"OF\n$bar\nEOF", []hdoc{{"EOF", true, "$bar\n", true}}},
+		{"cat <<\\EOF\n$bar\nEOF", []hdoc{{"EOF", true, "$bar\n", true}}},
+		{"cat <<-EOF\n\tfoo\n\t\tbar\n\tEOF", []hdoc{{"EOF", false, "foo\nbar\n", true}}},
+		{"cat <<-'EOF'\n\t$foo\n\tEOF", []hdoc{{"EOF", true, "$foo\n", true}}},
+		{
+			"cat <<A <<'B'\na\nA\nb\nB\nf() { x=$(cat <<C\nc\nC\n); }",
+			[]hdoc{
+				{"A", false, "a\n", true},
+				{"B", true, "b\n", true},
+				{"C", false, "c\n", true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			f, err := NewParser().Parse(strings.NewReader(test.src), "")
+			qt.Assert(t, qt.IsNil(err))
+			var got []hdoc
+			for _, h := range f.Heredocs() {
+				qt.Assert(t, qt.Equals(h.Body, h.Redirect.Hdoc))
+				text, ok := h.Text()
+				got = append(got, hdoc{h.Delim, h.Quoted, text, ok})
+			}
+			qt.Assert(t, qt.DeepEquals(got, test.want))
+		})
+	}
+}
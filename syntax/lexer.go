@@ -304,7 +304,9 @@ skipSpace:
 				}
 				r = p.rune()
 			}
-			if p.keepComments {
+			if p.pos.Offset() == 0 && p.f != nil && len(p.litBs) > 0 && p.litBs[0] == '!' {
+				p.f.Shebang = "#" + p.endLit()
+			} else if p.keepComments {
 				*p.curComs = append(*p.curComs, Comment{
 					Hash: p.pos,
 					Text: p.endLit(),
@@ -24,6 +24,11 @@ type Node interface {
 type File struct {
 	Name string
 
+	// Shebang is the "#!" line at the very start of the file, if any,
+	// such as "#!/usr/bin/env bash". It is kept even without [KeepComments],
+	// and it is not part of any statement's comments.
+	Shebang string
+
 	Stmts []*Stmt
 	Last  []Comment
 }
@@ -0,0 +1,132 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NumberFormat describes how an integer constant is written in an arithmetic
+// expression, such as "255", "0377", "0xff", or "16#FF".
+//
+// The zero value is equivalent to decimal.
+type NumberFormat struct {
+	// Base is the numeric base, from 2 to 64. Zero means base 10.
+	Base int
+
+	// Explicit means that the base is written as a "base#" prefix.
+	// It is implied for bases other than 8, 10, and 16,
+	// which are otherwise written as "0377", "255", and "0xff".
+	Explicit bool
+
+	// Upper means that digits above 9 are written as upper-case letters.
+	// It has no effect for bases above 36, where letter case matters.
+	Upper bool
+}
+
+const numberDigits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ@_"
+
+// ParseNumber parses an integer constant as written in an arithmetic
+// expression, returning its value along with the format it was written in.
+// Like in Bash, values which do not fit in 64 bits wrap around.
+//
+// An error is returned if s is not a valid integer constant;
+// note that arithmetic expressions may also contain variable names.
+func ParseNumber(s string) (int64, NumberFormat, error) {
+	var format NumberFormat
+	digits := s
+	switch {
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0X"):
+		format.Base = 16
+		digits = s[2:]
+	case len(s) > 1 && s[0] == '0':
+		format.Base = 8
+		digits = s[1:]
+	default:
+		prefix, rest, ok := strings.Cut(s, "#")
+		if !ok {
+			if s != "" && (s[0] < '0' || s[0] > '9') {
+				return 0, format, fmt.Errorf("%s: invalid integer constant", s)
+			}
+			format.Base = 10
+			break
+		}
+		base, err := strconv.Atoi(prefix)
+		if err != nil || base < 2 || base > 64 {
+			return 0, format, fmt.Errorf("%s: invalid arithmetic base", s)
+		}
+		if rest == "" {
+			return 0, format, fmt.Errorf("%s: invalid integer constant", s)
+		}
+		format.Base = base
+		format.Explicit = true
+		digits = rest
+	}
+	if digits == "" && format.Base != 16 {
+		return 0, format, fmt.Errorf("%s: invalid integer constant", s)
+	}
+	var n uint64
+	for _, r := range digits {
+		d := strings.IndexRune(numberDigits, r)
+		if format.Base <= 36 && 'A' <= r && r <= 'Z' {
+			d = int(r-'A') + 10
+			format.Upper = true
+		}
+		if d < 0 {
+			return 0, format, fmt.Errorf("%s: invalid integer constant", s)
+		}
+		if d >= format.Base {
+			return 0, format, fmt.Errorf("%s: value too great for base", s)
+		}
+		n = n*uint64(format.Base) + uint64(d)
+	}
+	return int64(n), format, nil
+}
+
+// FormatNumber returns the source for an integer constant in the given format,
+// to be used as a literal in an arithmetic expression.
+// Negative values are written with a leading minus sign,
+// which shells parse as a unary operator.
+//
+// FormatNumber panics if the base is not between 2 and 64.
+func FormatNumber(n int64, format NumberFormat) string {
+	base := format.Base
+	if base == 0 {
+		base = 10
+	}
+	if base < 2 || base > 64 {
+		panic(fmt.Sprintf("invalid arithmetic base: %d", base))
+	}
+	var prefix string
+	switch {
+	case format.Explicit, base != 8 && base != 10 && base != 16:
+		prefix = strconv.Itoa(base) + "#"
+	case base == 8 && n != 0:
+		prefix = "0"
+	case base == 16:
+		prefix = "0x"
+	}
+	u := uint64(n)
+	if n < 0 {
+		prefix = "-" + prefix
+		u = -u
+	}
+	var buf [64]byte
+	i := len(buf)
+	for {
+		i--
+		c := numberDigits[u%uint64(base)]
+		if format.Upper && base <= 36 && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		buf[i] = c
+		u /= uint64(base)
+		if u == 0 {
+			break
+		}
+	}
+	return prefix + string(buf[i:])
+}
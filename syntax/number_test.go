@@ -0,0 +1,100 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestParseNumber(t *testing.T) {
+	t.Parallel()
+	tests := [...]struct {
+		in      string
+		want    int64
+		format  NumberFormat
+		wantErr string
+	}{
+		{in: "0", want: 0, format: NumberFormat{Base: 10}},
+		{in: "123", want: 123, format: NumberFormat{Base: 10}},
+		{in: "010", want: 8, format: NumberFormat{Base: 8}},
+		{in: "0x1f", want: 31, format: NumberFormat{Base: 16}},
+		{in: "0X1F", want: 31, format: NumberFormat{Base: 16, Upper: true}},
+		{in: "0x", want: 0, format: NumberFormat{Base: 16}},
+		{in: "10#08", want: 8, format: NumberFormat{Base: 10, Explicit: true}},
+		{in: "2#101", want: 5, format: NumberFormat{Base: 2, Explicit: true}},
+		{in: "36#Z", want: 35, format: NumberFormat{Base: 36, Explicit: true, Upper: true}},
+		{in: "37#z", want: 35, format: NumberFormat{Base: 37, Explicit: true}},
+		{in: "64#@_", want: 62*64 + 63, format: NumberFormat{Base: 64, Explicit: true}},
+		{in: "9223372036854775808", want: math.MinInt64, format: NumberFormat{Base: 10}},
+
+		{in: "08", wantErr: "08: value too great for base"},
+		{in: "37#Z", wantErr: "37#Z: value too great for base"},
+		{in: "1#1", wantErr: "1#1: invalid arithmetic base"},
+		{in: "65#1", wantErr: "65#1: invalid arithmetic base"},
+		{in: "16#", wantErr: "16#: invalid integer constant"},
+		{in: "", wantErr: ": invalid integer constant"},
+		{in: "foo", wantErr: "foo: invalid integer constant"},
+		{in: "1f", wantErr: "1f: value too great for base"},
+		{in: "-1", wantErr: "-1: invalid integer constant"},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got, format, err := ParseNumber(test.in)
+			if test.wantErr != "" {
+				qt.Assert(t, qt.ErrorMatches(err, test.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(got, test.want))
+			qt.Assert(t, qt.Equals(format, test.format))
+		})
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	t.Parallel()
+	tests := [...]struct {
+		n      int64
+		format NumberFormat
+		want   string
+	}{
+		{0, NumberFormat{}, "0"},
+		{255, NumberFormat{}, "255"},
+		{-255, NumberFormat{Base: 10}, "-255"},
+		{255, NumberFormat{Base: 8}, "0377"},
+		{0, NumberFormat{Base: 8}, "0"},
+		{255, NumberFormat{Base: 16}, "0xff"},
+		{255, NumberFormat{Base: 16, Upper: true}, "0xFF"},
+		{-255, NumberFormat{Base: 16}, "-0xff"},
+		{255, NumberFormat{Base: 16, Explicit: true}, "16#ff"},
+		{255, NumberFormat{Base: 10, Explicit: true}, "10#255"},
+		{5, NumberFormat{Base: 2}, "2#101"},
+		{35, NumberFormat{Base: 36, Upper: true}, "36#Z"},
+		{61, NumberFormat{Base: 64, Upper: true}, "64#Z"},
+		{62*64 + 63, NumberFormat{Base: 64}, "64#@_"},
+		{math.MinInt64, NumberFormat{Base: 16}, "-0x8000000000000000"},
+	}
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got := FormatNumber(test.n, test.format)
+			qt.Assert(t, qt.Equals(got, test.want))
+
+			if test.n < 0 {
+				return
+			}
+			n, format, err := ParseNumber(got)
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(n, test.n))
+			if test.n != 0 && test.format.Base != 0 {
+				qt.Assert(t, qt.Equals(format.Base, test.format.Base))
+			}
+		})
+	}
+	qt.Assert(t, qt.PanicMatches(func() {
+		FormatNumber(1, NumberFormat{Base: 65})
+	}, "invalid arithmetic base: 65"))
+}
@@ -11,8 +11,6 @@ import (
 	"strings"
 	"text/tabwriter"
 	"unicode"
-
-	"mvdan.cc/sh/v3/fileutil"
 )
 
 // PrinterOption is a function which can be passed to NewPrinter
@@ -99,6 +97,46 @@ func FunctionNextLine(enabled bool) PrinterOption {
 	return func(p *Printer) { p.funcNextLine = enabled }
 }
 
+// QuoteStyle describes how the printer quotes words when it has a choice
+// between equivalent forms, such as 'foo' versus "foo".
+type QuoteStyle int
+
+const (
+	// QuoteKeep leaves quotes as they were in the original source.
+	QuoteKeep QuoteStyle = iota
+	// QuoteNone prefers no quotes at all, like EOF.
+	QuoteNone
+	// QuoteSingle prefers single quotes, like 'EOF'.
+	QuoteSingle
+	// QuoteDouble prefers double quotes, like "EOF".
+	QuoteDouble
+)
+
+// UpperHeredocDelims will print heredoc delimiters in upper case,
+// such as <<EOF instead of <<eof. A delimiter is left untouched if it is
+// not a simple word, or if the upper case version would appear as a line
+// in the heredoc's body.
+func UpperHeredocDelims(enabled bool) PrinterOption {
+	return func(p *Printer) { p.upperHdocDelims = enabled }
+}
+
+// QuoteHeredocDelims will print heredoc delimiters with a consistent quote
+// style, such as <<'EOF' or <<EOF. Since quoting a delimiter disables
+// expansions in the heredoc's body, a delimiter is only changed when its
+// body would have the same meaning either way.
+func QuoteHeredocDelims(style QuoteStyle) PrinterOption {
+	return func(p *Printer) { p.hdocDelimQuote = style }
+}
+
+// QuoteLiterals will print simple quoted literals with a consistent quote
+// style, such as 'foo' or "foo". A literal is only changed when it has the
+// same meaning with either kind of quotes, so "$foo" and 'don'"'"'t' are
+// left untouched. Removing quotes is not supported, so [QuoteNone] is
+// equivalent to [QuoteKeep].
+func QuoteLiterals(style QuoteStyle) PrinterOption {
+	return func(p *Printer) { p.litQuote = style }
+}
+
 // NewPrinter allocates a new Printer and applies any number of options.
 func NewPrinter(opts ...PrinterOption) *Printer {
 	p := &Printer{
@@ -141,6 +179,7 @@ func (p *Printer) Print(w io.Writer, node Node) error {
 	p.bufWriter.Reset(w)
 	switch node := node.(type) {
 	case *File:
+		p.shebang(node.Shebang)
 		p.stmtList(node.Stmts, node.Last)
 		p.newline(Pos{})
 	case *Stmt:
@@ -234,6 +273,10 @@ type Printer struct {
 	singleLine     bool
 	funcNextLine   bool
 
+	upperHdocDelims bool
+	hdocDelimQuote  QuoteStyle
+	litQuote        QuoteStyle
+
 	wantSpace wantSpaceState // whether space is required or has been written
 
 	wantNewline bool // newline is wanted for pretty-printing; ignored by singleLine; ignored by singleLine
@@ -267,6 +310,9 @@ type Printer struct {
 
 	// used when printing <<- heredocs with tab indentation
 	tabsPrinter *Printer
+
+	// regions are printed verbatim; see [Printer.PrintWithRegions].
+	regions []Region
 }
 
 func (p *Printer) reset() {
@@ -284,6 +330,36 @@ func (p *Printer) reset() {
 	p.pendingHdocs = p.pendingHdocs[:0]
 }
 
+// Region is a range of a program's source to be printed verbatim by
+// [Printer.PrintWithRegions].
+type Region struct {
+	// Start and End are the byte offsets of the region in the source.
+	Start, End uint
+
+	// Text is the source between the Start and End offsets.
+	Text []byte
+}
+
+// PrintWithRegions is like [Printer.Print], but any statements which lie
+// entirely within one of the regions are copied from its text as they are,
+// along with their indentation and comments, instead of being formatted.
+// The rest of the program is formatted as usual.
+//
+// Regions whose text length does not match their offsets are ignored.
+func (p *Printer) PrintWithRegions(w io.Writer, node Node, regions []Region) error {
+	p.regions = regions
+	defer func() { p.regions = nil }()
+	return p.Print(w, node)
+}
+
+// PrintSource is like [Printer.Print], but it honors any "shfmt" directives
+// in the program's comments, as described in [Directive]. Statements which
+// must not be formatted are copied verbatim from src, which must be the source
+// that node was parsed from, with comments kept.
+func (p *Printer) PrintSource(w io.Writer, node Node, src []byte) error {
+	return p.PrintWithRegions(w, node, directiveRegions(node, src))
+}
+
 func (p *Printer) spaces(n uint) {
 	for i := uint(0); i < n; i++ {
 		p.WriteByte(' ')
@@ -489,6 +565,10 @@ func (p *Printer) flushHeredocs() {
 					minify:         p.minify,
 					funcNextLine:   p.funcNextLine,
 
+					upperHdocDelims: p.upperHdocDelims,
+					hdocDelimQuote:  p.hdocDelimQuote,
+					litQuote:        p.litQuote,
+
 					line: r.Hdoc.Pos().Line(),
 				}
 				p.tabsPrinter.wordParts(r.Hdoc.Parts, true)
@@ -497,7 +577,7 @@ func (p *Printer) flushHeredocs() {
 		} else if r.Hdoc != nil {
 			p.wordParts(r.Hdoc.Parts, true)
 		}
-		p.unquotedWord(r.Word)
+		p.unquotedWord(p.hdocDelim(r))
 		if r.Hdoc != nil {
 			// Overwrite p.line, since printing r.Word again can set
 			// p.line to the beginning of the heredoc again.
@@ -598,18 +678,26 @@ func (p *Printer) flushComments() {
 
 func (p *Printer) comments(comments ...Comment) {
 	if p.minify {
-		for _, c := range comments {
-			if fileutil.Shebang([]byte("#"+c.Text)) != "" && c.Hash.Col() == 1 && c.Hash.Line() == 1 {
-				p.WriteString(strings.TrimRightFunc("#"+c.Text, unicode.IsSpace))
-				p.WriteString("\n")
-				p.line++
-			}
-		}
 		return
 	}
 	p.pendingComments = append(p.pendingComments, comments...)
 }
 
+// shebang prints a file's "#!" line, which is kept even when minifying.
+func (p *Printer) shebang(line string) {
+	if line == "" {
+		return
+	}
+	p.WriteString(strings.TrimRightFunc(line, unicode.IsSpace))
+	p.line = 1
+	if p.minify {
+		p.WriteByte('\n')
+		return
+	}
+	p.firstLine = false
+	p.wantNewline, p.mustNewline = true, true
+}
+
 func (p *Printer) wordParts(wps []WordPart, quoted bool) {
 	// We disallow unquoted escaped newlines between word parts below.
 	// However, we want to allow a leading escaped newline for cases such as:
@@ -644,6 +732,13 @@ func (p *Printer) wordPart(wp, next WordPart) {
 	case *Lit:
 		p.writeLit(wp.Value)
 	case *SglQuoted:
+		if p.litQuote == QuoteDouble && !wp.Dollar && !strings.ContainsAny(wp.Value, "$`\\\"!") {
+			p.WriteByte('"')
+			p.writeLit(wp.Value)
+			p.WriteByte('"')
+			p.advanceLine(wp.End().Line())
+			break
+		}
 		if wp.Dollar {
 			p.WriteByte('$')
 		}
@@ -726,6 +821,15 @@ func (p *Printer) wordPart(wp, next WordPart) {
 }
 
 func (p *Printer) dblQuoted(dq *DblQuoted) {
+	if p.litQuote == QuoteSingle && !dq.Dollar {
+		if val, ok := simpleDblQuoted(dq); ok && !strings.Contains(val, "'") {
+			p.WriteByte('\'')
+			p.writeLit(val)
+			p.WriteByte('\'')
+			p.advanceLine(dq.End().Line())
+			return
+		}
+	}
 	if dq.Dollar {
 		p.WriteByte('$')
 	}
@@ -809,7 +913,8 @@ func (p *Printer) loop(loop Loop) {
 	switch loop := loop.(type) {
 	case *WordIter:
 		p.writeLit(loop.Name.Value)
-		if loop.InPos.IsValid() {
+		// Built syntax trees may lack positions.
+		if loop.InPos.IsValid() || len(loop.Items) > 0 {
 			p.spacedString(" in", Pos{})
 			p.wordJoin(loop.Items)
 		}
@@ -939,6 +1044,138 @@ func (p *Printer) unquotedWord(w *Word) {
 	}
 }
 
+// simpleDblQuoted returns the value of a double-quoted string which consists
+// of a single literal without any escapes or special characters.
+func simpleDblQuoted(dq *DblQuoted) (string, bool) {
+	switch len(dq.Parts) {
+	case 0:
+		return "", true
+	case 1:
+		lit, _ := dq.Parts[0].(*Lit)
+		if lit == nil || strings.ContainsAny(lit.Value, "$`\\!") {
+			return "", false
+		}
+		return lit.Value, true
+	}
+	return "", false
+}
+
+// hdocDelim returns the heredoc delimiter word to print for a redirect,
+// applying the UpperHeredocDelims and QuoteHeredocDelims options.
+func (p *Printer) hdocDelim(r *Redirect) *Word {
+	if !p.upperHdocDelims && p.hdocDelimQuote == QuoteKeep {
+		return r.Word
+	}
+	if len(r.Word.Parts) != 1 {
+		return r.Word
+	}
+	var delim string
+	quote := QuoteNone
+	switch wp := r.Word.Parts[0].(type) {
+	case *Lit:
+		delim = wp.Value
+	case *SglQuoted:
+		if wp.Dollar {
+			return r.Word
+		}
+		delim, quote = wp.Value, QuoteSingle
+	case *DblQuoted:
+		val, ok := simpleDblQuoted(wp)
+		if !ok || wp.Dollar {
+			return r.Word
+		}
+		delim, quote = val, QuoteDouble
+	default:
+		return r.Word
+	}
+	if delim == "" {
+		return r.Word
+	}
+	for _, c := range delim {
+		switch {
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9':
+		case c == '_', c == '-', c == '.':
+		default:
+			return r.Word
+		}
+	}
+	newDelim, newQuote := delim, quote
+	if p.upperHdocDelims {
+		newDelim = strings.ToUpper(delim)
+		if newDelim != delim && hdocHasLine(r, newDelim) {
+			newDelim = delim
+		}
+	}
+	switch p.hdocDelimQuote {
+	case QuoteKeep:
+	case QuoteNone:
+		// Unquoting enables expansions in the body.
+		if quote == QuoteNone || hdocBodyIsLiteral(r, "$`\\") {
+			newQuote = QuoteNone
+		}
+	default:
+		// Quoting disables expansions in the body.
+		if quote != QuoteNone || hdocBodyIsLiteral(r, "\\") {
+			newQuote = p.hdocDelimQuote
+		}
+	}
+	if newDelim == delim && newQuote == quote {
+		return r.Word
+	}
+	pos, end := r.Word.Pos(), r.Word.End()
+	var wp WordPart
+	switch newQuote {
+	case QuoteNone:
+		wp = &Lit{ValuePos: pos, ValueEnd: end, Value: newDelim}
+	case QuoteSingle:
+		wp = &SglQuoted{Left: pos, Right: end, Value: newDelim}
+	default: // QuoteDouble
+		wp = &DblQuoted{Left: pos, Right: end, Parts: []WordPart{
+			&Lit{ValuePos: pos, ValueEnd: end, Value: newDelim},
+		}}
+	}
+	return &Word{Parts: []WordPart{wp}}
+}
+
+// hdocBodyIsLiteral reports whether a heredoc's body is a single literal
+// which doesn't contain any of the given characters.
+// Note that escaped newlines split an unquoted body into multiple literals.
+func hdocBodyIsLiteral(r *Redirect, chars string) bool {
+	if r.Hdoc == nil || len(r.Hdoc.Parts) == 0 {
+		return true
+	}
+	if len(r.Hdoc.Parts) > 1 {
+		return false
+	}
+	lit, _ := r.Hdoc.Parts[0].(*Lit)
+	return lit != nil && !strings.ContainsAny(lit.Value, chars)
+}
+
+// hdocHasLine reports whether a heredoc's body might contain the given line,
+// which would end the heredoc early if used as its delimiter.
+func hdocHasLine(r *Redirect, line string) bool {
+	if r.Hdoc == nil {
+		return false
+	}
+	for _, wp := range r.Hdoc.Parts {
+		lit, _ := wp.(*Lit)
+		if lit == nil {
+			continue
+		}
+		for _, l := range strings.Split(lit.Value, "\n") {
+			if r.Op == DashHdoc {
+				l = strings.TrimLeft(l, "\t")
+			}
+			if l == line {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (p *Printer) wordJoin(ws []*Word) {
 	anyNewline := false
 	for _, w := range ws {
@@ -1038,9 +1275,15 @@ func (p *Printer) stmt(s *Stmt) {
 		} else {
 			p.wantSpace = spaceRequired
 		}
-		p.word(r.Word)
 		if r.Op == Hdoc || r.Op == DashHdoc {
+			// Heredoc delimiters have their own quoting option.
+			litQuote := p.litQuote
+			p.litQuote = QuoteKeep
+			p.word(p.hdocDelim(r))
+			p.litQuote = litQuote
 			p.pendingHdocs = append(p.pendingHdocs, r)
+		} else {
+			p.word(r.Word)
 		}
 	}
 	sep := s.Semicolon.IsValid() && s.Semicolon.Line() > p.line && !p.singleLine
@@ -1332,7 +1575,8 @@ func (p *Printer) ifClause(ic *IfClause, elif bool) {
 	}
 	p.nestedStmts(ic.Then, ic.ThenLast, thenEnd)
 
-	if el != nil && el.ThenPos.IsValid() {
+	// An "else" has no condition; built syntax trees may lack positions.
+	if el != nil && (el.ThenPos.IsValid() || len(el.Cond) > 0) {
 		p.comments(ic.Last...)
 		p.semiRsrv("elif", el.Position)
 		p.ifClause(el, true)
@@ -1359,13 +1603,22 @@ func (p *Printer) ifClause(ic *IfClause, elif bool) {
 
 func (p *Printer) stmtList(stmts []*Stmt, last []Comment) {
 	sep := p.wantNewline || (len(stmts) > 0 && stmts[0].Pos().Line() > p.line)
+	verbatimEnd := 0
 	for i, s := range stmts {
+		if i < verbatimEnd {
+			continue // already printed verbatim
+		}
 		if i > 0 && p.singleLine && p.wantNewline && !p.wroteSemi {
 			// In singleLine mode, ensure we use semicolons between
 			// statements.
 			p.WriteByte(';')
 			p.wantSpace = spaceRequired
 		}
+		if end, text := p.verbatimStmts(stmts, i); end > i {
+			p.verbatim(stmts[i:end], text)
+			verbatimEnd = end
+			continue
+		}
 		pos := s.Pos()
 		var midComs, endComs []Comment
 		for _, c := range s.Comments {
@@ -1400,6 +1653,107 @@ func (p *Printer) stmtList(stmts []*Stmt, last []Comment) {
 	p.comments(last...)
 }
 
+// verbatimStmts reports whether stmts[i] starts a list of statements which
+// lie within one of the regions to be printed verbatim. If so, it returns the
+// index of the first statement after the list, and the part of the region
+// which covers the list, including the indentation of its first line.
+func (p *Printer) verbatimStmts(stmts []*Stmt, i int) (end int, text Region) {
+	first := stmts[i]
+	for _, r := range p.regions {
+		if r.End < r.Start || uint(len(r.Text)) != r.End-r.Start {
+			continue // malformed region
+		}
+		if !r.contains(first) {
+			continue
+		}
+		end = i + 1
+		for end < len(stmts) && r.contains(stmts[end]) {
+			end++
+		}
+		text.Start = first.Pos().Offset()
+		if col := first.Pos().Col(); col > 0 && text.Start-(col-1) >= r.Start {
+			lineStart := text.Start - (col - 1)
+			indent := r.Text[lineStart-r.Start : text.Start-r.Start]
+			if len(bytes.Trim(indent, " \t")) == 0 {
+				text.Start = lineStart
+			}
+		}
+		last := stmts[end-1]
+		text.End = last.End().Offset()
+		if last.Semicolon.IsValid() && !last.Background && !last.Coprocess {
+			// The printer adds semicolons where needed.
+			text.End = last.Semicolon.Offset()
+		}
+		text.Text = bytes.TrimRight(r.Text[text.Start-r.Start:text.End-r.Start], " \t")
+		return end, text
+	}
+	return i, Region{}
+}
+
+// contains reports whether a statement lies entirely within a region.
+func (r Region) contains(s *Stmt) bool {
+	if s.Pos().Offset() < r.Start || s.End().Offset() > r.End {
+		return false
+	}
+	valid := true
+	Walk(s, func(node Node) bool {
+		if r, ok := node.(*Redirect); ok && r.Hdoc == nil &&
+			(r.Op == Hdoc || r.Op == DashHdoc) {
+			// An empty heredoc body doesn't tell us where the
+			// closing delimiter is, so we can't copy it.
+			valid = false
+		}
+		return valid
+	})
+	return valid
+}
+
+// verbatim prints a list of statements by copying their source text, to keep
+// their formatting untouched.
+func (p *Printer) verbatim(stmts []*Stmt, text Region) {
+	first, last := stmts[0], stmts[len(stmts)-1]
+	// Comments before the verbatim source are printed as usual.
+	for _, c := range first.Comments {
+		if c.Pos().Offset() < text.Start {
+			p.comments(c)
+		}
+	}
+	p.flushComments()
+	if col := first.Pos().Col(); col > 0 && text.Start == first.Pos().Offset()-(col-1) {
+		// Like newlines, but the source keeps its own indentation.
+		level, lastLevel := p.level, p.lastLevel
+		p.level = 0
+		p.newlines(first.Pos())
+		p.level, p.lastLevel = level, lastLevel
+	} else {
+		p.newlines(first.Pos())
+		if p.wantSpace == spaceRequired {
+			p.space()
+		}
+	}
+	for i, line := range strings.Split(string(text.Text), "\n") {
+		if i > 0 {
+			p.WriteByte('\n')
+		}
+		p.writeLit(line)
+	}
+	p.advanceLine(last.End().Line())
+	p.wantSpace = spaceRequired
+	p.wantNewline = true
+	Walk(last, func(node Node) bool {
+		if r, ok := node.(*Redirect); ok && (r.Op == Hdoc || r.Op == DashHdoc) {
+			// Nothing can follow a heredoc's closing delimiter.
+			p.mustNewline = true
+		}
+		return true
+	})
+	for _, c := range last.Comments {
+		if c.Pos().Offset() >= last.End().Offset() {
+			p.comments(c)
+		}
+	}
+}
+
 func (p *Printer) nestedStmts(stmts []*Stmt, last []Comment, closing Pos) {
 	p.incLevel()
 	switch {
@@ -934,6 +934,258 @@ func TestPrintSpaceRedirects(t *testing.T) {
 	}
 }
 
+func TestPrintHeredocDelims(t *testing.T) {
+	t.Parallel()
+	tests := [...]struct {
+		opts []PrinterOption
+		printCase
+	}{
+		{
+			[]PrinterOption{UpperHeredocDelims(true)},
+			printCase{"cat <<eof\nfoo\neof", "cat <<EOF\nfoo\nEOF"},
+		},
+		{
+			[]PrinterOption{UpperHeredocDelims(true)},
+			printCase{"cat <<'end'\n$foo\nend", "cat <<'END'\n$foo\nEND"},
+		},
+		{
+			[]PrinterOption{UpperHeredocDelims(true)},
+			samePrint("cat <<eof\nEOF\neof"),
+		},
+		{
+			[]PrinterOption{UpperHeredocDelims(true)},
+			samePrint("cat <<-eof\n\tEOF\neof"),
+		},
+		{
+			[]PrinterOption{UpperHeredocDelims(true)},
+			samePrint("cat <<e'o'f\nfoo\neof"),
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteSingle)},
+			printCase{"cat <<EOF\nfoo\nEOF", "cat <<'EOF'\nfoo\nEOF"},
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteSingle)},
+			printCase{"cat <<\"EOF\"\n$foo\nEOF", "cat <<'EOF'\n$foo\nEOF"},
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteSingle)},
+			samePrint("cat <<EOF\n$foo\nEOF"),
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteSingle)},
+			samePrint("cat <<EOF\nfoo\\\nbar\nEOF"),
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteDouble)},
+			printCase{"cat <<EOF\nfoo\nEOF", "cat <<\"EOF\"\nfoo\nEOF"},
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteNone)},
+			printCase{"cat <<'EOF'\nfoo\nEOF", "cat <<EOF\nfoo\nEOF"},
+		},
+		{
+			[]PrinterOption{QuoteHeredocDelims(QuoteNone)},
+			samePrint("cat <<'EOF'\n$foo `bar`\nEOF"),
+		},
+		{
+			[]PrinterOption{UpperHeredocDelims(true), QuoteHeredocDelims(QuoteSingle), QuoteLiterals(QuoteDouble)},
+			printCase{"cat <<-eof\n\tfoo\n\teof", "cat <<-'EOF'\n\tfoo\nEOF"},
+		},
+	}
+	parser := NewParser(KeepComments(true))
+	for _, tc := range tests {
+		printer := NewPrinter(tc.opts...)
+		t.Run("", func(t *testing.T) {
+			printTest(t, parser, printer, tc.in, tc.want)
+		})
+	}
+}
+
+func TestPrintQuoteLiterals(t *testing.T) {
+	t.Parallel()
+	tests := [...]struct {
+		style QuoteStyle
+		printCase
+	}{
+		{QuoteDouble, printCase{"echo 'foo bar'", `echo "foo bar"`}},
+		{QuoteDouble, printCase{"echo foo'bar'", `echo foo"bar"`}},
+		{QuoteDouble, printCase{"echo ''", `echo ""`}},
+		{QuoteDouble, samePrint("echo '$foo'")},
+		{QuoteDouble, samePrint(`echo 'foo\bar'`)},
+		{QuoteDouble, samePrint(`echo 'say "hi"'`)},
+		{QuoteDouble, samePrint("echo $'foo'")},
+		{QuoteSingle, printCase{`echo "foo bar"`, "echo 'foo bar'"}},
+		{QuoteSingle, printCase{`echo ""`, "echo ''"}},
+		{QuoteSingle, samePrint(`echo "$foo"`)},
+		{QuoteSingle, samePrint(`echo "don't"`)},
+		{QuoteSingle, samePrint(`echo "foo\"bar"`)},
+		{QuoteSingle, samePrint(`echo $"foo"`)},
+		{QuoteNone, samePrint(`echo "foo" 'bar'`)},
+	}
+	parser := NewParser(KeepComments(true))
+	for _, tc := range tests {
+		printer := NewPrinter(QuoteLiterals(tc.style))
+		t.Run("", func(t *testing.T) {
+			printTest(t, parser, printer, tc.in, tc.want)
+		})
+	}
+}
+
+func TestPrintSourceDirectives(t *testing.T) {
+	t.Parallel()
+	tests := [...]printCase{
+		samePrint("# shfmt: off\nfoo   bar"),
+		{
+			"echo   a\n# shfmt: off\nfoo  |   bar\n  if   true;   then\n    x\n  fi\n# shfmt: on\necho   b",
+			"echo a\n# shfmt: off\nfoo  |   bar\n  if   true;   then\n    x\n  fi\n# shfmt: on\necho b",
+		},
+		{
+			"# shfmt: ignore-next-line\necho   a\necho   b",
+			"# shfmt: ignore-next-line\necho   a\necho b",
+		},
+		{
+			"# shfmt: ignore-next-line\necho   a # trailing\necho   b",
+			"# shfmt: ignore-next-line\necho   a # trailing\necho b",
+		},
+		{
+			"f() {\n\t# shfmt: ignore-next-line\n\t  x=(  1   2 )\n\ty=(  1   2 )\n}",
+			"f() {\n\t# shfmt: ignore-next-line\n\t  x=(  1   2 )\n\ty=(1 2)\n}",
+		},
+		{
+			"{\n# shfmt: off\nfoo   bar\n}\nfoo   bar",
+			"{\n\t# shfmt: off\nfoo   bar\n}\nfoo bar",
+		},
+		{
+			"# shfmt: off\ncat   <<EOF\n  body\nEOF\necho   a",
+			"# shfmt: off\ncat   <<EOF\n  body\nEOF\necho   a",
+		},
+		{
+			"# shfmt: ignore-next-line\ncat   <<EOF\n  body\nEOF\necho   a",
+			"# shfmt: ignore-next-line\ncat   <<EOF\n  body\nEOF\necho a",
+		},
+		{
+			"# shfmt: ignore-next-line\ncat   <<EOF\nEOF\necho   a",
+			"# shfmt: ignore-next-line\ncat <<EOF\nEOF\necho a",
+		},
+		{
+			"# shfmt: unknown\necho   a",
+			"# shfmt: unknown\necho a",
+		},
+	}
+	parser := NewParser(KeepComments(true))
+	printer := NewPrinter()
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			prog, err := parser.Parse(strings.NewReader(tc.in), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sb strings.Builder
+			if err := printer.PrintSource(&sb, prog, []byte(tc.in)); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + "\n"
+			if got := sb.String(); got != want {
+				t.Fatalf("PrintSource mismatch:\nin:\n%s\nwant:\n%sgot:\n%s", tc.in, want, got)
+			}
+		})
+	}
+}
+
+func TestPrintShebang(t *testing.T) {
+	t.Parallel()
+	tests := [...]printCase{
+		samePrint("#!/bin/sh"),
+		samePrint("#!/bin/sh\necho foo"),
+		samePrint("#!/bin/sh\n\necho foo"),
+		samePrint("#!/bin/sh\n# comment\necho foo"),
+		samePrint("#!/usr/bin/env bash\nfoo #!/bin/sh\n#!/bin/sh"),
+		{"#!/bin/bash -e  \n\n\n\nfoo", "#!/bin/bash -e\n\nfoo"},
+		{" #!/bin/sh\nfoo", "#!/bin/sh\nfoo"},
+	}
+	parser := NewParser(KeepComments(true))
+	printer := NewPrinter()
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			printTest(t, parser, printer, tc.in, tc.want)
+		})
+	}
+
+	// The shebang line is kept without KeepComments,
+	// and it can be modified or added.
+	f, err := NewParser().Parse(strings.NewReader("#!/bin/sh -e\n# comment\nfoo"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#!/bin/sh -e"; f.Shebang != want {
+		t.Fatalf("want shebang %q, got %q", want, f.Shebang)
+	}
+	f.Shebang = "#!/bin/bash"
+	got, err := strPrint(printer, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#!/bin/bash\n\nfoo\n"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	f = &File{Shebang: "#!/bin/sh", Stmts: []*Stmt{litStmt("foo")}}
+	got, err = strPrint(printer, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#!/bin/sh\nfoo\n"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestPrintWithRegions(t *testing.T) {
+	t.Parallel()
+	// Each test marks its region with "[" and "]", which are removed
+	// from the source before parsing.
+	tests := [...]printCase{
+		{"foo   a\n[bar   b\n]baz   c", "foo a\nbar   b\nbaz c"},
+		{"[foo   a\nbar   b]", "foo   a\nbar   b"},
+		{"foo   a;[  bar   b]", "foo a\nbar   b"},
+		{"{\n[  foo   a\n  bar   b\n]}", "{\n  foo   a\n  bar   b\n}"},
+		{"{\n  foo   a\n  [bar   b\n]}", "{\n\tfoo a\n\tbar   b\n}"},
+		{"if   x;  then\n[  foo   a\n]fi", "if x; then\n  foo   a\nfi"},
+		{"[if   x;  then\n  foo   a\n]fi", "if x; then\n  foo   a\nfi"},
+		{"if   [x  ;]  then\n  foo   a\nfi", "if x; then\n\tfoo a\nfi"},
+		{"[foo   a &\n]bar   b", "foo   a &\nbar b"},
+		{"foo   a\n[bar   b;\n]", "foo a\nbar   b"},
+		{"foo   a\n[# comment\nbar   b # trailing\n]", "foo a\n# comment\nbar   b # trailing"},
+		{"[cat   <<EOF\n  body\nEOF\n]foo   a", "cat   <<EOF\n  body\nEOF\nfoo a"},
+		{"foo   a\n[]bar   b", "foo a\nbar b"},
+	}
+	parser := NewParser(KeepComments(true))
+	printer := NewPrinter()
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			start := strings.Index(tc.in, "[")
+			end := strings.Index(tc.in, "]") - 1
+			src := strings.Replace(strings.Replace(tc.in, "[", "", 1), "]", "", 1)
+			prog, err := parser.Parse(strings.NewReader(src), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			regions := []Region{{
+				Start: uint(start),
+				End:   uint(end),
+				Text:  []byte(src[start:end]),
+			}}
+			var sb strings.Builder
+			if err := printer.PrintWithRegions(&sb, prog, regions); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + "\n"
+			if got := sb.String(); got != want {
+				t.Fatalf("PrintWithRegions mismatch:\nin:\n%s\nwant:\n%sgot:\n%s", tc.in, want, got)
+			}
+		})
+	}
+}
+
 func TestPrintKeepPadding(t *testing.T) {
 	t.Parallel()
 	tests := [...]printCase{
@@ -1166,6 +1418,8 @@ func TestPrintOptionsNotBroken(t *testing.T) {
 	}{
 		{"Minify", []PrinterOption{Minify(true)}},
 		{"SingleLine", []PrinterOption{SingleLine(true)}},
+		{"QuoteSingle", []PrinterOption{UpperHeredocDelims(true), QuoteHeredocDelims(QuoteSingle), QuoteLiterals(QuoteSingle)}},
+		{"QuoteDouble", []PrinterOption{QuoteHeredocDelims(QuoteNone), QuoteLiterals(QuoteDouble)}},
 	} {
 		printer := NewPrinter(opts.list...)
 		for _, tc := range append(fileTests, fileTestsNoPrint...) {
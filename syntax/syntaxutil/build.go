@@ -0,0 +1,200 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package syntaxutil provides helpers to build shell syntax trees, such as to
+// generate shell scripts from Go programs without writing shell source code.
+//
+// All positions in the built nodes are left invalid, which [syntax.Printer]
+// supports; without positions, each statement is printed on a single line.
+// Nodes should not be shared between different parts of a tree, as
+// the printer or other tools may modify them.
+package syntaxutil
+
+import (
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Lit returns a literal with the given value, which is used verbatim. To build
+// a literal word from an arbitrary string, use [Quote].
+func Lit(value string) *syntax.Lit {
+	return &syntax.Lit{Value: value}
+}
+
+// Word returns a word made of the given parts.
+func Word(parts ...syntax.WordPart) *syntax.Word {
+	return &syntax.Word{Parts: parts}
+}
+
+// LitWord returns a word with a single literal, which is used verbatim.
+func LitWord(value string) *syntax.Word {
+	return Word(Lit(value))
+}
+
+// LitWords is like [LitWord], for multiple words.
+func LitWords(values ...string) []*syntax.Word {
+	words := make([]*syntax.Word, len(values))
+	for i, value := range values {
+		words[i] = LitWord(value)
+	}
+	return words
+}
+
+// Quote returns a word which expands to s as a single field, quoting it when
+// necessary as per [syntax.Quote] with [syntax.LangBash]. For example, "foo"
+// results in the literal foo, and "foo bar" results in 'foo bar'.
+func Quote(s string) (*syntax.Word, error) {
+	quoted, err := syntax.Quote(s, syntax.LangBash)
+	if err != nil {
+		return nil, err
+	}
+	return Word(quotedPart(quoted)), nil
+}
+
+// quotedPart turns the output of [syntax.Quote] into a word part.
+func quotedPart(quoted string) syntax.WordPart {
+	switch {
+	case strings.HasPrefix(quoted, "$'"):
+		return &syntax.SglQuoted{Dollar: true, Value: quoted[2 : len(quoted)-1]}
+	case strings.HasPrefix(quoted, "'"):
+		return &syntax.SglQuoted{Value: quoted[1 : len(quoted)-1]}
+	case strings.HasPrefix(quoted, `"`):
+		return &syntax.DblQuoted{Parts: []syntax.WordPart{Lit(quoted[1 : len(quoted)-1])}}
+	}
+	return Lit(quoted)
+}
+
+// DblQuote returns double quotes around the given parts, such as literals and
+// parameter expansions. Note that literals are used verbatim, so characters
+// like '"' and '$' must be escaped with backslashes.
+func DblQuote(parts ...syntax.WordPart) *syntax.DblQuoted {
+	return &syntax.DblQuoted{Parts: parts}
+}
+
+// Param returns a parameter expansion like $name, or ${name} when needed,
+// such as with positional parameters like ${10}.
+func Param(name string) *syntax.ParamExp {
+	return &syntax.ParamExp{
+		Short: syntax.ValidName(name) || len(name) == 1,
+		Param: Lit(name),
+	}
+}
+
+// CmdSubst returns a command substitution like $(stmts).
+func CmdSubst(stmts ...*syntax.Stmt) *syntax.CmdSubst {
+	return &syntax.CmdSubst{Stmts: stmts}
+}
+
+// Assign returns a variable assignment like name=value.
+// A nil value results in an empty assignment like name=.
+func Assign(name string, value *syntax.Word) *syntax.Assign {
+	return &syntax.Assign{Name: Lit(name), Value: value}
+}
+
+// Call returns a simple command with the given arguments,
+// such as the words from [LitWords] or [Quote].
+func Call(args ...*syntax.Word) *syntax.CallExpr {
+	return &syntax.CallExpr{Args: args}
+}
+
+// Stmt returns a statement with the given command.
+func Stmt(cmd syntax.Command) *syntax.Stmt {
+	return &syntax.Stmt{Cmd: cmd}
+}
+
+// Stmts is like [Stmt], for multiple commands.
+func Stmts(cmds ...syntax.Command) []*syntax.Stmt {
+	stmts := make([]*syntax.Stmt, len(cmds))
+	for i, cmd := range cmds {
+		stmts[i] = Stmt(cmd)
+	}
+	return stmts
+}
+
+// File returns a file with the given statements.
+func File(stmts ...*syntax.Stmt) *syntax.File {
+	return &syntax.File{Stmts: stmts}
+}
+
+func binary(op syntax.BinCmdOperator, cmds []syntax.Command) syntax.Command {
+	if len(cmds) == 0 {
+		panic("syntaxutil: need at least one command")
+	}
+	cmd := cmds[0]
+	for _, y := range cmds[1:] {
+		cmd = &syntax.BinaryCmd{Op: op, X: Stmt(cmd), Y: Stmt(y)}
+	}
+	return cmd
+}
+
+// Pipe returns a pipeline like "x | y | z". With a single command, it returns
+// that same command. It panics if no commands are given.
+func Pipe(cmds ...syntax.Command) syntax.Command {
+	return binary(syntax.Pipe, cmds)
+}
+
+// And returns a list like "x && y && z", following the same rules as [Pipe].
+func And(cmds ...syntax.Command) syntax.Command {
+	return binary(syntax.AndStmt, cmds)
+}
+
+// Or returns a list like "x || y || z", following the same rules as [Pipe].
+func Or(cmds ...syntax.Command) syntax.Command {
+	return binary(syntax.OrStmt, cmds)
+}
+
+// Not returns a statement whose exit status is negated, like "! cmd".
+func Not(cmd syntax.Command) *syntax.Stmt {
+	return &syntax.Stmt{Cmd: cmd, Negated: true}
+}
+
+// If returns an if clause which runs then when cond succeeds.
+// To add an "else" or "elif", use [Else] or [Elif].
+func If(cond, then []*syntax.Stmt) *syntax.IfClause {
+	return &syntax.IfClause{Cond: cond, Then: then}
+}
+
+// Else sets the "else" branch of an if clause, and returns the if clause.
+func Else(ic *syntax.IfClause, stmts ...*syntax.Stmt) *syntax.IfClause {
+	last(ic).Else = &syntax.IfClause{Then: stmts}
+	return ic
+}
+
+// Elif adds an "elif" branch to an if clause, and returns the if clause.
+func Elif(ic *syntax.IfClause, cond, then []*syntax.Stmt) *syntax.IfClause {
+	last(ic).Else = If(cond, then)
+	return ic
+}
+
+// last returns the last clause in a chain of if clauses.
+func last(ic *syntax.IfClause) *syntax.IfClause {
+	for ic.Else != nil {
+		ic = ic.Else
+	}
+	return ic
+}
+
+// Block returns a block like "{ stmts; }".
+func Block(stmts ...*syntax.Stmt) *syntax.Block {
+	return &syntax.Block{Stmts: stmts}
+}
+
+// Subshell returns a subshell like "( stmts )".
+func Subshell(stmts ...*syntax.Stmt) *syntax.Subshell {
+	return &syntax.Subshell{Stmts: stmts}
+}
+
+// Func returns a function declaration like "name() { stmts; }".
+func Func(name string, stmts ...*syntax.Stmt) *syntax.FuncDecl {
+	return &syntax.FuncDecl{Name: Lit(name), Body: Stmt(Block(stmts...))}
+}
+
+// ForIn returns a loop like "for name in items; do stmts; done".
+// Note that without any items, it iterates over the positional parameters.
+func ForIn(name string, items []*syntax.Word, stmts ...*syntax.Stmt) *syntax.ForClause {
+	return &syntax.ForClause{
+		Loop: &syntax.WordIter{Name: Lit(name), Items: items},
+		Do:   stmts,
+	}
+}
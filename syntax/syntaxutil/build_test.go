@@ -0,0 +1,115 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntaxutil_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+
+	"mvdan.cc/sh/v3/syntax"
+	su "mvdan.cc/sh/v3/syntax/syntaxutil"
+)
+
+func mustQuote(s string) *syntax.Word {
+	w, err := su.Quote(s)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+var buildTests = []struct {
+	node syntax.Node
+	want string
+}{
+	{su.Call(su.LitWords("echo", "foo")...), "echo foo"},
+	{mustQuote("foo"), "foo"},
+	{mustQuote(""), "''"},
+	{mustQuote("foo bar"), "'foo bar'"},
+	{mustQuote("it's $HOME"), `"it's \$HOME"`},
+	{mustQuote("tab\there"), `$'tab\there'`},
+	{su.Word(su.Lit("a"), su.Param("b"), su.Param("10"), su.Param("@")), "a$b${10}$@"},
+	{su.Word(su.DblQuote(su.Param("x"), su.Lit(" y"))), `"$x y"`},
+	{su.Word(su.CmdSubst(su.Stmts(su.Call(su.LitWord("date")))...)), "$(date)"},
+	{
+		&syntax.CallExpr{Assigns: []*syntax.Assign{su.Assign("a", su.LitWord("b")), su.Assign("c", nil)}},
+		"a=b c=",
+	},
+	{su.Pipe(su.Call(su.LitWord("a"))), "a"},
+	{
+		su.Pipe(su.Call(su.LitWord("a")), su.Call(su.LitWord("b")), su.Call(su.LitWord("c"))),
+		"a | b | c",
+	},
+	{
+		su.Or(su.And(su.Call(su.LitWord("a")), su.Call(su.LitWord("b"))), su.Call(su.LitWord("c"))),
+		"a && b || c",
+	},
+	{su.Not(su.Call(su.LitWord("a"))), "! a"},
+	{
+		su.If(su.Stmts(su.Call(su.LitWord("a"))), su.Stmts(su.Call(su.LitWord("b")))),
+		"if a; then b; fi",
+	},
+	{
+		su.Else(su.Elif(
+			su.If(su.Stmts(su.Call(su.LitWord("a"))), su.Stmts(su.Call(su.LitWord("b")))),
+			su.Stmts(su.Call(su.LitWord("c"))), su.Stmts(su.Call(su.LitWord("d"))),
+		), su.Stmt(su.Call(su.LitWord("e")))),
+		"if a; then b; elif c; then d; else e; fi",
+	},
+	{su.Block(su.Stmts(su.Call(su.LitWord("a")))...), "{ a; }"},
+	{su.Subshell(su.Stmts(su.Call(su.LitWord("a")))...), "(a)"},
+	{su.Func("f", su.Stmts(su.Call(su.LitWord("a")))...), "f() { a; }"},
+	{
+		su.ForIn("x", su.LitWords("1", "2"), su.Stmt(su.Call(su.LitWord("echo"), su.Word(su.Param("x"))))),
+		"for x in 1 2; do echo $x; done",
+	},
+	{su.ForIn("x", nil, su.Stmt(su.Call(su.LitWord("a")))), "for x; do a; done"},
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+	printer := syntax.NewPrinter(syntax.SingleLine(true))
+	for _, test := range buildTests {
+		var sb strings.Builder
+		err := printer.Print(&sb, test.node)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Check(t, qt.Equals(strings.TrimSuffix(sb.String(), "\n"), test.want))
+	}
+}
+
+func TestBuildRoundtrip(t *testing.T) {
+	t.Parallel()
+	// Quoted words must expand to the original strings.
+	for _, s := range []string{"foo", "a b", "'", `"$x"`, "\x01\n", "~", "*"} {
+		var sb strings.Builder
+		err := syntax.NewPrinter().Print(&sb, mustQuote(s))
+		qt.Assert(t, qt.IsNil(err))
+		f, err := syntax.NewParser().Parse(strings.NewReader("echo "+sb.String()), "")
+		qt.Assert(t, qt.IsNil(err))
+		got, err := syntax.Quote(s, syntax.LangBash)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Check(t, qt.Equals(sb.String(), got))
+		qt.Check(t, qt.HasLen(f.Stmts[0].Cmd.(*syntax.CallExpr).Args, 2))
+	}
+	_, err := su.Quote("nul\x00")
+	qt.Assert(t, qt.IsNotNil(err))
+}
+
+func Example() {
+	msg, _ := su.Quote("Hello, $USER!")
+	f := su.File(
+		su.Stmt(&syntax.CallExpr{Assigns: []*syntax.Assign{su.Assign("greeting", msg)}}),
+		su.Stmt(su.If(
+			su.Stmts(su.Call(su.LitWords("[", "-t", "1", "]")...)),
+			su.Stmts(su.Call(su.LitWord("echo"), su.Word(su.DblQuote(su.Param("greeting"))))),
+		)),
+	)
+	syntax.NewPrinter().Print(os.Stdout, f)
+	// Output:
+	// greeting='Hello, $USER!'
+	// if [ -t 1 ]; then echo "$greeting"; fi
+}
@@ -0,0 +1,322 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntaxutil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+var rxPlaceholder = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_]*)\}\}`)
+
+// Template parses a Bash script with placeholders like {{name}}, and replaces
+// them with the given values, quoting each value for the context where its
+// placeholder appears. This way, each value is used verbatim, and is never run
+// as shell code. For example, given the value "it's $HOME", the template
+//
+//	echo {{msg}} "msg: {{msg}}"
+//
+// results in
+//
+//	echo "it's \$HOME" "msg: it's \$HOME"
+//
+// Placeholders may appear in words, in double quotes, and in heredoc bodies.
+// A placeholder which makes up an entire argument of a simple command or an
+// entire item of a for loop may be given a []string value, resulting in one
+// argument per element. Other values may be strings, [fmt.Stringer]s, booleans,
+// or numbers. Escaping a placeholder's first brace, such as \{{name}}, leaves it
+// untouched.
+//
+// An error is returned if a placeholder lacks a value, if it appears elsewhere,
+// such as within single quotes, or if a value cannot be quoted, such as when it
+// contains null bytes.
+func Template(src string, values map[string]any) (*syntax.File, error) {
+	f, err := syntax.NewParser(syntax.KeepComments(true)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return nil, err
+	}
+	t := &template{values: values}
+	t.check(f)
+	if t.err != nil {
+		return nil, t.err
+	}
+	syntax.Walk(f, t.visit)
+	if t.err != nil {
+		return nil, t.err
+	}
+	return f, nil
+}
+
+type template struct {
+	values map[string]any
+	err    error
+
+	// hdocs holds the heredoc bodies, which are words too,
+	// but need different escaping.
+	hdocs map[*syntax.Word]bool
+}
+
+func (t *template) errorf(pos syntax.Pos, format string, args ...any) {
+	if t.err == nil {
+		t.err = fmt.Errorf("%s: %s", pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// check ensures that placeholders only appear where they are supported,
+// before any values are inserted, as the values may contain placeholders too.
+func (t *template) check(f *syntax.File) {
+	t.hdocs = make(map[*syntax.Word]bool)
+	supported := make(map[*syntax.Lit]bool)
+	delims := make(map[*syntax.Word]bool)
+	syntax.Walk(f, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Redirect:
+			if node.Hdoc != nil {
+				t.hdocs[node.Hdoc] = true
+				delims[node.Word] = true
+			}
+		case *syntax.Word:
+			if delims[node] {
+				break
+			}
+			for _, part := range node.Parts {
+				switch part := part.(type) {
+				case *syntax.Lit:
+					supported[part] = true
+				case *syntax.DblQuoted:
+					for _, part := range part.Parts {
+						if lit, ok := part.(*syntax.Lit); ok {
+							supported[lit] = true
+						}
+					}
+				}
+			}
+		case *syntax.Lit:
+			if !supported[node] {
+				t.checkUnsupported(node.Pos(), node.Value)
+			}
+		case *syntax.SglQuoted:
+			t.checkUnsupported(node.Pos(), node.Value)
+		}
+		return t.err == nil
+	})
+}
+
+func (t *template) checkUnsupported(pos syntax.Pos, s string) {
+	if name, _, _ := nextPlaceholder(s); name != "" {
+		t.errorf(pos, "placeholder {{%s}} not supported here", name)
+	}
+}
+
+func (t *template) visit(node syntax.Node) bool {
+	switch node := node.(type) {
+	case *syntax.CallExpr:
+		node.Args = t.words(node.Args)
+	case *syntax.WordIter:
+		node.Items = t.words(node.Items)
+	case *syntax.Redirect:
+		if node.Hdoc != nil {
+			t.heredoc(node)
+		}
+	case *syntax.Word:
+		if !t.hdocs[node] {
+			node.Parts = t.wordParts(node.Parts)
+		}
+	}
+	return t.err == nil
+}
+
+// nextPlaceholder finds the first placeholder in s which isn't escaped,
+// returning its name and its start and end offsets.
+func nextPlaceholder(s string) (name string, start, end int) {
+	offs := 0
+	for {
+		loc := rxPlaceholder.FindStringSubmatchIndex(s[offs:])
+		if loc == nil {
+			return "", 0, 0
+		}
+		start, end := offs+loc[0], offs+loc[1]
+		backslashes := len(s[:start]) - len(strings.TrimRight(s[:start], `\`))
+		if backslashes%2 == 0 {
+			return s[offs+loc[2] : offs+loc[3]], start, end
+		}
+		offs = end
+	}
+}
+
+// value returns the string for a placeholder's value.
+func (t *template) value(pos syntax.Pos, name string) string {
+	switch v := t.values[name].(type) {
+	case nil:
+		if _, ok := t.values[name]; !ok {
+			t.errorf(pos, "no value for placeholder {{%s}}", name)
+			return ""
+		}
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case []string:
+		t.errorf(pos, "placeholder {{%s}} cannot be a list here", name)
+		return ""
+	}
+	t.errorf(pos, "unsupported value type for placeholder {{%s}}: %T", name, t.values[name])
+	return ""
+}
+
+// replace replaces the placeholders in a literal, calling fn with the text
+// between the placeholders as well as with the placeholders' values.
+func (t *template) replace(pos syntax.Pos, s string, fn func(text string, isValue bool)) {
+	for t.err == nil {
+		name, start, end := nextPlaceholder(s)
+		if name == "" {
+			break
+		}
+		if start > 0 {
+			fn(s[:start], false)
+		}
+		value := t.value(pos, name)
+		if t.err != nil {
+			return
+		}
+		fn(value, true)
+		s = s[end:]
+	}
+	if s != "" {
+		fn(s, false)
+	}
+}
+
+// words replaces placeholders for []string values which make up entire words.
+func (t *template) words(words []*syntax.Word) []*syntax.Word {
+	var result []*syntax.Word
+	for i, word := range words {
+		lit := word.Lit()
+		name, start, end := nextPlaceholder(lit)
+		list, ok := t.values[name].([]string)
+		if name == "" || start > 0 || end < len(lit) || !ok {
+			if result != nil {
+				result = append(result, word)
+			}
+			continue
+		}
+		if result == nil {
+			result = append(result, words[:i]...)
+		}
+		for _, s := range list {
+			quoted, err := Quote(s)
+			if err != nil {
+				t.errorf(word.Pos(), "placeholder {{%s}}: %v", name, err)
+				return words
+			}
+			result = append(result, quoted)
+		}
+	}
+	if result == nil {
+		return words
+	}
+	return result
+}
+
+func (t *template) wordParts(parts []syntax.WordPart) []syntax.WordPart {
+	var result []syntax.WordPart
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			t.replace(part.Pos(), part.Value, func(s string, isValue bool) {
+				if !isValue {
+					result = append(result, Lit(s))
+					return
+				}
+				quoted, err := syntax.Quote(s, syntax.LangBash)
+				if err != nil {
+					t.errorf(part.Pos(), "%v", err)
+					return
+				}
+				result = append(result, quotedPart(quoted))
+			})
+			continue
+		case *syntax.DblQuoted:
+			part.Parts = t.escapedParts(part.Parts, `"\$`+"`", "")
+		}
+		result = append(result, part)
+	}
+	return result
+}
+
+// escapedParts replaces placeholders in literals, such as in double quotes or
+// in heredocs, escaping the given characters with backslashes. Any line equal
+// to delim is rejected, as it would end a heredoc early.
+func (t *template) escapedParts(parts []syntax.WordPart, chars, delim string) []syntax.WordPart {
+	for _, part := range parts {
+		lit, ok := part.(*syntax.Lit)
+		if !ok {
+			continue
+		}
+		var sb strings.Builder
+		t.replace(lit.Pos(), lit.Value, func(s string, isValue bool) {
+			if !isValue {
+				sb.WriteString(s)
+				return
+			}
+			if strings.Contains(s, "\x00") {
+				t.errorf(lit.Pos(), "cannot quote a string containing null bytes")
+				return
+			}
+			if delim != "" && strings.Contains("\n"+s+"\n", "\n"+delim+"\n") {
+				t.errorf(lit.Pos(), "value contains the heredoc delimiter %q", delim)
+				return
+			}
+			for _, r := range s {
+				if strings.ContainsRune(chars, r) {
+					sb.WriteByte('\\')
+				}
+				sb.WriteRune(r)
+			}
+		})
+		lit.Value = sb.String()
+	}
+	return parts
+}
+
+func (t *template) heredoc(rd *syntax.Redirect) {
+	delim, quoted := hdocDelim(rd.Word)
+	if quoted {
+		// Nothing is expanded, so nothing is escaped either.
+		t.escapedParts(rd.Hdoc.Parts, "", delim)
+	} else {
+		t.escapedParts(rd.Hdoc.Parts, `\$`+"`", delim)
+	}
+}
+
+// hdocDelim returns a heredoc's delimiter, and whether it is quoted,
+// which means that the heredoc body is not expanded.
+func hdocDelim(word *syntax.Word) (delim string, quoted bool) {
+	var sb strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			if strings.Contains(part.Value, `\`) {
+				quoted = true
+			}
+			sb.WriteString(strings.ReplaceAll(part.Value, `\`, ""))
+		case *syntax.SglQuoted:
+			quoted = true
+			sb.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			quoted = true
+			for _, part := range part.Parts {
+				if lit, ok := part.(*syntax.Lit); ok {
+					sb.WriteString(lit.Value)
+				}
+			}
+		}
+	}
+	return sb.String(), quoted
+}
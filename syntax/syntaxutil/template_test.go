@@ -0,0 +1,171 @@
+// Copyright (c) 2024, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntaxutil_test
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+
+	"mvdan.cc/sh/v3/interp"
+	"mvdan.cc/sh/v3/syntax"
+	su "mvdan.cc/sh/v3/syntax/syntaxutil"
+)
+
+type stringer struct{}
+
+func (stringer) String() string { return "from stringer" }
+
+var templateTests = []struct {
+	src     string
+	values  map[string]any
+	want    string
+	wantErr string
+}{
+	{src: "echo foo", want: "echo foo\n"},
+	{src: "echo {{x}}", values: map[string]any{"x": "foo"}, want: "echo foo\n"},
+	{src: "echo {{x}}", values: map[string]any{"x": "a b"}, want: "echo 'a b'\n"},
+	{src: "echo {{x}}", values: map[string]any{"x": ""}, want: "echo ''\n"},
+	{src: "echo {{x}}", values: map[string]any{"x": "$(rm -rf /)"}, want: "echo '$(rm -rf /)'\n"},
+	{src: "echo a{{x}}b", values: map[string]any{"x": "; c"}, want: "echo a'; c'b\n"},
+	{src: "echo {{x}}{{y}}", values: map[string]any{"x": 1, "y": true}, want: "echo 1true\n"},
+	{src: "echo {{x}}", values: map[string]any{"x": stringer{}}, want: "echo 'from stringer'\n"},
+	{src: "echo {{x}}", values: map[string]any{"x": "{{y}}"}, want: "echo '{{y}}'\n"},
+	{src: `echo \{{x}}`, want: "echo \\{{x}}\n"},
+	{src: "foo={{x}}", values: map[string]any{"x": "a b"}, want: "foo='a b'\n"},
+	{src: "echo $({{x}})", values: map[string]any{"x": "a b"}, want: "echo $('a b')\n"},
+	{
+		src:    `echo "msg: {{x}}" "$HOME"`,
+		values: map[string]any{"x": "it's \"$HOME\" `a` \\"},
+		want:   "echo \"msg: it's \\\"\\$HOME\\\" \\`a\\` \\\\\" \"$HOME\"\n",
+	},
+	{
+		src:    "echo {{xs}} end",
+		values: map[string]any{"xs": []string{"a", "b c"}},
+		want:   "echo a 'b c' end\n",
+	},
+	{src: "echo {{xs}}", values: map[string]any{"xs": []string{}}, want: "echo\n"},
+	{
+		src:    "for x in {{xs}}; do echo $x; done",
+		values: map[string]any{"xs": []string{"1", "2"}},
+		want:   "for x in 1 2; do echo $x; done\n",
+	},
+	{
+		src:    "cat <<EOF\n{{x}}\nEOF",
+		values: map[string]any{"x": "$a `b` \\c"},
+		want:   "cat <<EOF\n\\$a \\`b\\` \\\\c\nEOF\n",
+	},
+	{
+		src:    "cat <<'EOF'\n{{x}}\nEOF",
+		values: map[string]any{"x": "$a `b` \\c"},
+		want:   "cat <<'EOF'\n$a `b` \\c\nEOF\n",
+	},
+	{
+		src:     "echo {{x}}",
+		wantErr: "1:6: no value for placeholder {{x}}",
+	},
+	{
+		src:     "echo '{{x}}'",
+		values:  map[string]any{"x": "foo"},
+		wantErr: "1:6: placeholder {{x}} not supported here",
+	},
+	{
+		src:     "{{x}}() { :; }",
+		values:  map[string]any{"x": "foo"},
+		wantErr: "1:1: placeholder {{x}} not supported here",
+	},
+	{
+		src:     "cat <<{{x}}\nfoo\n{{x}}",
+		values:  map[string]any{"x": "foo"},
+		wantErr: "1:7: placeholder {{x}} not supported here",
+	},
+	{
+		src:     "echo a{{xs}}",
+		values:  map[string]any{"xs": []string{"a"}},
+		wantErr: "1:6: placeholder {{xs}} cannot be a list here",
+	},
+	{
+		src:     "echo {{x}}",
+		values:  map[string]any{"x": []byte("foo")},
+		wantErr: "1:6: unsupported value type for placeholder {{x}}: []uint8",
+	},
+	{
+		src:     "echo {{x}}",
+		values:  map[string]any{"x": "nul\x00"},
+		wantErr: "cannot quote",
+	},
+	{
+		src:     `echo "{{x}}"`,
+		values:  map[string]any{"x": "nul\x00"},
+		wantErr: "cannot quote",
+	},
+	{
+		src:     "cat <<EOF\n{{x}}\nEOF",
+		values:  map[string]any{"x": "foo\nEOF\nrm -rf /"},
+		wantErr: `2:1: value contains the heredoc delimiter "EOF"`,
+	},
+}
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+	printer := syntax.NewPrinter()
+	for _, test := range templateTests {
+		t.Run("", func(t *testing.T) {
+			f, err := su.Template(test.src, test.values)
+			if test.wantErr != "" {
+				qt.Assert(t, qt.ErrorMatches(err, ".*"+regexp.QuoteMeta(test.wantErr)+".*"))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			var sb strings.Builder
+			err = printer.Print(&sb, f)
+			qt.Assert(t, qt.IsNil(err))
+			qt.Check(t, qt.Equals(sb.String(), test.want))
+		})
+	}
+}
+
+func TestTemplateRun(t *testing.T) {
+	t.Parallel()
+	// The values must reach the commands verbatim.
+	value := "a 'b' \"c\" $d `e` \\f * ~\n$(g)"
+	f, err := su.Template(`printf '%s|' {{v}} "{{v}}" x{{v}}x {{vs}}; cat <<EOF
+{{v}}
+EOF`, map[string]any{"v": value, "vs": []string{value, ""}})
+	qt.Assert(t, qt.IsNil(err))
+
+	var sb strings.Builder
+	r, err := interp.New(interp.StdIO(nil, &sb, &sb))
+	qt.Assert(t, qt.IsNil(err))
+	err = r.Run(context.Background(), f)
+	qt.Assert(t, qt.IsNil(err))
+	want := strings.Repeat(value+"|", 2) + "x" + value + "x|" + value + "||" + value + "\n"
+	qt.Check(t, qt.Equals(sb.String(), want))
+}
+
+func ExampleTemplate() {
+	f, err := su.Template(`greet() {
+	echo "Hello, {{name}}!"
+}
+greet
+ls {{files}}
+`, map[string]any{
+		"name":  "$USER",
+		"files": []string{"foo.txt", "my file.txt"},
+	})
+	if err != nil {
+		panic(err)
+	}
+	syntax.NewPrinter().Print(os.Stdout, f)
+	// Output:
+	// greet() {
+	// 	echo "Hello, \$USER!"
+	// }
+	// greet
+	// ls foo.txt 'my file.txt'
+}